@@ -5,8 +5,9 @@
 //
 // # Architecture Overview
 //
-// The application follows a layered architecture:
-//   - main.go: Application entry point and initialization
+// This file only wires the application together; the API server, its
+// options, the Storage interface and the backends live in the reviewapi
+// package so that other programs can embed them. Within reviewapi:
 //   - api.go: HTTP routing and request handlers (presentation layer)
 //   - storage.go: Database access and persistence (data layer)
 //   - types.go: Domain models and DTOs
@@ -14,8 +15,12 @@
 // # Quick Start
 //
 // 1. Ensure PostgreSQL is running on localhost:5432
-// 2. Set environment variables (optional - defaults work for local dev):
+// 2. Configure the server (optional - defaults work for local dev) with a
+// YAML file (-config), environment variables or flags:
 //   - DB_HOST, DB_PORT, DB_USER, DB_PASSWORD, DB_NAME
+//   - or DATABASE_URL with a full DSN / postgres:// URL
+//   - DB_SSLMODE, DB_SSLROOTCERT, DB_SSLCERT, DB_SSLKEY for TLS to PostgreSQL
+//   - run with -h for every setting, -print-config to show the effective values
 //
 // 3. Run the server:
 //
@@ -23,12 +28,22 @@
 //
 // 4. The API will be available at http://localhost:8080
 //
+// # HTTPS
+//
+// Set TLS_CERT_FILE and TLS_KEY_FILE to serve HTTPS. Certificates are reloaded
+// automatically when the files change. TLS_MIN_VERSION selects the minimum
+// protocol version, and TLS_CLIENT_CA_FILE with TLS_REQUIRE_CLIENT_CERT=true
+// enables mutual TLS for internal deployments.
+//
 // # API Endpoints
 //
 //	POST   /review      - Create a new review
 //	GET    /review/{id} - Get a review by ID
 //	PUT    /review/{id} - Update a review
 //	DELETE /review/{id} - Delete a review
+//	GET    /metrics     - Prometheus metrics
+//	GET    /healthz     - Liveness probe
+//	GET    /readyz      - Readiness probe (database, statements, schema version)
 //
 // # Graceful Shutdown
 //
@@ -37,40 +52,163 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"os"
+
+	"github.com/Logan-0/goBackend/reviewapi"
 )
 
 // main is the application entry point.
 // It initializes the database connection and starts the HTTP server.
 //
 // Initialization sequence:
-//  1. Connect to PostgreSQL database
-//  2. Configure connection pool
-//  3. Prepare SQL statements
-//  4. Start HTTP server with graceful shutdown support
+//  1. Load and validate configuration (defaults, file, env, flags)
+//  2. Create the structured logger
+//  3. Configure the storage backend selected by database.driver (PostgreSQL
+//     or SQLite) and its connection pool
+//  4. Connect in the background, retrying with backoff, then apply schema
+//     migrations and prepare SQL statements
+//  5. Start the background purge of expired deleted reviews, the webhook
+//     dispatcher and the review event feed
+//  6. Start HTTP server with graceful shutdown support (not ready until 4 completes)
 //
-// The function will log.Fatal and exit if database connection fails.
+// The process exits with status 1 if the configuration is invalid or the
+// database stays unreachable for database.connect_retry.max_elapsed.
 // Once the server is running, it blocks until a shutdown signal is received.
 func main() {
-	// Initialize Database connection and prepare statements
-	client, err := InitializeClientAndDB()
+	if err := run(os.Args[1:]); err != nil {
+		os.Exit(1)
+	}
+}
+
+// run initializes and serves the application (see main), returning once the
+// server has stopped. Every failure is logged before it is returned, and the
+// deferred cleanup (server shutdown, background jobs, database client,
+// tracer flush) runs on every path.
+//
+// Parameters:
+//   - args: The command line arguments, without the program name
+//
+// Returns:
+//   - error: Non-nil if the application failed to start or stopped on an error
+func run(args []string) error {
+	// Load configuration from defaults, config file, environment and flags
+	cfg, opts, err := reviewapi.LoadConfig(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		// The configured logger is not available yet; use a default JSON logger
+		return failed(slog.New(slog.NewJSONHandler(os.Stderr, nil)), "load configuration", err)
+	}
+	if opts.PrintConfig {
+		fmt.Print(cfg)
+		return nil
+	}
+
+	logger, err := reviewapi.NewLogger(cfg.Log, os.Stderr)
+	if err != nil {
+		return failed(slog.New(slog.NewJSONHandler(os.Stderr, nil)), "create logger", err)
+	}
+	logger.Info("configuration loaded", "environment", cfg.Environment, "config_file", opts.ConfigFile, "driver", cfg.Database.Driver)
+	if cfg.Server.TLS.ClientCAFile == "" {
+		// Without client certificates only admin token holders are identified
+		logger.Warn("mutual TLS is disabled: review changes are audited as the anonymous actor",
+			"setting", "server.tls.client_ca_file")
+	}
+
+	// Install the tracer provider before any component starts spans
+	shutdownTracing, err := reviewapi.SetupTracing(context.Background(), cfg.Tracing)
 	if err != nil {
-		log.Fatal("********************** Failed: Connection to Database ", err.Error())
+		return failed(logger, "set up tracing", err)
 	}
-	fmt.Println("********************** Success: Database Port 5432")
-
-	// Table creation is commented out for development.
-	// In production, tables should be managed via migrations.
-	// Uncomment below to auto-create the reviews table on startup:
-	//
-	// err = client.CreateReviewTable()
-	// if err != nil {
-	// 	log.Fatal("********************** Failed: Create Review Table" + err.Error())
-	// }
-	// fmt.Println("********************** Success: Create Review Table")
-
-	// Start the HTTP server (blocks until shutdown signal)
-	fmt.Println("********************** Success: Server Running 8080")
-	RunNewServer("0.0.0.0:8080", client)
+	defer shutdownTracing(context.Background())
+
+	// reviewapi.Metrics are recorded only when enabled (a nil *reviewapi.Metrics is a no-op)
+	var metrics *reviewapi.Metrics
+	if cfg.Metrics.Enabled {
+		metrics = reviewapi.NewMetrics()
+	}
+
+	// Create the database client; it reports not-ready until connected
+	client, err := reviewapi.NewBackend(cfg.Database, logger, metrics)
+	if err != nil {
+		return failed(logger, "configure database", err)
+	}
+	defer client.Close()
+
+	// Connect in the background so /healthz and /readyz answer while the
+	// database comes up. If it stays unreachable, stop the server so the
+	// process exits and is restarted; on shutdown stop retrying and wait
+	// before closing the client.
+	connectCtx, cancelConnect := context.WithCancel(context.Background())
+	serveCtx, stopServing := context.WithCancelCause(context.Background())
+	defer stopServing(nil)
+	connectDone := make(chan struct{})
+	go func() {
+		defer close(connectDone)
+		if err := client.Connect(connectCtx); err != nil {
+			if connectCtx.Err() == nil {
+				stopServing(fmt.Errorf("connect to database: %w", err))
+			}
+			return
+		}
+		logger.Info("database connected")
+	}()
+	defer func() {
+		cancelConnect()
+		<-connectDone
+	}()
+
+	// Decorate the stores: all of them share the retry policy and circuit
+	// breaker, and every store whose writes change reviews goes through the
+	// cache so that it invalidates them
+	resilience := reviewapi.NewResilience(cfg.Resilience, logger, metrics)
+	cache := reviewapi.NewReviewCache(cfg.Cache, metrics)
+	storage := reviewapi.NewInstrumentedStorage(cache.Storage(resilience.Storage(client)), metrics)
+	trash := reviewapi.NewInstrumentedTrash(cache.Trash(resilience.Trash(client)), metrics)
+	revisions := reviewapi.NewInstrumentedRevisions(cache.Revisions(resilience.Revisions(client)), metrics)
+
+	// Purge reviews that have been in the trash past the retention period
+	stopPurge := reviewapi.StartTrashPurge(trash, cfg.Trash, logger)
+	defer stopPurge()
+
+	// Deliver review events from the outbox to the configured webhooks
+	stopWebhooks := reviewapi.StartWebhookDispatcher(client, cfg.Webhooks, logger, metrics)
+	defer stopWebhooks()
+
+	// Start the HTTP(S) server (blocks until shutdown signal)
+	options := []reviewapi.ServerOption{
+		reviewapi.WithLogger(logger),
+		reviewapi.WithMetrics(metrics, cfg.Metrics.Path),
+		reviewapi.WithReadiness(client),
+		reviewapi.WithStopContext(serveCtx),
+		reviewapi.WithTrash(trash),
+		reviewapi.WithRevisions(revisions),
+		reviewapi.WithAudit(resilience.Audit(client)),
+		reviewapi.WithWebhookAdmin(resilience.WebhookAdmin(client)),
+	}
+
+	// Stream the review events committed to the database at /events
+	if cfg.Events.Enabled {
+		broadcaster := reviewapi.NewEventBroadcaster(cfg.Events.BufferSize, metrics)
+		stopEvents := reviewapi.StartEventFeed(client, broadcaster, logger)
+		defer stopEvents()
+		options = append(options, reviewapi.WithEvents(broadcaster, resilience.EventLog(client), cfg.Events.KeepAlive))
+	}
+
+	if err := reviewapi.RunNewServer(cfg.Server, storage, options...); err != nil {
+		return failed(logger, "server failed", err)
+	}
+	return nil
+}
+
+// failed logs err at error level and returns it.
+func failed(logger *slog.Logger, msg string, err error) error {
+	logger.Error(msg, "error", err)
+	return err
 }
@@ -0,0 +1,814 @@
+// Package reviewapi provides the HTTP API layer for the Movie Review API.
+// This file implements the REST API server using the chi router, including
+// route definitions, request handlers, HTTPS serving and graceful shutdown support.
+//
+// API Endpoints:
+//   - POST   /review      - Create a new review
+//   - GET    /review/{id} - Retrieve a review by ID
+//   - PUT    /review/{id} - Update an existing review
+//   - DELETE /review/{id} - Move a review to the trash
+//   - GET    /trash       - List deleted reviews (see trash.go)
+//   - POST   /review/{id}/restore - Restore a deleted review
+//   - GET    /review/{id}/revisions - List revisions (see revisions.go)
+//   - GET    /review/{id}/revisions/{revision} - Get a revision
+//   - GET    /review/{id}/revisions/diff - Compare two revisions
+//   - POST   /review/{id}/revisions/{revision}/revert - Revert to a revision
+//   - GET    /events      - Stream review changes (see events.go)
+//   - GET    /admin/audit - Query the audit log (see audit.go)
+//   - GET    /admin/webhooks/dead-letters - List dead webhook deliveries (see webhooks.go)
+//   - POST   /admin/webhooks/dead-letters/{id}/retry - Retry a dead delivery
+//   - GET    /metrics     - Prometheus metrics (path configurable)
+//   - GET    /healthz     - Liveness probe
+//   - GET    /readyz      - Readiness probe
+package reviewapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// WriteJSON is a helper function that writes a JSON response to the client.
+// It sets the Content-Type header to application/json and encodes the provided
+// value as JSON in the response body.
+//
+// Parameters:
+//   - writer: The HTTP response writer
+//   - status: The HTTP status code to send (e.g., http.StatusOK)
+//   - anyVar: Any value that can be marshaled to JSON
+//
+// Returns:
+//   - error: Non-nil if JSON encoding fails
+//
+// Note: Headers must be set before WriteHeader is called, which this function
+// handles correctly.
+func WriteJSON(writer http.ResponseWriter, status int, anyVar any) error {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	return json.NewEncoder(writer).Encode(anyVar)
+}
+
+// apiFunc is a function signature for HTTP handlers that can return errors.
+// This allows handlers to return errors instead of handling them inline,
+// which are then processed by makeHttpHandleFunc.
+type apiFunc func(http.ResponseWriter, *http.Request) error
+
+// ApiError represents a JSON error response returned to clients.
+// It provides a consistent error format across all API endpoints.
+//
+// Example JSON response:
+//
+//	{"Error": "review with id 123 not found", "RequestID": "4f3c9a..."}
+type ApiError struct {
+	// Error contains the human-readable error message
+	Error string
+
+	// RequestID identifies the failed request in the server logs
+	RequestID string `json:"RequestID,omitempty"`
+}
+
+// StatusClientClosedRequest is the non-standard status (popularized by nginx)
+// recorded when the client disconnected before the response was ready.
+const StatusClientClosedRequest = 499
+
+// errServerShutdown is the cancellation cause of in-flight requests that are
+// still running when the graceful shutdown timeout expires.
+var errServerShutdown = errors.New("server shutting down")
+
+// statusForError maps a handler error to the HTTP status code returned.
+//
+// Status mapping:
+//   - 503 Service Unavailable: The request was aborted by server shutdown,
+//     the storage backend is still connecting, or its circuit breaker is open
+//   - 504 Gateway Timeout: A storage operation exceeded its time budget
+//   - 499 Client Closed Request: The client disconnected mid-request
+//   - 400 Bad Request: Any other error
+func statusForError(err error) int {
+	switch {
+	case errors.Is(err, errServerShutdown), errors.Is(err, ErrNotReady), errors.Is(err, ErrCircuitOpen):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, ErrCanceled):
+		return StatusClientClosedRequest
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// parseLimit reads the optional limit query parameter of a listing request.
+//
+// Parameters:
+//   - request: The HTTP request
+//   - defaultLimit: The limit used when the parameter is absent
+//   - maxLimit: The largest limit accepted
+//
+// Returns:
+//   - int: The limit
+//   - error: Non-nil if the parameter is not an integer in [1, maxLimit]
+func parseLimit(request *http.Request, defaultLimit, maxLimit int) (int, error) {
+	raw := request.URL.Query().Get("limit")
+	if raw == "" {
+		return defaultLimit, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 1 || value > maxLimit {
+		return 0, fmt.Errorf("invalid limit %q: must be between 1 and %d", raw, maxLimit)
+	}
+	return value, nil
+}
+
+// makeHttpHandleFunc wraps an apiFunc to create a standard http.HandlerFunc.
+// It provides centralized error handling - if the wrapped function returns
+// an error, it's automatically converted to a JSON error response tagged with
+// the request's ID. The status is HTTP 400 Bad Request unless the error is a
+// cancellation or timeout (see statusForError).
+//
+// This pattern allows handlers to focus on business logic and simply return
+// errors, rather than handling HTTP response writing for error cases.
+//
+// Parameters:
+//   - function: The apiFunc to wrap
+//
+// Returns:
+//   - http.HandlerFunc: A standard handler that can be registered with the router
+func makeHttpHandleFunc(function apiFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if err := function(writer, request); err != nil {
+			WriteJSON(writer, statusForError(err), ApiError{
+				Error:     err.Error(),
+				RequestID: RequestIDFromContext(request.Context()),
+			})
+		}
+	}
+}
+
+// APIServer represents the HTTP API server instance.
+// It holds the server configuration, database connection, router and, once
+// started, the HTTP server.
+//
+// The router can be used on its own (Handler), e.g. mounted inside another
+// server or driven by httptest, or served by the APIServer itself with
+// Start and Shutdown. RunNewServer does the latter until a signal arrives.
+type APIServer struct {
+	// cfg holds the listen address, timeouts, TLS, cache and admin settings.
+	cfg ServerConfig
+
+	// dbInstance is the storage backend implementing the Storage interface
+	dbInstance Storage
+
+	// The optional stores behind the trash, revision, audit and dead-letter
+	// routes; a route is not served when its store is nil.
+	trash     TrashStore
+	revisions RevisionStore
+	audit     AuditStore
+	webhooks  WebhookAdmin
+
+	// logger is the structured logger for server and handler messages
+	logger *slog.Logger
+
+	// handler is the router serving the API, built by NewAPIServer.
+	handler http.Handler
+
+	// httpServer is the underlying HTTP server for graceful shutdown support
+	httpServer *http.Server
+
+	// listener is the socket accepted on, set by Start.
+	listener net.Listener
+
+	// serveErr receives the error that stopped the server, if any.
+	serveErr chan error
+
+	// cancelRequests cancels the contexts of requests still running when
+	// the shutdown timeout expires.
+	cancelRequests context.CancelCauseFunc
+
+	// readiness reports whether dependencies are ready (nil means always ready)
+	readiness ReadinessChecker
+
+	// shuttingDown is set when graceful shutdown begins so /readyz fails
+	shuttingDown atomic.Bool
+
+	// events feeds GET /events (nil when the route is not served), whose
+	// idle streams send a comment every eventsKeepAlive; eventLog replays
+	// the events missed by resuming clients.
+	events          *EventBroadcaster
+	eventLog        EventLog
+	eventsKeepAlive time.Duration
+
+	// streamsDone is closed when the HTTP server shuts down, ending the
+	// event streams, which would otherwise hold shutdown up.
+	streamsDone chan struct{}
+
+	// stop shuts RunNewServer down like a signal once done (see
+	// WithStopContext).
+	stop context.Context
+}
+
+// serverOptions collects the optional settings of NewAPIServer.
+type serverOptions struct {
+	logger      *slog.Logger
+	metrics     *Metrics
+	metricsPath string
+	readiness   ReadinessChecker
+	trash       TrashStore
+	revisions   RevisionStore
+	audit       AuditStore
+	webhooks    WebhookAdmin
+	events      *EventBroadcaster
+	eventLog    EventLog
+	stop        context.Context
+	middleware  []func(http.Handler) http.Handler
+	routes      []func(chi.Router)
+
+	eventsKeepAlive time.Duration
+}
+
+// ServerOption customizes an APIServer created by NewAPIServer.
+type ServerOption func(*serverOptions)
+
+// WithLogger sets the logger for server, handler and access log messages
+// (default slog.Default()).
+func WithLogger(logger *slog.Logger) ServerOption {
+	return func(o *serverOptions) { o.logger = logger }
+}
+
+// WithMetrics records request metrics and serves them at path. Without it,
+// or with nil metrics, no metrics are recorded or served.
+func WithMetrics(metrics *Metrics, path string) ServerOption {
+	return func(o *serverOptions) {
+		o.metrics = metrics
+		o.metricsPath = path
+	}
+}
+
+// WithReadiness sets the dependency checks behind /readyz (default: always
+// ready).
+func WithReadiness(readiness ReadinessChecker) ServerOption {
+	return func(o *serverOptions) { o.readiness = readiness }
+}
+
+// WithTrash serves GET /trash and POST /review/{id}/restore from trash
+// (see trash.go).
+func WithTrash(trash TrashStore) ServerOption {
+	return func(o *serverOptions) { o.trash = trash }
+}
+
+// WithRevisions serves the /review/{id}/revisions routes from revisions
+// (see revisions.go).
+func WithRevisions(revisions RevisionStore) ServerOption {
+	return func(o *serverOptions) { o.revisions = revisions }
+}
+
+// WithAudit serves GET /admin/audit from audit when an admin token is set
+// (see audit.go).
+func WithAudit(audit AuditStore) ServerOption {
+	return func(o *serverOptions) { o.audit = audit }
+}
+
+// WithWebhookAdmin serves the /admin/webhooks/dead-letters routes from
+// webhooks when an admin token is set (see webhooks.go).
+func WithWebhookAdmin(webhooks WebhookAdmin) ServerOption {
+	return func(o *serverOptions) { o.webhooks = webhooks }
+}
+
+// WithEvents serves GET /events, streaming the events of broadcaster,
+// replaying those after Last-Event-ID from log and sending a keep-alive
+// comment on streams idle for keepAlive (see events.go).
+func WithEvents(broadcaster *EventBroadcaster, log EventLog, keepAlive time.Duration) ServerOption {
+	return func(o *serverOptions) {
+		o.events = broadcaster
+		o.eventLog = log
+		o.eventsKeepAlive = keepAlive
+	}
+}
+
+// WithStopContext makes RunNewServer shut down gracefully when ctx is done,
+// as on a signal, and return context.Cause(ctx). It lets a failing background
+// task (e.g. the database connection) stop the server through the normal
+// shutdown path.
+func WithStopContext(ctx context.Context) ServerOption {
+	return func(o *serverOptions) { o.stop = ctx }
+}
+
+// WithMiddleware adds middleware around every route, including extra routes.
+// It runs after the built-in middleware, so request IDs, tracing spans and
+// consistency sessions are already in the request context.
+func WithMiddleware(middleware ...func(http.Handler) http.Handler) ServerOption {
+	return func(o *serverOptions) { o.middleware = append(o.middleware, middleware...) }
+}
+
+// WithRoutes registers extra routes next to the review API, for example
+// application specific endpoints. They get the same middleware.
+//
+// Example:
+//
+//	server := NewAPIServer(cfg, storage, WithRoutes(func(r chi.Router) {
+//	    r.Get("/version", handleVersion)
+//	}))
+func WithRoutes(register func(chi.Router)) ServerOption {
+	return func(o *serverOptions) { o.routes = append(o.routes, register) }
+}
+
+// NewAPIServer creates the API server and builds its router, without
+// listening. Use Handler to serve the API from another server, or Start and
+// Shutdown to run it on cfg.Addr.
+//
+// Parameters:
+//   - cfg: Listen address, timeouts, TLS and cache settings (see ServerConfig)
+//   - dbInstance: The storage backend for persisting reviews
+//   - opts: Logger, metrics, readiness, middleware and extra routes
+//
+// Returns:
+//   - *APIServer: The server, ready to be started
+func NewAPIServer(cfg ServerConfig, dbInstance Storage, opts ...ServerOption) *APIServer {
+	options := serverOptions{logger: slog.Default(), stop: context.Background()}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	server := &APIServer{
+		cfg:        cfg,
+		dbInstance: dbInstance,
+		logger:     options.logger.With("component", "server"),
+		readiness:  options.readiness,
+		trash:      options.trash,
+		revisions:  options.revisions,
+		audit:      options.audit,
+		webhooks:   options.webhooks,
+
+		events:          options.events,
+		eventLog:        options.eventLog,
+		eventsKeepAlive: options.eventsKeepAlive,
+		streamsDone:     make(chan struct{}),
+		stop:            options.stop,
+	}
+	server.handler = server.routes(options)
+	return server
+}
+
+// NewHandler returns the router serving the review API, for mounting inside
+// another HTTP server. It is NewAPIServer(cfg, dbInstance, opts...).Handler().
+//
+// Example:
+//
+//	mux := http.NewServeMux()
+//	mux.Handle("/reviews-api/", http.StripPrefix("/reviews-api", NewHandler(cfg.Server, storage)))
+func NewHandler(cfg ServerConfig, dbInstance Storage, opts ...ServerOption) http.Handler {
+	return NewAPIServer(cfg, dbInstance, opts...).Handler()
+}
+
+// Handler returns the router serving the API.
+func (server *APIServer) Handler() http.Handler {
+	return server.handler
+}
+
+// Start listens on cfg.Addr and serves the API in the background. Listen
+// errors (e.g. address in use) are returned directly; a later failure of
+// the server is delivered on Err.
+//
+// Returns:
+//   - error: Non-nil if TLS cannot be configured or the address cannot be bound
+func (server *APIServer) Start() error {
+	// Request contexts derive from baseCtx so that requests still running
+	// when the shutdown timeout expires have their storage calls cancelled
+	baseCtx, cancelRequests := context.WithCancelCause(context.Background())
+	server.cancelRequests = cancelRequests
+
+	// Configure HTTP server with security-conscious timeouts
+	server.httpServer = &http.Server{
+		Addr:         server.cfg.Addr,
+		Handler:      server.handler,
+		ReadTimeout:  server.cfg.ReadTimeout,  // Prevents slow client attacks
+		WriteTimeout: server.cfg.WriteTimeout, // Prevents slow response attacks
+		IdleTimeout:  server.cfg.IdleTimeout,  // Keep-alive connection timeout
+		BaseContext:  func(net.Listener) context.Context { return baseCtx },
+	}
+	server.httpServer.RegisterOnShutdown(func() { close(server.streamsDone) })
+
+	// Configure HTTPS (and optionally mutual TLS) before accepting connections
+	if server.cfg.TLS.Enabled() {
+		serverTLS, err := server.cfg.TLS.Build(server.logger)
+		if err != nil {
+			cancelRequests(errServerShutdown)
+			return fmt.Errorf("configure TLS: %w", err)
+		}
+		server.httpServer.TLSConfig = serverTLS
+	}
+
+	listener, err := net.Listen("tcp", server.cfg.Addr)
+	if err != nil {
+		cancelRequests(errServerShutdown)
+		return fmt.Errorf("server failed: %w", err)
+	}
+	server.listener = listener
+
+	// Serve in a background goroutine
+	server.serveErr = make(chan error, 1)
+	go func() {
+		var err error
+		if server.httpServer.TLSConfig != nil {
+			// Certificates are served by TLSConfig.GetCertificate
+			err = server.httpServer.ServeTLS(listener, "", "")
+		} else {
+			err = server.httpServer.Serve(listener)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			server.serveErr <- fmt.Errorf("server failed: %w", err)
+		}
+	}()
+	server.logger.Info("server listening", "addr", listener.Addr().String(), "tls", server.cfg.TLS.Enabled())
+	return nil
+}
+
+// Addr returns the address the server listens on, or nil before Start.
+// It reports the actual port when cfg.Addr uses port 0.
+func (server *APIServer) Addr() net.Addr {
+	if server.listener == nil {
+		return nil
+	}
+	return server.listener.Addr()
+}
+
+// Err returns a channel receiving the error that stopped a started server.
+// It receives nothing after a successful Shutdown.
+func (server *APIServer) Err() <-chan error {
+	return server.serveErr
+}
+
+// Shutdown stops a started server gracefully. /readyz starts failing at
+// once, the server keeps serving for cfg.DrainDelay so load balancers can
+// stop routing to it, and then in-flight requests are given until ctx is
+// done to complete. Requests still running then are cancelled and their
+// connections closed.
+//
+// Parameters:
+//   - ctx: Bounds the drain delay and the wait for in-flight requests
+//
+// Returns:
+//   - error: Non-nil if requests were still running when ctx was done
+//
+// Shutdown does nothing if the server was not started.
+func (server *APIServer) Shutdown(ctx context.Context) error {
+	if server.httpServer == nil {
+		return nil
+	}
+
+	// Report not-ready first and keep serving while load balancers drain
+	server.shuttingDown.Store(true)
+	drain := time.NewTimer(server.cfg.DrainDelay)
+	select {
+	case <-drain.C:
+	case <-ctx.Done():
+		drain.Stop()
+	}
+
+	// Attempt graceful shutdown - allows in-flight requests to complete.
+	// On timeout, cancel the remaining requests and close their connections.
+	defer server.cancelRequests(errServerShutdown)
+	if err := server.httpServer.Shutdown(ctx); err != nil {
+		server.cancelRequests(errServerShutdown)
+		server.httpServer.Close()
+		return fmt.Errorf("server shutdown failed: %w", err)
+	}
+
+	server.logger.Info("server stopped")
+	return nil
+}
+
+// RunNewServer creates, configures, and starts the HTTP API server.
+// It sets up routing, configures timeouts, and implements graceful shutdown
+// on SIGINT or SIGTERM signals.
+//
+// Parameters:
+//   - cfg: Listen address, timeouts and TLS settings (see ServerConfig)
+//   - dbInstance: The storage backend for persisting reviews
+//   - opts: Logger, metrics, readiness, events and the other options of
+//     NewAPIServer
+//
+// Returns:
+//   - error: Non-nil if the server fails to start, shutdown fails, or the
+//     context of WithStopContext is cancelled with a cause
+//
+// The server runs until it receives an interrupt signal (Ctrl+C) or SIGTERM,
+// or until the context of WithStopContext is done,
+// at which point /readyz starts failing, the server keeps serving for
+// cfg.DrainDelay so load balancers can stop routing to it, and then it
+// gracefully shuts down, giving in-flight requests up to cfg.ShutdownTimeout
+// (default 30 seconds) to complete.
+//
+// Default Timeouts:
+//   - ReadTimeout: 15 seconds - max time to read request
+//   - WriteTimeout: 15 seconds - max time to write response
+//   - IdleTimeout: 60 seconds - max time for keep-alive connections
+//   - ShutdownTimeout: 30 seconds - max time for graceful shutdown
+//
+// When TLS is enabled the certificate files are reloaded automatically when
+// they change on disk, and client certificates are verified against the
+// configured CA bundle for mutual TLS deployments.
+func RunNewServer(cfg ServerConfig, dbInstance Storage, opts ...ServerOption) error {
+	server := NewAPIServer(cfg, dbInstance, opts...)
+
+	// Set up graceful shutdown signal handling before serving
+	shutdownChan := make(chan os.Signal, 1)
+	signal.Notify(shutdownChan, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(shutdownChan)
+
+	if err := server.Start(); err != nil {
+		return err
+	}
+
+	// Block until shutdown signal received (or the server fails or is stopped)
+	var stopErr error
+	select {
+	case err := <-server.Err():
+		return err
+	case sig := <-shutdownChan:
+		server.logger.Info("shutting down gracefully", "signal", sig.String(),
+			"drain_delay", cfg.DrainDelay, "timeout", cfg.ShutdownTimeout)
+	case <-server.stop.Done():
+		stopErr = context.Cause(server.stop)
+		server.logger.Info("shutting down gracefully", "cause", stopErr.Error(),
+			"drain_delay", cfg.DrainDelay, "timeout", cfg.ShutdownTimeout)
+	}
+
+	// The shutdown timeout starts once draining is over
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.DrainDelay+cfg.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		return err
+	}
+	return stopErr
+}
+
+// routes builds the chi router serving the API: the middleware chain, the
+// review routes, the routes of the optional stores given, the health probes,
+// the metrics endpoint when enabled, and the extra routes of the options.
+//
+// Parameters:
+//   - options: The settings collected by NewAPIServer
+//
+// Returns:
+//   - http.Handler: The router
+func (server *APIServer) routes(options serverOptions) http.Handler {
+	// Create chi router - lightweight and fast HTTP router
+	router := chi.NewRouter()
+
+	// Assign request IDs first so the access log and handlers can use them
+	router.Use(requestIDMiddleware)
+	router.Use(actorMiddleware(server.cfg.AdminToken))
+	router.Use(consistencyMiddleware)
+	router.Use(tracingMiddleware)
+	router.Use(accessLogMiddleware(options.logger.With("component", "http")))
+	router.Use(options.metrics.Middleware)
+	router.Use(cacheControlMiddleware(server.cfg.CacheControl))
+	router.Use(options.middleware...)
+
+	// Register API routes
+	// All routes use makeHttpHandleFunc for consistent error handling
+	router.Post("/review", makeHttpHandleFunc(server.handleCreateReview))
+	router.Get("/review/{id}", makeHttpHandleFunc(server.handleGetReview))
+	router.Delete("/review/{id}", makeHttpHandleFunc(server.handleDeleteReview))
+	router.Put("/review/{id}", makeHttpHandleFunc(server.handleUpdateReview))
+
+	// Serve the optional routes whose store was given
+	if server.trash != nil {
+		router.Get("/trash", makeHttpHandleFunc(server.handleListTrash))
+		router.Post("/review/{id}/restore", makeHttpHandleFunc(server.handleRestoreReview))
+	}
+	if server.revisions != nil {
+		router.Get("/review/{id}/revisions", makeHttpHandleFunc(server.handleListRevisions))
+		router.Get("/review/{id}/revisions/diff", makeHttpHandleFunc(server.handleDiffRevisions))
+		router.Get("/review/{id}/revisions/{revision}", makeHttpHandleFunc(server.handleGetRevision))
+		router.Post("/review/{id}/revisions/{revision}/revert", makeHttpHandleFunc(server.handleRevertReview))
+	}
+
+	// Stream review changes when an event feed is configured
+	if server.events != nil && server.eventLog != nil {
+		router.Get("/events", makeHttpHandleFunc(server.handleEvents))
+	}
+
+	// Admin routes require the admin token and are disabled without one
+	if server.cfg.AdminToken != "" {
+		router.Group(func(admin chi.Router) {
+			admin.Use(requireAdminToken(server.cfg.AdminToken))
+			if server.audit != nil {
+				admin.Get("/admin/audit", makeHttpHandleFunc(server.handleListAudit))
+			}
+			if server.webhooks != nil {
+				admin.Get("/admin/webhooks/dead-letters", makeHttpHandleFunc(server.handleListDeadLetters))
+				admin.Post("/admin/webhooks/dead-letters/{id}/retry", makeHttpHandleFunc(server.handleRetryDeadLetter))
+			}
+		})
+	}
+
+	// Liveness and readiness probes for orchestrators and load balancers
+	router.Get("/healthz", server.handleHealthz)
+	router.Get("/readyz", server.handleReadyz)
+
+	// Expose Prometheus metrics when enabled
+	if options.metrics != nil {
+		router.Method(http.MethodGet, options.metricsPath, options.metrics.Handler())
+	}
+
+	// Routes added by the embedding program
+	for _, register := range options.routes {
+		register(router)
+	}
+	return router
+}
+
+// handleGetReview handles GET /review/{id} requests.
+// It retrieves a single review by its unique identifier.
+//
+// URL Parameters:
+//   - id: The numeric ID of the review to retrieve
+//
+// Response:
+//   - 200 OK: Returns the review as JSON, with ETag and Last-Modified set from
+//     its updatedAt
+//   - 304 Not Modified: If the review still matches If-None-Match, or has not
+//     changed since If-Modified-Since
+//   - 400 Bad Request: If the ID is invalid or the review is not found
+//   - 499/503/504: If the request is cancelled or times out (see statusForError)
+//
+// Example Request:
+//
+//	GET /review/42
+//
+// Example Response:
+//
+//	{
+//	    "id": 42,
+//	    "title": "Inception",
+//	    "director": "Christopher Nolan",
+//	    "releaseDate": "16 Jul 10 00:00",
+//	    "rating": "9/10",
+//	    "reviewNotes": "Mind-bending masterpiece",
+//	    "dateCreated": "15 Jan 26 10:30"
+//	}
+func (server *APIServer) handleGetReview(writer http.ResponseWriter, request *http.Request) error {
+	// Extract and validate the ID from URL path
+	id, err := strconv.Atoi(chi.URLParam(request, "id"))
+	if err != nil {
+		return fmt.Errorf("invalid id: %w", err)
+	}
+
+	// Fetch the review from the database
+	review, err := server.dbInstance.GetReviewById(request.Context(), id)
+	if err != nil {
+		// Keep cancellations distinct from a missing review
+		if errors.Is(err, ErrCanceled) || errors.Is(err, ErrTimeout) || errors.Is(err, ErrNotFound) {
+			return err
+		}
+		return fmt.Errorf("review not found: %w", err)
+	}
+
+	// Let caches revalidate: answer 304 if the client's copy is current
+	if checkNotModified(writer, request, review.UpdatedAt) {
+		return nil
+	}
+	return WriteJSON(writer, http.StatusOK, review)
+}
+
+// handleCreateReview handles POST /review requests.
+// It creates a new review from the JSON request body.
+//
+// Request Body:
+//   - JSON object matching CreateReviewRequest structure
+//
+// Response:
+//   - 200 OK: Returns the created review (with auto-generated ID and dateCreated)
+//   - 400 Bad Request: If the request body is invalid or database insert fails
+//   - 499/503/504: If the request is cancelled or times out (see statusForError)
+//
+// Example Request:
+//
+//	POST /review
+//	Content-Type: application/json
+//
+//	{
+//	    "title": "Inception",
+//	    "director": "Christopher Nolan",
+//	    "releaseDate": "16 Jul 10 00:00 UTC",
+//	    "rating": "9/10",
+//	    "reviewNotes": "Mind-bending masterpiece"
+//	}
+func (server *APIServer) handleCreateReview(writer http.ResponseWriter, request *http.Request) error {
+	// Parse the JSON request body into CreateReviewRequest
+	createReviewRequest := new(CreateReviewRequest)
+	if err := json.NewDecoder(request.Body).Decode(createReviewRequest); err != nil {
+		return err
+	}
+
+	// Create a new Review with the provided data and auto-generated timestamps
+	review := NewReview(
+		server.logger,
+		createReviewRequest.Title,
+		createReviewRequest.Director,
+		createReviewRequest.ReleaseDate,
+		createReviewRequest.Rating,
+		createReviewRequest.ReviewNotes,
+	)
+
+	// Persist the review to the database
+	if _, err := server.dbInstance.CreateReview(request.Context(), review); err != nil {
+		return err
+	}
+	return WriteJSON(writer, http.StatusOK, review)
+}
+
+// handleDeleteReview handles DELETE /review/{id} requests.
+// It moves a review to the trash, from where it can be restored until the
+// retention period has passed (see trash.go).
+//
+// URL Parameters:
+//   - id: The numeric ID of the review to delete
+//
+// Response:
+//   - 200 OK: Returns {"deleted": "success"}
+//   - 400 Bad Request: If the ID is invalid or the review is not found
+//   - 499/503/504: If the request is cancelled or times out (see statusForError)
+//
+// Example Request:
+//
+//	DELETE /review/42
+//
+// Example Response:
+//
+//	{"deleted": "success"}
+func (server *APIServer) handleDeleteReview(writer http.ResponseWriter, request *http.Request) error {
+	// Extract and validate the ID from URL path
+	id, err := strconv.Atoi(chi.URLParam(request, "id"))
+	if err != nil {
+		return fmt.Errorf("invalid id: %w", err)
+	}
+
+	// Delete the review from the database
+	if err := server.dbInstance.DeleteReview(request.Context(), id); err != nil {
+		return err
+	}
+	return WriteJSON(writer, http.StatusOK, map[string]string{"deleted": "success"})
+}
+
+// handleUpdateReview handles PUT /review/{id} requests.
+// It updates an existing review with the provided JSON data.
+// The ID in the URL takes precedence over any ID in the request body.
+//
+// URL Parameters:
+//   - id: The numeric ID of the review to update
+//
+// Request Body:
+//   - JSON object with review fields to update
+//
+// Response:
+//   - 200 OK: Returns the updated review
+//   - 400 Bad Request: If the ID is invalid, body is malformed, or review not found
+//   - 499/503/504: If the request is cancelled or times out (see statusForError)
+//
+// Example Request:
+//
+//	PUT /review/42
+//	Content-Type: application/json
+//
+//	{
+//	    "title": "Inception (Director's Cut)",
+//	    "director": "Christopher Nolan",
+//	    "releaseDate": "16 Jul 10 00:00",
+//	    "rating": "10/10",
+//	    "reviewNotes": "Even better on rewatch"
+//	}
+func (server *APIServer) handleUpdateReview(writer http.ResponseWriter, request *http.Request) error {
+	// Extract and validate the ID from URL path
+	id, err := strconv.Atoi(chi.URLParam(request, "id"))
+	if err != nil {
+		return fmt.Errorf("invalid id: %w", err)
+	}
+
+	// Parse the JSON request body
+	updateReview := new(Review)
+	if err := json.NewDecoder(request.Body).Decode(updateReview); err != nil {
+		return err
+	}
+
+	// Use the URL ID (overrides any ID in the body)
+	updateReview.ID = id
+
+	// Update the review in the database
+	if err := server.dbInstance.UpdateReview(request.Context(), updateReview); err != nil {
+		return err
+	}
+	return WriteJSON(writer, http.StatusOK, updateReview)
+}
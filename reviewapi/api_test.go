@@ -0,0 +1,1283 @@
+// Package reviewapi provides the HTTP handler tests for the Movie Review API.
+// This file drives the router built by NewAPIServer with httptest and a
+// scriptable fake Storage, and compares every response (status, selected
+// headers and body) with a golden file in testdata/api.
+//
+// After an intended change to a response, regenerate the golden files and
+// review the diff:
+//
+//	go test -run TestAPI -update
+package reviewapi
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// updateGolden rewrites the golden files instead of comparing against them.
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// testRequestID is sent with every request so responses are deterministic.
+const testRequestID = "test-request-id"
+
+// testUpdatedAt is the modification time the fake storage assigns.
+var testUpdatedAt = time.Date(2026, time.January, 15, 10, 30, 0, 0, time.UTC)
+
+// goldenHeaders are the response headers recorded in golden files.
+var goldenHeaders = []string{"Cache-Control", "Content-Type", "ETag", "Last-Modified", "WWW-Authenticate", consistencyTokenHeader, requestIDHeader}
+
+// testAdminToken is the admin token of the server under test.
+const testAdminToken = "test-admin-token-0123456789"
+
+// fakeStorage is a Storage, and every optional store, whose results are
+// scripted per test. Calls to a method without a script fail the test.
+type fakeStorage struct {
+	t *testing.T
+
+	create func(ctx context.Context, review *Review) (string, error)
+	update func(ctx context.Context, review *Review) error
+	delete func(ctx context.Context, id int) error
+	get    func(ctx context.Context, id int) (*Review, error)
+
+	listDeleted func(ctx context.Context, limit int) ([]*Review, error)
+	restore     func(ctx context.Context, id int) (*Review, error)
+	purge       func(ctx context.Context, cutoff time.Time) (int, error)
+
+	listRevisions func(ctx context.Context, id int) ([]*Revision, error)
+	getRevision   func(ctx context.Context, id, revision int) (*Revision, error)
+	revert        func(ctx context.Context, id, revision int) (*Review, error)
+
+	listAudit func(ctx context.Context, query AuditQuery) ([]*AuditEntry, error)
+
+	listDeadDeliveries func(ctx context.Context, limit int) ([]*WebhookDelivery, error)
+	retryDelivery      func(ctx context.Context, id int64) (*WebhookDelivery, error)
+
+	listEvents  func(ctx context.Context, afterID int64, limit int) ([]*ReviewEvent, error)
+	oldestEvent func(ctx context.Context) (int64, error)
+
+	mu    sync.Mutex
+	calls []string
+}
+
+// record notes a call for later assertions.
+func (f *fakeStorage) record(format string, args ...any) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeStorage) CreateReview(ctx context.Context, review *Review) (string, error) {
+	f.record("CreateReview(%s)", review.Title)
+	if f.create == nil {
+		f.t.Fatalf("unexpected CreateReview")
+	}
+	return f.create(ctx, review)
+}
+
+func (f *fakeStorage) UpdateReview(ctx context.Context, review *Review) error {
+	f.record("UpdateReview(%d, %s)", review.ID, review.Title)
+	if f.update == nil {
+		f.t.Fatalf("unexpected UpdateReview")
+	}
+	return f.update(ctx, review)
+}
+
+func (f *fakeStorage) DeleteReview(ctx context.Context, id int) error {
+	f.record("DeleteReview(%d)", id)
+	if f.delete == nil {
+		f.t.Fatalf("unexpected DeleteReview")
+	}
+	return f.delete(ctx, id)
+}
+
+func (f *fakeStorage) GetReviewById(ctx context.Context, id int) (*Review, error) {
+	f.record("GetReviewById(%d)", id)
+	if f.get == nil {
+		f.t.Fatalf("unexpected GetReviewById")
+	}
+	return f.get(ctx, id)
+}
+
+func (f *fakeStorage) ListDeletedReviews(ctx context.Context, limit int) ([]*Review, error) {
+	f.record("ListDeletedReviews(%d)", limit)
+	if f.listDeleted == nil {
+		f.t.Fatalf("unexpected ListDeletedReviews")
+	}
+	return f.listDeleted(ctx, limit)
+}
+
+func (f *fakeStorage) RestoreReview(ctx context.Context, id int) (*Review, error) {
+	f.record("RestoreReview(%d)", id)
+	if f.restore == nil {
+		f.t.Fatalf("unexpected RestoreReview")
+	}
+	return f.restore(ctx, id)
+}
+
+func (f *fakeStorage) PurgeDeletedReviews(ctx context.Context, cutoff time.Time) (int, error) {
+	f.record("PurgeDeletedReviews(%s)", cutoff.Format(time.RFC3339))
+	if f.purge == nil {
+		f.t.Fatalf("unexpected PurgeDeletedReviews")
+	}
+	return f.purge(ctx, cutoff)
+}
+
+func (f *fakeStorage) ListRevisions(ctx context.Context, id int) ([]*Revision, error) {
+	f.record("ListRevisions(%d)", id)
+	if f.listRevisions == nil {
+		f.t.Fatalf("unexpected ListRevisions")
+	}
+	return f.listRevisions(ctx, id)
+}
+
+func (f *fakeStorage) GetRevision(ctx context.Context, id, revision int) (*Revision, error) {
+	f.record("GetRevision(%d, %d)", id, revision)
+	if f.getRevision == nil {
+		f.t.Fatalf("unexpected GetRevision")
+	}
+	return f.getRevision(ctx, id, revision)
+}
+
+func (f *fakeStorage) RevertReview(ctx context.Context, id, revision int) (*Review, error) {
+	f.record("RevertReview(%d, %d)", id, revision)
+	if f.revert == nil {
+		f.t.Fatalf("unexpected RevertReview")
+	}
+	return f.revert(ctx, id, revision)
+}
+
+func (f *fakeStorage) ListAuditEntries(ctx context.Context, query AuditQuery) ([]*AuditEntry, error) {
+	f.record("ListAuditEntries(actor=%s action=%s since=%s until=%s before=%d limit=%d)", query.Actor, query.Action,
+		query.Since.Format(time.RFC3339), query.Until.Format(time.RFC3339), query.BeforeID, query.Limit)
+	if f.listAudit == nil {
+		f.t.Fatalf("unexpected ListAuditEntries")
+	}
+	return f.listAudit(ctx, query)
+}
+
+func (f *fakeStorage) ListDeadWebhookDeliveries(ctx context.Context, limit int) ([]*WebhookDelivery, error) {
+	f.record("ListDeadWebhookDeliveries(%d)", limit)
+	if f.listDeadDeliveries == nil {
+		f.t.Fatalf("unexpected ListDeadWebhookDeliveries")
+	}
+	return f.listDeadDeliveries(ctx, limit)
+}
+
+func (f *fakeStorage) RetryWebhookDelivery(ctx context.Context, id int64) (*WebhookDelivery, error) {
+	f.record("RetryWebhookDelivery(%d)", id)
+	if f.retryDelivery == nil {
+		f.t.Fatalf("unexpected RetryWebhookDelivery")
+	}
+	return f.retryDelivery(ctx, id)
+}
+
+func (f *fakeStorage) ListReviewEvents(ctx context.Context, afterID int64, limit int) ([]*ReviewEvent, error) {
+	f.record("ListReviewEvents(%d, %d)", afterID, limit)
+	if f.listEvents == nil {
+		f.t.Fatalf("unexpected ListReviewEvents")
+	}
+	return f.listEvents(ctx, afterID, limit)
+}
+
+func (f *fakeStorage) OldestReviewEventID(ctx context.Context) (int64, error) {
+	f.record("OldestReviewEventID()")
+	if f.oldestEvent == nil {
+		f.t.Fatalf("unexpected OldestReviewEventID")
+	}
+	return f.oldestEvent(ctx)
+}
+
+// readinessFunc adapts a function to ReadinessChecker.
+type readinessFunc func(ctx context.Context) error
+
+func (f readinessFunc) CheckReady(ctx context.Context) error { return f(ctx) }
+
+// storedReview returns the review the fake storage holds under id.
+func storedReview(id int) *Review {
+	return &Review{
+		ID:          id,
+		Title:       "Inception",
+		Director:    "Christopher Nolan",
+		ReleaseDate: "16 Jul 10 00:00 UTC",
+		Rating:      "9/10",
+		ReviewNotes: "A mind-bending masterpiece",
+		DateCreated: "15 Jan 26 10:30 UTC",
+		UpdatedAt:   testUpdatedAt,
+	}
+}
+
+// deletedReview returns the review the fake storage holds in the trash under id.
+func deletedReview(id int) *Review {
+	review := storedReview(id)
+	deletedAt := testUpdatedAt.Add(time.Hour)
+	review.DeletedAt = &deletedAt
+	review.UpdatedAt = deletedAt
+	return review
+}
+
+// storedRevisions returns the history the fake storage holds for the review
+// under id: its creation, then a rating change by alice.
+func storedRevisions(id int) []*Revision {
+	first := &Revision{
+		ReviewID:      id,
+		Revision:      1,
+		Author:        AnonymousActor,
+		CreatedAt:     testUpdatedAt.Add(-24 * time.Hour),
+		ChangedFields: ReviewFields,
+		Title:         "Inception",
+		Director:      "Christopher Nolan",
+		ReleaseDate:   "16 Jul 10 00:00 UTC",
+		Rating:        "8/10",
+		ReviewNotes:   "A mind-bending masterpiece",
+	}
+	second := *first
+	second.Revision = 2
+	second.Author = "alice"
+	second.CreatedAt = testUpdatedAt
+	second.ChangedFields = []string{"rating"}
+	second.Rating = "9/10"
+	return []*Revision{&second, first}
+}
+
+// getStoredRevision scripts GetRevision from storedRevisions.
+func getStoredRevision(_ context.Context, id, revision int) (*Revision, error) {
+	revisions := storedRevisions(id)
+	if revision < 1 || revision > len(revisions) {
+		return nil, fmt.Errorf("revision %d of review with id %d %w", revision, id, ErrNotFound)
+	}
+	return revisions[len(revisions)-revision], nil
+}
+
+// revertToStoredRevision reverts the stored review with the given ID to one
+// of its stored revisions.
+func revertToStoredRevision(ctx context.Context, id, revision int) (*Review, error) {
+	target, err := getStoredRevision(ctx, id, revision)
+	if err != nil {
+		return nil, err
+	}
+	review := storedReview(id)
+	review.Title = target.Title
+	review.Director = target.Director
+	review.ReleaseDate = target.ReleaseDate
+	review.Rating = target.Rating
+	review.ReviewNotes = target.ReviewNotes
+	return review, nil
+}
+
+// storedAuditEntries returns the audit log the fake storage holds: the
+// creation of review 42, then a rating change by alice.
+func storedAuditEntries() []*AuditEntry {
+	before := storedReview(42)
+	before.Rating = "8/10"
+	before.UpdatedAt = testUpdatedAt.Add(-24 * time.Hour)
+	beforeJSON, _ := json.Marshal(before)
+	afterJSON, _ := json.Marshal(storedReview(42))
+	return []*AuditEntry{
+		{
+			ID:         2,
+			OccurredAt: testUpdatedAt,
+			Actor:      "alice",
+			Action:     AuditUpdate,
+			ReviewID:   42,
+			Before:     beforeJSON,
+			After:      afterJSON,
+			RequestID:  "update-request-id",
+			ClientIP:   "203.0.113.7",
+		},
+		{
+			ID:           1,
+			OccurredAt:   testUpdatedAt.Add(-24 * time.Hour),
+			Actor:        AnonymousActor,
+			Action:       AuditCreate,
+			ReviewID:     42,
+			After:        beforeJSON,
+			RequestID:    "create-request-id",
+			ClientIP:     "203.0.113.7",
+			ClaimedActor: "mallory",
+		},
+	}
+}
+
+// storedDeadDelivery returns the dead delivery the fake storage holds: the
+// creation of review 42, which the "search" webhook kept rejecting.
+func storedDeadDelivery() *WebhookDelivery {
+	reviewJSON, _ := json.Marshal(storedReview(42))
+	return &WebhookDelivery{
+		ID:            17,
+		Webhook:       "search",
+		Status:        DeliveryDead,
+		Attempts:      10,
+		NextAttemptAt: testUpdatedAt,
+		LastError:     "webhook responded 500 Internal Server Error",
+		UpdatedAt:     testUpdatedAt,
+		Event: ReviewEvent{
+			ID:         5,
+			Type:       EventReviewCreated,
+			OccurredAt: testUpdatedAt.Add(-5 * time.Hour),
+			ReviewID:   42,
+			Review:     reviewJSON,
+		},
+	}
+}
+
+// createdOK scripts CreateReview to succeed with ID 42.
+func createdOK(ctx context.Context, review *Review) (string, error) {
+	review.ID = 42
+	review.UpdatedAt = testUpdatedAt
+	return "Review Created", nil
+}
+
+// failWith returns Storage scripts failing with err.
+func failWith(err error) (func(context.Context, *Review) (string, error), func(context.Context, int) (*Review, error)) {
+	return func(context.Context, *Review) (string, error) { return "", err },
+		func(context.Context, int) (*Review, error) { return nil, err }
+}
+
+// notFound is the error the backends return for a missing review.
+func notFound(id int) error {
+	return fmt.Errorf("review with id %d %w", id, ErrNotFound)
+}
+
+// apiTestCase is one request against the router and its expectations.
+type apiTestCase struct {
+	// name is also the golden file name.
+	name    string
+	method  string
+	path    string
+	body    string
+	headers map[string]string
+
+	// script sets up the fake storage (nil: no storage call expected).
+	script func(f *fakeStorage)
+
+	// readiness replaces the default always-ready checker.
+	readiness ReadinessChecker
+
+	// shuttingDown marks the server as draining.
+	shuttingDown bool
+
+	// calls are the expected storage calls, in order.
+	calls []string
+}
+
+// apiTestCases covers every route and its error paths.
+var apiTestCases = []apiTestCase{
+	// POST /review
+	{
+		name:   "create_ok",
+		method: http.MethodPost,
+		path:   "/review",
+		body:   `{"title":"Inception","director":"Christopher Nolan","releaseDate":"16 Jul 10 00:00 UTC","rating":"9/10","reviewNotes":"A mind-bending masterpiece"}`,
+		script: func(f *fakeStorage) { f.create = createdOK },
+		calls:  []string{"CreateReview(Inception)"},
+	},
+	{
+		name:   "create_malformed_body",
+		method: http.MethodPost,
+		path:   "/review",
+		body:   `{"title":`,
+	},
+	{
+		name:   "create_storage_error",
+		method: http.MethodPost,
+		path:   "/review",
+		body:   `{"title":"Inception","releaseDate":"16 Jul 10 00:00 UTC"}`,
+		script: func(f *fakeStorage) { f.create, _ = failWith(errors.New("failed to create review: disk full")) },
+		calls:  []string{"CreateReview(Inception)"},
+	},
+	{
+		name:   "create_timeout",
+		method: http.MethodPost,
+		path:   "/review",
+		body:   `{"title":"Inception","releaseDate":"16 Jul 10 00:00 UTC"}`,
+		script: func(f *fakeStorage) { f.create, _ = failWith(fmt.Errorf("failed to create review: %w", ErrTimeout)) },
+		calls:  []string{"CreateReview(Inception)"},
+	},
+	{
+		name:   "create_canceled",
+		method: http.MethodPost,
+		path:   "/review",
+		body:   `{"title":"Inception","releaseDate":"16 Jul 10 00:00 UTC"}`,
+		script: func(f *fakeStorage) { f.create, _ = failWith(fmt.Errorf("failed to create review: %w", ErrCanceled)) },
+		calls:  []string{"CreateReview(Inception)"},
+	},
+	{
+		name:   "create_not_ready",
+		method: http.MethodPost,
+		path:   "/review",
+		body:   `{"title":"Inception","releaseDate":"16 Jul 10 00:00 UTC"}`,
+		script: func(f *fakeStorage) { f.create, _ = failWith(ErrNotReady) },
+		calls:  []string{"CreateReview(Inception)"},
+	},
+	{
+		name:   "create_circuit_open",
+		method: http.MethodPost,
+		path:   "/review",
+		body:   `{"title":"Inception","releaseDate":"16 Jul 10 00:00 UTC"}`,
+		script: func(f *fakeStorage) { f.create, _ = failWith(fmt.Errorf("CreateReview: %w", ErrCircuitOpen)) },
+		calls:  []string{"CreateReview(Inception)"},
+	},
+
+	// GET /review/{id}
+	{
+		name:   "get_ok",
+		method: http.MethodGet,
+		path:   "/review/42",
+		script: func(f *fakeStorage) {
+			f.get = func(context.Context, int) (*Review, error) { return storedReview(42), nil }
+		},
+		calls: []string{"GetReviewById(42)"},
+	},
+	{
+		name:    "get_not_modified",
+		method:  http.MethodGet,
+		path:    "/review/42",
+		headers: map[string]string{"If-Modified-Since": testUpdatedAt.Format(http.TimeFormat)},
+		script: func(f *fakeStorage) {
+			f.get = func(context.Context, int) (*Review, error) { return storedReview(42), nil }
+		},
+		calls: []string{"GetReviewById(42)"},
+	},
+	{
+		name:    "get_modified_since",
+		method:  http.MethodGet,
+		path:    "/review/42",
+		headers: map[string]string{"If-Modified-Since": testUpdatedAt.Add(-time.Hour).Format(http.TimeFormat)},
+		script: func(f *fakeStorage) {
+			f.get = func(context.Context, int) (*Review, error) { return storedReview(42), nil }
+		},
+		calls: []string{"GetReviewById(42)"},
+	},
+	{
+		// Changed later within the second of the client's Last-Modified
+		name:    "get_modified_same_second",
+		method:  http.MethodGet,
+		path:    "/review/42",
+		headers: map[string]string{"If-Modified-Since": testUpdatedAt.Format(http.TimeFormat)},
+		script: func(f *fakeStorage) {
+			f.get = func(context.Context, int) (*Review, error) {
+				review := storedReview(42)
+				review.UpdatedAt = testUpdatedAt.Add(500 * time.Millisecond)
+				return review, nil
+			}
+		},
+		calls: []string{"GetReviewById(42)"},
+	},
+	{
+		name:    "get_etag_not_modified",
+		method:  http.MethodGet,
+		path:    "/review/42",
+		headers: map[string]string{"If-None-Match": `"1", ` + entityTag(testUpdatedAt)},
+		script: func(f *fakeStorage) {
+			f.get = func(context.Context, int) (*Review, error) { return storedReview(42), nil }
+		},
+		calls: []string{"GetReviewById(42)"},
+	},
+	{
+		// If-None-Match wins over an If-Modified-Since that would match
+		name:   "get_etag_modified",
+		method: http.MethodGet,
+		path:   "/review/42",
+		headers: map[string]string{
+			"If-None-Match":     entityTag(testUpdatedAt.Add(-time.Second)),
+			"If-Modified-Since": testUpdatedAt.Format(http.TimeFormat),
+		},
+		script: func(f *fakeStorage) {
+			f.get = func(context.Context, int) (*Review, error) { return storedReview(42), nil }
+		},
+		calls: []string{"GetReviewById(42)"},
+	},
+	{
+		name:   "get_invalid_id",
+		method: http.MethodGet,
+		path:   "/review/abc",
+	},
+	{
+		name:   "get_not_found",
+		method: http.MethodGet,
+		path:   "/review/7",
+		script: func(f *fakeStorage) { _, f.get = failWith(notFound(7)) },
+		calls:  []string{"GetReviewById(7)"},
+	},
+	{
+		name:   "get_timeout",
+		method: http.MethodGet,
+		path:   "/review/42",
+		script: func(f *fakeStorage) { _, f.get = failWith(fmt.Errorf("failed to get review: %w", ErrTimeout)) },
+		calls:  []string{"GetReviewById(42)"},
+	},
+	{
+		name:   "get_not_ready",
+		method: http.MethodGet,
+		path:   "/review/42",
+		script: func(f *fakeStorage) { _, f.get = failWith(ErrNotReady) },
+		calls:  []string{"GetReviewById(42)"},
+	},
+
+	// PUT /review/{id}
+	{
+		name:   "update_ok",
+		method: http.MethodPut,
+		path:   "/review/42",
+		body:   `{"id":999,"title":"Inception (Director's Cut)","director":"Christopher Nolan","releaseDate":"16 Jul 10 00:00 UTC","rating":"10/10","reviewNotes":"Even better on rewatch"}`,
+		script: func(f *fakeStorage) {
+			f.update = func(_ context.Context, review *Review) error {
+				review.UpdatedAt = testUpdatedAt
+				return nil
+			}
+		},
+		// The ID in the URL wins over the one in the body
+		calls: []string{"UpdateReview(42, Inception (Director's Cut))"},
+	},
+	{
+		name:   "update_invalid_id",
+		method: http.MethodPut,
+		path:   "/review/abc",
+		body:   `{"title":"Inception"}`,
+	},
+	{
+		name:   "update_malformed_body",
+		method: http.MethodPut,
+		path:   "/review/42",
+		body:   `not json`,
+	},
+	{
+		name:   "update_not_found",
+		method: http.MethodPut,
+		path:   "/review/7",
+		body:   `{"title":"Inception"}`,
+		script: func(f *fakeStorage) { f.update = func(context.Context, *Review) error { return notFound(7) } },
+		calls:  []string{"UpdateReview(7, Inception)"},
+	},
+
+	// DELETE /review/{id}
+	{
+		name:   "delete_ok",
+		method: http.MethodDelete,
+		path:   "/review/42",
+		script: func(f *fakeStorage) { f.delete = func(context.Context, int) error { return nil } },
+		calls:  []string{"DeleteReview(42)"},
+	},
+	{
+		name:   "delete_invalid_id",
+		method: http.MethodDelete,
+		path:   "/review/abc",
+	},
+	{
+		name:   "delete_not_found",
+		method: http.MethodDelete,
+		path:   "/review/7",
+		script: func(f *fakeStorage) { f.delete = func(context.Context, int) error { return notFound(7) } },
+		calls:  []string{"DeleteReview(7)"},
+	},
+	{
+		name:   "delete_canceled",
+		method: http.MethodDelete,
+		path:   "/review/42",
+		script: func(f *fakeStorage) {
+			f.delete = func(context.Context, int) error { return fmt.Errorf("failed to delete review: %w", ErrCanceled) }
+		},
+		calls: []string{"DeleteReview(42)"},
+	},
+
+	// GET /trash
+	{
+		name:   "trash_ok",
+		method: http.MethodGet,
+		path:   "/trash",
+		script: func(f *fakeStorage) {
+			f.listDeleted = func(context.Context, int) ([]*Review, error) {
+				return []*Review{deletedReview(42), deletedReview(7)}, nil
+			}
+		},
+		calls: []string{"ListDeletedReviews(100)"},
+	},
+	{
+		name:   "trash_empty",
+		method: http.MethodGet,
+		path:   "/trash?limit=5",
+		script: func(f *fakeStorage) {
+			f.listDeleted = func(context.Context, int) ([]*Review, error) { return []*Review{}, nil }
+		},
+		calls: []string{"ListDeletedReviews(5)"},
+	},
+	{
+		name:   "trash_invalid_limit",
+		method: http.MethodGet,
+		path:   "/trash?limit=5000",
+	},
+
+	// POST /review/{id}/restore
+	{
+		name:   "restore_ok",
+		method: http.MethodPost,
+		path:   "/review/42/restore",
+		script: func(f *fakeStorage) {
+			f.restore = func(context.Context, int) (*Review, error) { return storedReview(42), nil }
+		},
+		calls: []string{"RestoreReview(42)"},
+	},
+	{
+		name:   "restore_invalid_id",
+		method: http.MethodPost,
+		path:   "/review/abc/restore",
+	},
+	{
+		name:   "restore_not_found",
+		method: http.MethodPost,
+		path:   "/review/7/restore",
+		script: func(f *fakeStorage) {
+			f.restore = func(context.Context, int) (*Review, error) {
+				return nil, fmt.Errorf("deleted review with id %d %w", 7, ErrNotFound)
+			}
+		},
+		calls: []string{"RestoreReview(7)"},
+	},
+
+	// Revision history
+	{
+		name:   "revisions_ok",
+		method: http.MethodGet,
+		path:   "/review/42/revisions",
+		script: func(f *fakeStorage) {
+			f.listRevisions = func(_ context.Context, id int) ([]*Revision, error) { return storedRevisions(id), nil }
+		},
+		calls: []string{"ListRevisions(42)"},
+	},
+	{
+		name:   "revisions_not_found",
+		method: http.MethodGet,
+		path:   "/review/7/revisions",
+		script: func(f *fakeStorage) {
+			f.listRevisions = func(context.Context, int) ([]*Revision, error) { return nil, notFound(7) }
+		},
+		calls: []string{"ListRevisions(7)"},
+	},
+	{
+		name:   "revision_ok",
+		method: http.MethodGet,
+		path:   "/review/42/revisions/1",
+		script: func(f *fakeStorage) { f.getRevision = getStoredRevision },
+		calls:  []string{"GetRevision(42, 1)"},
+	},
+	{
+		name:   "revision_invalid",
+		method: http.MethodGet,
+		path:   "/review/42/revisions/0",
+	},
+	{
+		name:   "revision_not_found",
+		method: http.MethodGet,
+		path:   "/review/42/revisions/9",
+		script: func(f *fakeStorage) { f.getRevision = getStoredRevision },
+		calls:  []string{"GetRevision(42, 9)"},
+	},
+	{
+		name:   "revisions_diff_ok",
+		method: http.MethodGet,
+		path:   "/review/42/revisions/diff?from=1&to=2",
+		script: func(f *fakeStorage) { f.getRevision = getStoredRevision },
+		calls:  []string{"GetRevision(42, 1)", "GetRevision(42, 2)"},
+	},
+	{
+		name:   "revisions_diff_missing_to",
+		method: http.MethodGet,
+		path:   "/review/42/revisions/diff?from=1",
+	},
+	{
+		name:    "revert_ok",
+		method:  http.MethodPost,
+		path:    "/review/42/revisions/1/revert",
+		headers: map[string]string{actorHeader: "bob", "Authorization": "Bearer " + testAdminToken},
+		script: func(f *fakeStorage) {
+			f.revert = func(ctx context.Context, id, revision int) (*Review, error) {
+				// Only the admin token authenticates; X-Actor is a claim
+				if actor, claimed := ActorFromContext(ctx), ClaimedActorFromContext(ctx); actor != AdminActor || claimed != "bob" {
+					return nil, fmt.Errorf("revert by %q claiming %q, want admin claiming bob", actor, claimed)
+				}
+				return revertToStoredRevision(ctx, id, revision)
+			}
+		},
+		calls: []string{"RevertReview(42, 1)"},
+	},
+	{
+		name:   "revert_not_found",
+		method: http.MethodPost,
+		path:   "/review/42/revisions/9/revert",
+		script: func(f *fakeStorage) { f.revert = revertToStoredRevision },
+		calls:  []string{"RevertReview(42, 9)"},
+	},
+
+	// Audit log
+	{
+		name:    "audit_ok",
+		method:  http.MethodGet,
+		path:    "/admin/audit?actor=alice&since=2026-01-01T00:00:00Z&until=2026-02-01T00:00:00Z&before=3&limit=10",
+		headers: map[string]string{"Authorization": "Bearer " + testAdminToken},
+		script: func(f *fakeStorage) {
+			f.listAudit = func(context.Context, AuditQuery) ([]*AuditEntry, error) { return storedAuditEntries(), nil }
+		},
+		calls: []string{"ListAuditEntries(actor=alice action= since=2026-01-01T00:00:00Z until=2026-02-01T00:00:00Z before=3 limit=10)"},
+	},
+	{
+		name:    "audit_empty",
+		method:  http.MethodGet,
+		path:    "/admin/audit?action=purge",
+		headers: map[string]string{"Authorization": "Bearer " + testAdminToken},
+		script: func(f *fakeStorage) {
+			f.listAudit = func(context.Context, AuditQuery) ([]*AuditEntry, error) { return []*AuditEntry{}, nil }
+		},
+		calls: []string{"ListAuditEntries(actor= action=purge since=0001-01-01T00:00:00Z until=0001-01-01T00:00:00Z before=0 limit=100)"},
+	},
+	{
+		name:   "audit_unauthorized",
+		method: http.MethodGet,
+		path:   "/admin/audit",
+	},
+	{
+		name:    "audit_wrong_token",
+		method:  http.MethodGet,
+		path:    "/admin/audit",
+		headers: map[string]string{"Authorization": "Bearer wrong-token"},
+	},
+	{
+		name:    "audit_invalid_action",
+		method:  http.MethodGet,
+		path:    "/admin/audit?action=rename",
+		headers: map[string]string{"Authorization": "Bearer " + testAdminToken},
+	},
+	{
+		name:    "audit_invalid_since",
+		method:  http.MethodGet,
+		path:    "/admin/audit?since=yesterday",
+		headers: map[string]string{"Authorization": "Bearer " + testAdminToken},
+	},
+
+	// Webhook dead letters
+	{
+		name:    "dead_letters_ok",
+		method:  http.MethodGet,
+		path:    "/admin/webhooks/dead-letters?limit=20",
+		headers: map[string]string{"Authorization": "Bearer " + testAdminToken},
+		script: func(f *fakeStorage) {
+			f.listDeadDeliveries = func(context.Context, int) ([]*WebhookDelivery, error) {
+				return []*WebhookDelivery{storedDeadDelivery()}, nil
+			}
+		},
+		calls: []string{"ListDeadWebhookDeliveries(20)"},
+	},
+	{
+		name:    "dead_letters_invalid_limit",
+		method:  http.MethodGet,
+		path:    "/admin/webhooks/dead-letters?limit=0",
+		headers: map[string]string{"Authorization": "Bearer " + testAdminToken},
+	},
+	{
+		name:    "dead_letter_retry_ok",
+		method:  http.MethodPost,
+		path:    "/admin/webhooks/dead-letters/17/retry",
+		headers: map[string]string{"Authorization": "Bearer " + testAdminToken},
+		script: func(f *fakeStorage) {
+			f.retryDelivery = func(context.Context, int64) (*WebhookDelivery, error) {
+				delivery := storedDeadDelivery()
+				delivery.Status = DeliveryPending
+				delivery.Attempts = 0
+				return delivery, nil
+			}
+		},
+		calls: []string{"RetryWebhookDelivery(17)"},
+	},
+	{
+		name:    "dead_letter_retry_invalid_id",
+		method:  http.MethodPost,
+		path:    "/admin/webhooks/dead-letters/abc/retry",
+		headers: map[string]string{"Authorization": "Bearer " + testAdminToken},
+	},
+	{
+		name:    "dead_letter_retry_not_found",
+		method:  http.MethodPost,
+		path:    "/admin/webhooks/dead-letters/18/retry",
+		headers: map[string]string{"Authorization": "Bearer " + testAdminToken},
+		script: func(f *fakeStorage) {
+			f.retryDelivery = func(context.Context, int64) (*WebhookDelivery, error) {
+				return nil, fmt.Errorf("dead webhook delivery with id %d %w", 18, ErrNotFound)
+			}
+		},
+		calls: []string{"RetryWebhookDelivery(18)"},
+	},
+	{
+		name:   "dead_letter_retry_unauthorized",
+		method: http.MethodPost,
+		path:   "/admin/webhooks/dead-letters/17/retry",
+	},
+
+	// GET /events (streams are covered by TestAPIEvents)
+	{
+		name:   "events_invalid_review",
+		method: http.MethodGet,
+		path:   "/events?review=abc",
+	},
+	{
+		name:    "events_invalid_last_event_id",
+		method:  http.MethodGet,
+		path:    "/events",
+		headers: map[string]string{"Last-Event-ID": "latest"},
+	},
+	{
+		name:    "events_replay_timeout",
+		method:  http.MethodGet,
+		path:    "/events",
+		headers: map[string]string{"Last-Event-ID": "311"},
+		script: func(f *fakeStorage) {
+			f.listEvents = func(context.Context, int64, int) ([]*ReviewEvent, error) {
+				return nil, fmt.Errorf("failed to list review events: %w", ErrTimeout)
+			}
+		},
+		calls: []string{"ListReviewEvents(311, 500)"},
+	},
+
+	// Health probes
+	{
+		name:   "healthz",
+		method: http.MethodGet,
+		path:   "/healthz",
+	},
+	{
+		name:   "readyz_ready",
+		method: http.MethodGet,
+		path:   "/readyz",
+	},
+	{
+		name:      "readyz_not_ready",
+		method:    http.MethodGet,
+		path:      "/readyz",
+		readiness: readinessFunc(func(context.Context) error { return fmt.Errorf("database connecting: %w", ErrNotReady) }),
+	},
+	{
+		name:         "readyz_shutting_down",
+		method:       http.MethodGet,
+		path:         "/readyz",
+		shuttingDown: true,
+	},
+
+	// Routing errors and client supplied headers
+	{
+		name:   "unknown_route",
+		method: http.MethodGet,
+		path:   "/reviews",
+	},
+	{
+		name:   "method_not_allowed",
+		method: http.MethodPatch,
+		path:   "/review/42",
+	},
+	{
+		name:    "consistency_token_echoed",
+		method:  http.MethodGet,
+		path:    "/healthz",
+		headers: map[string]string{consistencyTokenHeader: "0/16B3748"},
+	},
+}
+
+// newTestAPI returns the router of an APIServer serving every route from
+// storage.
+func newTestAPI(storage *fakeStorage, readiness ReadinessChecker, metrics *Metrics) (*APIServer, http.Handler) {
+	cfg := DefaultConfig().Server
+	cfg.AdminToken = testAdminToken
+	server := NewAPIServer(cfg, storage,
+		WithLogger(discardLogger()),
+		WithReadiness(readiness),
+		WithMetrics(metrics, "/metrics"),
+		WithTrash(storage),
+		WithRevisions(storage),
+		WithAudit(storage),
+		WithWebhookAdmin(storage),
+		WithEvents(NewEventBroadcaster(16, nil), storage, time.Minute))
+	return server, server.Handler()
+}
+
+// TestAPI runs every case in apiTestCases against its golden file.
+func TestAPI(t *testing.T) {
+	for _, tc := range apiTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			storage := &fakeStorage{t: t}
+			if tc.script != nil {
+				tc.script(storage)
+			}
+			readiness := tc.readiness
+			if readiness == nil {
+				readiness = readinessFunc(func(context.Context) error { return nil })
+			}
+			server, handler := newTestAPI(storage, readiness, nil)
+			server.shuttingDown.Store(tc.shuttingDown)
+
+			request := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			request.Header.Set(requestIDHeader, testRequestID)
+			for name, value := range tc.headers {
+				request.Header.Set(name, value)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			checkGolden(t, tc.name, formatExchange(tc, recorder))
+			if strings.Join(storage.calls, "\n") != strings.Join(tc.calls, "\n") {
+				t.Errorf("storage calls:\n got  %q\n want %q", storage.calls, tc.calls)
+			}
+		})
+	}
+}
+
+// TestAPIMetrics checks that the metrics endpoint is served only when
+// metrics are enabled and that requests are counted by route pattern.
+func TestAPIMetrics(t *testing.T) {
+	storage := &fakeStorage{t: t}
+	storage.get = func(context.Context, int) (*Review, error) { return storedReview(42), nil }
+
+	_, handler := newTestAPI(storage, nil, nil)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("metrics disabled: got status %d, want 404", recorder.Code)
+	}
+
+	_, handler = newTestAPI(storage, nil, NewMetrics())
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/review/42", nil))
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("metrics enabled: got status %d, want 200", recorder.Code)
+	}
+	want := `http_requests_total{method="GET",route="/review/{id}",status="200"} 1`
+	if !strings.Contains(recorder.Body.String(), want) {
+		t.Fatalf("metrics output lacks %s", want)
+	}
+}
+
+// TestAPIAdminDisabled checks that the admin routes are not served without
+// an admin token.
+func TestAPIAdminDisabled(t *testing.T) {
+	handler := NewHandler(DefaultConfig().Server, &fakeStorage{t: t}, WithLogger(discardLogger()))
+	request := httptest.NewRequest(http.MethodGet, "/admin/audit", nil)
+	request.Header.Set("Authorization", "Bearer ")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("got status %d, want 404", recorder.Code)
+	}
+}
+
+// TestAPIOptionalStores checks that the routes of the optional stores are
+// not served when their store is not given.
+func TestAPIOptionalStores(t *testing.T) {
+	cfg := DefaultConfig().Server
+	cfg.AdminToken = testAdminToken
+	handler := NewHandler(cfg, &fakeStorage{t: t}, WithLogger(discardLogger()))
+	for _, tc := range []struct{ method, path string }{
+		{http.MethodGet, "/trash"},
+		{http.MethodPost, "/review/42/restore"},
+		{http.MethodGet, "/review/42/revisions"},
+		{http.MethodPost, "/review/42/revisions/1/revert"},
+		{http.MethodGet, "/admin/audit"},
+		{http.MethodGet, "/admin/webhooks/dead-letters"},
+		{http.MethodGet, "/events"},
+	} {
+		request := httptest.NewRequest(tc.method, tc.path, nil)
+		request.Header.Set("Authorization", "Bearer "+testAdminToken)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		if recorder.Code != http.StatusNotFound {
+			t.Errorf("%s %s: got status %d, want 404", tc.method, tc.path, recorder.Code)
+		}
+	}
+}
+
+// TestAPIServerOptions checks extra routes and middleware, mounted under a
+// prefix inside another server's mux.
+func TestAPIServerOptions(t *testing.T) {
+	var seen []string
+	handler := NewHandler(DefaultConfig().Server, &fakeStorage{t: t},
+		WithLogger(discardLogger()),
+		WithMiddleware(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				// Built-in middleware has already run
+				seen = append(seen, request.URL.Path+" "+RequestIDFromContext(request.Context()))
+				next.ServeHTTP(writer, request)
+			})
+		}),
+		WithRoutes(func(r chi.Router) {
+			r.Get("/version", func(writer http.ResponseWriter, request *http.Request) {
+				WriteJSON(writer, http.StatusOK, map[string]string{"version": "test"})
+			})
+		}))
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/", http.StripPrefix("/api", handler))
+
+	for _, tc := range []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/api/version", http.StatusOK, `{"version":"test"}`},
+		{"/api/healthz", http.StatusOK, `{"status":"ok"}`},
+	} {
+		request := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		request.Header.Set(requestIDHeader, testRequestID)
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, request)
+		if recorder.Code != tc.status || strings.TrimSpace(recorder.Body.String()) != tc.body {
+			t.Errorf("GET %s: got %d %s, want %d %s", tc.path, recorder.Code, recorder.Body, tc.status, tc.body)
+		}
+	}
+
+	want := []string{"/version " + testRequestID, "/healthz " + testRequestID}
+	if strings.Join(seen, ",") != strings.Join(want, ",") {
+		t.Errorf("middleware saw %q, want %q", seen, want)
+	}
+}
+
+// TestAPIServerLifecycle starts a server on a free port, serves a request
+// and shuts it down, checking that /readyz fails while draining.
+func TestAPIServerLifecycle(t *testing.T) {
+	cfg := DefaultConfig().Server
+	cfg.Addr = "127.0.0.1:0"
+	cfg.DrainDelay = 200 * time.Millisecond
+	server := NewAPIServer(cfg, &fakeStorage{t: t}, WithLogger(discardLogger()))
+
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	url := "http://" + server.Addr().String()
+	if status := getStatus(t, url+"/readyz"); status != http.StatusOK {
+		t.Fatalf("readyz before shutdown: got %d, want 200", status)
+	}
+
+	shutdownErr := make(chan error, 1)
+	go func() { shutdownErr <- server.Shutdown(context.Background()) }()
+
+	// Still serving during the drain delay, but reporting not ready
+	time.Sleep(cfg.DrainDelay / 4)
+	if status := getStatus(t, url+"/readyz"); status != http.StatusServiceUnavailable {
+		t.Fatalf("readyz while draining: got %d, want 503", status)
+	}
+
+	if err := <-shutdownErr; err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if _, err := http.Get(url + "/healthz"); err == nil {
+		t.Fatalf("server still serving after Shutdown")
+	}
+	select {
+	case err := <-server.Err():
+		t.Fatalf("Err after clean shutdown: %v", err)
+	default:
+	}
+}
+
+// testReviewEvent returns a review.updated event for a review of director.
+func testReviewEvent(id int64, reviewID int, director string) *ReviewEvent {
+	return &ReviewEvent{
+		ID:         id,
+		Type:       EventReviewUpdated,
+		OccurredAt: testUpdatedAt,
+		ReviewID:   reviewID,
+		Review:     json.RawMessage(fmt.Sprintf(`{"id":%d,"director":%q}`, reviewID, director)),
+	}
+}
+
+// openEventStream sends GET path with Last-Event-ID to handler and returns
+// a function reading the next event of the stream, as its fields without
+// the data, and the data.
+func openEventStream(t *testing.T, handler http.Handler, path, lastEventID string) (readEvent func() (fields, data string)) {
+	t.Helper()
+	httpServer := httptest.NewServer(handler)
+	t.Cleanup(httpServer.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, httpServer.URL+path, nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	request.Header.Set("Last-Event-ID", lastEventID)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	t.Cleanup(func() { response.Body.Close() })
+	if response.StatusCode != http.StatusOK || response.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("GET %s: %d %s, want a 200 event stream", path, response.StatusCode, response.Header.Get("Content-Type"))
+	}
+
+	reader := bufio.NewReader(response.Body)
+	return func() (string, string) {
+		t.Helper()
+		var lines []string
+		var data string
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("read event stream: %v", err)
+			}
+			line = strings.TrimSuffix(line, "\n")
+			if line == "" {
+				return strings.Join(lines, " "), data
+			}
+			if value, ok := strings.CutPrefix(line, "data: "); ok {
+				data = value
+			} else {
+				lines = append(lines, line)
+			}
+		}
+	}
+}
+
+// TestAPIEvents checks that GET /events replays the events after
+// Last-Event-ID, then streams the events published to the broadcaster,
+// skipping the ones already replayed and the ones the filter rejects.
+func TestAPIEvents(t *testing.T) {
+	storage := &fakeStorage{t: t}
+	storage.listEvents = func(_ context.Context, afterID int64, _ int) ([]*ReviewEvent, error) {
+		if afterID != 3 {
+			return nil, nil
+		}
+		return []*ReviewEvent{
+			testReviewEvent(4, 42, "Christopher Nolan"),
+			testReviewEvent(5, 7, "Agnès Varda"),
+		}, nil
+	}
+	storage.oldestEvent = func(context.Context) (int64, error) { return 1, nil }
+	server, handler := newTestAPI(storage, nil, nil)
+	stream := openEventStream(t, handler, "/events?director=christopher%20nolan", "3")
+	readEvent := func() string {
+		t.Helper()
+		fields, _ := stream()
+		return fields
+	}
+
+	if got, want := readEvent(), "id: 4 event: review.updated"; got != want {
+		t.Fatalf("replayed event %q, want %q", got, want)
+	}
+
+	// The subscription predates the replay, so the replayed event 4 may
+	// also be published; it is skipped like the filtered events
+	server.events.Publish(testReviewEvent(4, 42, "Christopher Nolan"))
+	server.events.Publish(testReviewEvent(6, 7, "Agnès Varda"))
+	server.events.Publish(testReviewEvent(7, 42, "Christopher Nolan"))
+	if got, want := readEvent(), "id: 7 event: review.updated"; got != want {
+		t.Fatalf("live event %q, want %q", got, want)
+	}
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	if got, want := strings.Join(storage.calls, "; "), "ListReviewEvents(3, 500); OldestReviewEventID()"; got != want {
+		t.Fatalf("storage calls %q, want %q", got, want)
+	}
+}
+
+// TestAPIEventsGap checks that a client resuming from an event older than
+// the outbox keeps is told about the gap before the replay.
+func TestAPIEventsGap(t *testing.T) {
+	storage := &fakeStorage{t: t}
+	storage.listEvents = func(_ context.Context, afterID int64, _ int) ([]*ReviewEvent, error) {
+		if afterID != 3 {
+			return nil, nil
+		}
+		return []*ReviewEvent{testReviewEvent(10, 42, "Christopher Nolan")}, nil
+	}
+	storage.oldestEvent = func(context.Context) (int64, error) { return 10, nil }
+	_, handler := newTestAPI(storage, nil, nil)
+	stream := openEventStream(t, handler, "/events", "3")
+
+	if fields, data := stream(); fields != "event: gap" || data != `{"lastEventId":3,"oldestEventId":10}` {
+		t.Fatalf("first event %q with data %s, want the gap after event 3", fields, data)
+	}
+	if fields, _ := stream(); fields != "id: 10 event: review.updated" {
+		t.Fatalf("replayed event %q, want event 10", fields)
+	}
+}
+
+// getStatus returns the status code of a GET request to url.
+func getStatus(t *testing.T, url string) int {
+	t.Helper()
+	response, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	response.Body.Close()
+	return response.StatusCode
+}
+
+// dateCreatedPattern matches the creation time, which NewReview takes from
+// the clock when a review is created.
+var dateCreatedPattern = regexp.MustCompile(`"dateCreated":"[^"]*"`)
+
+// formatExchange renders the request and the response as golden file text.
+func formatExchange(tc apiTestCase, recorder *httptest.ResponseRecorder) string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "%s %s\n", tc.method, tc.path)
+	names := make([]string, 0, len(tc.headers))
+	for name := range tc.headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&out, "%s: %s\n", name, tc.headers[name])
+	}
+	if tc.body != "" {
+		fmt.Fprintf(&out, "\n%s\n", tc.body)
+	}
+
+	fmt.Fprintf(&out, "\n%s\n", strings.TrimSpace(fmt.Sprintf("%d %s", recorder.Code, http.StatusText(recorder.Code))))
+	for _, name := range goldenHeaders {
+		if value := recorder.Header().Get(name); value != "" {
+			fmt.Fprintf(&out, "%s: %s\n", name, value)
+		}
+	}
+	if body := recorder.Body.String(); body != "" {
+		if tc.method == http.MethodPost && tc.path == "/review" {
+			body = dateCreatedPattern.ReplaceAllString(body, `"dateCreated":"<now>"`)
+		}
+		fmt.Fprintf(&out, "\n%s", body)
+	}
+	return out.String()
+}
+
+// checkGolden compares got with testdata/api/<name>.golden, or rewrites the
+// file when -update is set.
+func checkGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", "api", name+".golden")
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden file (run with -update to create it): %v", err)
+	}
+	if got != string(want) {
+		t.Errorf("response differs from %s:\n--- got\n%s\n--- want\n%s", path, got, want)
+	}
+}
@@ -0,0 +1,332 @@
+// Package reviewapi provides the audit log for the Movie Review API.
+// This file records an append-only audit entry for every review changed by a
+// mutating store call and implements the admin endpoint querying them.
+//
+// Behaviour:
+//   - CreateReview, UpdateReview, DeleteReview, RestoreReview,
+//     PurgeDeletedReviews and RevertReview write one entry per changed
+//     review, in the same transaction as the change, so the log and the
+//     reviews cannot disagree
+//   - Each entry holds the authenticated actor (see ActorFromContext), the
+//     action, the review as JSON before and after the change, and the
+//     request ID, client IP and unverified claimed actor (X-Actor header)
+//     of the HTTP request that made it. Actors are only authenticated by
+//     mutual TLS or the admin token; other changes are "anonymous"
+//   - Database triggers reject any UPDATE or DELETE of the audit_log table,
+//     and entries outlive the reviews they describe
+//   - The admin endpoint is only served when server.admin_token is set, and
+//     requires it as a bearer token (see requireAdminToken)
+//
+// API Endpoints:
+//   - GET /admin/audit - List audit entries, newest first, with filters
+package reviewapi
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Audit actions, one per kind of mutating call.
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	AuditPurge   = "purge"
+	AuditRevert  = "revert"
+)
+
+// auditActions lists the valid audit actions, for validating queries.
+var auditActions = []string{AuditCreate, AuditUpdate, AuditDelete, AuditRestore, AuditPurge, AuditRevert}
+
+// AuditStore is implemented by the backends to query the audit log. The
+// audit route is served when one is given with WithAudit.
+type AuditStore interface {
+	// ListAuditEntries returns the audit entries selected by query, newest
+	// first. Every mutating call of Storage and TrashStore appends one entry
+	// per changed review.
+	ListAuditEntries(ctx context.Context, query AuditQuery) ([]*AuditEntry, error)
+}
+
+// auditColumns are the audit_log columns read by scanAuditEntry, in order.
+const auditColumns = "id, occurredAt, actor, action, reviewId, before, after, requestId, clientIp, claimedActor"
+
+// Bounds of the limit query parameter of GET /admin/audit.
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// updatedReview returns the version of current written by UpdateReview with
+// review: current with the tracked fields and UpdatedAt of review.
+func updatedReview(current, review *Review) *Review {
+	updated := *current
+	updated.Title = review.Title
+	updated.Director = review.Director
+	updated.ReleaseDate = review.ReleaseDate
+	updated.Rating = review.Rating
+	updated.ReviewNotes = review.ReviewNotes
+	updated.UpdatedAt = review.UpdatedAt
+	return &updated
+}
+
+// auditJSON encodes review for the before or after column of an audit
+// entry, or returns nil (SQL NULL) if there is no review. It returns a
+// string so that the PostgreSQL driver sends it as text for the JSONB column.
+func auditJSON(review *Review) (any, error) {
+	if review == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(review)
+	if err != nil {
+		return nil, fmt.Errorf("encode audit review: %w", err)
+	}
+	return string(data), nil
+}
+
+// auditArgs returns the arguments of the insertAudit statements for an
+// action on the review with the given ID, made within the request of ctx.
+func auditArgs(ctx context.Context, action string, id int, before, after *Review) ([]any, error) {
+	beforeJSON, err := auditJSON(before)
+	if err != nil {
+		return nil, err
+	}
+	afterJSON, err := auditJSON(after)
+	if err != nil {
+		return nil, err
+	}
+	return []any{ActorFromContext(ctx), action, id, beforeJSON, afterJSON,
+		RequestIDFromContext(ctx), ClientIPFromContext(ctx), ClaimedActorFromContext(ctx)}, nil
+}
+
+// auditQueryArgs returns the arguments of the listAudit statements for
+// query; zero times are passed as NULL so that they do not filter.
+func auditQueryArgs(query AuditQuery) []any {
+	var since, until any
+	if !query.Since.IsZero() {
+		since = query.Since.UTC()
+	}
+	if !query.Until.IsZero() {
+		until = query.Until.UTC()
+	}
+	return []any{query.Actor, query.Action, since, until, query.BeforeID, query.Limit}
+}
+
+// scanAuditEntry reads the auditColumns of row into entry.
+func scanAuditEntry(row rowScanner, entry *AuditEntry) error {
+	var before, after []byte
+	err := row.Scan(
+		&entry.ID,
+		&entry.OccurredAt,
+		&entry.Actor,
+		&entry.Action,
+		&entry.ReviewID,
+		&before,
+		&after,
+		&entry.RequestID,
+		&entry.ClientIP,
+		&entry.ClaimedActor)
+	if before != nil {
+		entry.Before = json.RawMessage(before)
+	}
+	if after != nil {
+		entry.After = json.RawMessage(after)
+	}
+	return err
+}
+
+// scanAuditEntries reads every audit entry of rows and closes them. It
+// returns an empty slice rather than nil when there is none.
+func scanAuditEntries(rows *sql.Rows) ([]*AuditEntry, error) {
+	defer rows.Close()
+	entries := []*AuditEntry{}
+	for rows.Next() {
+		entry := &AuditEntry{}
+		if err := scanAuditEntry(rows, entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// insertAudit records an action on the review with the given ID within tx.
+// before is nil for a create and after is nil for a purge.
+func (pg *PgDb) insertAudit(ctx context.Context, tx *sql.Tx, action string, id int, before, after *Review) error {
+	args, err := auditArgs(ctx, action, id, before, after)
+	if err != nil {
+		return err
+	}
+	return pg.withStatement(ctx, pg.stmtInsertAudit, false, func(stmt *sql.Stmt) error {
+		_, err := tx.StmtContext(ctx, stmt).ExecContext(ctx, args...)
+		return err
+	})
+}
+
+// ListAuditEntries returns the audit entries selected by query, newest
+// first.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout control
+//   - query: The filters and the maximum number of entries
+//
+// Returns:
+//   - []*AuditEntry: The entries, empty if none match
+//   - error: Non-nil if the query fails
+func (pg *PgDb) ListAuditEntries(ctx context.Context, query AuditQuery) (_ []*AuditEntry, err error) {
+	ctx, finish := pg.beginOperation(ctx, "listAudit", "SELECT", 0)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !pg.ready.Load() {
+		return nil, ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, pg.timeouts.Get)
+	defer cancel()
+
+	// Served by a caught-up replica if any; a stale statement is retried once
+	var entries []*AuditEntry
+	err = pg.withReadStatement(ctx, pg.stmtListAudit, func(s *replicaStatements) *preparedStmt { return s.listAudit }, func(stmt *sql.Stmt) error {
+		rows, err := stmt.QueryContext(ctx, auditQueryArgs(query)...)
+		if err != nil {
+			return err
+		}
+		entries, err = scanAuditEntries(rows)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit entries: %w", contextError(ctx, err))
+	}
+	return entries, nil
+}
+
+// insertAudit records an action on the review with the given ID within tx
+// (see PgDb.insertAudit).
+func (s *SqliteDb) insertAudit(ctx context.Context, tx *sql.Tx, action string, id int, before, after *Review) error {
+	args, err := auditArgs(ctx, action, id, before, after)
+	if err != nil {
+		return err
+	}
+	args = append([]any{time.Now().UTC()}, args...)
+	_, err = tx.StmtContext(ctx, s.stmtInsertAudit).ExecContext(ctx, args...)
+	return err
+}
+
+// ListAuditEntries returns the audit entries selected by query, newest
+// first (see PgDb.ListAuditEntries).
+func (s *SqliteDb) ListAuditEntries(ctx context.Context, query AuditQuery) (_ []*AuditEntry, err error) {
+	ctx, finish := s.beginOperation(ctx, "listAudit", "SELECT", 0)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !s.ready.Load() {
+		return nil, ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Get)
+	defer cancel()
+
+	rows, err := s.stmtListAudit.QueryContext(ctx, auditQueryArgs(query)...)
+	var entries []*AuditEntry
+	if err == nil {
+		entries, err = scanAuditEntries(rows)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit entries: %w", contextError(ctx, err))
+	}
+	return entries, nil
+}
+
+// parseAuditTime parses an optional RFC 3339 time query parameter.
+func parseAuditTime(name, raw string) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	value, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q: must be an RFC 3339 time", name, raw)
+	}
+	return value, nil
+}
+
+// parseAuditQuery builds an AuditQuery from the query parameters of a
+// GET /admin/audit request.
+func parseAuditQuery(request *http.Request) (AuditQuery, error) {
+	params := request.URL.Query()
+	query := AuditQuery{
+		Actor:  params.Get("actor"),
+		Action: params.Get("action"),
+	}
+
+	// Only known actions can match, so reject typos instead of returning nothing
+	if query.Action != "" && !slices.Contains(auditActions, query.Action) {
+		return query, fmt.Errorf("invalid action %q: must be one of %v", query.Action, auditActions)
+	}
+
+	var err error
+	if query.Since, err = parseAuditTime("since", params.Get("since")); err != nil {
+		return query, err
+	}
+	if query.Until, err = parseAuditTime("until", params.Get("until")); err != nil {
+		return query, err
+	}
+
+	if raw := params.Get("before"); raw != "" {
+		value, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || value < 1 {
+			return query, fmt.Errorf("invalid before %q: must be a positive entry id", raw)
+		}
+		query.BeforeID = value
+	}
+
+	query.Limit, err = parseLimit(request, defaultAuditLimit, maxAuditLimit)
+	return query, err
+}
+
+// handleListAudit handles GET /admin/audit requests.
+// It lists audit entries, newest first.
+//
+// Query Parameters:
+//   - actor: Only entries made by this actor (optional)
+//   - action: Only entries of this action: create, update, delete, restore,
+//     purge or revert (optional)
+//   - since, until: Only entries that occurred in [since, until), as RFC 3339
+//     times (optional)
+//   - before: Only entries with a smaller id, to fetch the next page
+//     (optional)
+//   - limit: Maximum number of entries, 1 to 1000 (default 100)
+//
+// Response:
+//   - 200 OK: Returns a JSON array of audit entries
+//   - 400 Bad Request: If a query parameter is invalid
+//   - 401 Unauthorized: If the admin token is missing or wrong
+//   - 499/503/504: If the request is cancelled or times out (see statusForError)
+//
+// Example Request:
+//
+//	GET /admin/audit?actor=alice&action=update&since=2026-01-01T00:00:00Z
+//	Authorization: Bearer <admin token>
+func (server *APIServer) handleListAudit(writer http.ResponseWriter, request *http.Request) error {
+	query, err := parseAuditQuery(request)
+	if err != nil {
+		return err
+	}
+
+	entries, err := server.audit.ListAuditEntries(request.Context(), query)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(writer, http.StatusOK, entries)
+}
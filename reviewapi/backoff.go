@@ -0,0 +1,94 @@
+// Package reviewapi provides retry backoff for the Movie Review API.
+// This file implements exponential backoff with jitter, used to wait for
+// the database at startup instead of exiting when it is not up yet (e.g.
+// when both are started together by docker-compose).
+package reviewapi
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// BackoffConfig controls how often a failing operation is retried.
+//
+// The n-th delay is InitialInterval doubled n times, capped at MaxInterval,
+// and randomized to between half and all of that value so that several
+// instances started together do not retry in lockstep.
+type BackoffConfig struct {
+	// InitialInterval is the base delay before the first retry.
+	InitialInterval time.Duration `yaml:"initial_interval"`
+
+	// MaxInterval caps the delay between attempts.
+	MaxInterval time.Duration `yaml:"max_interval"`
+
+	// MaxElapsed is the total time after which retrying stops.
+	// Zero retries until the context is cancelled.
+	MaxElapsed time.Duration `yaml:"max_elapsed"`
+}
+
+// Validate checks the backoff settings.
+func (c BackoffConfig) Validate() error {
+	if c.InitialInterval <= 0 {
+		return fmt.Errorf("initial interval must be positive")
+	}
+	if c.MaxInterval < c.InitialInterval {
+		return fmt.Errorf("max interval must not be less than the initial interval")
+	}
+	if c.MaxElapsed < 0 {
+		return fmt.Errorf("max elapsed must not be negative")
+	}
+	return nil
+}
+
+// delay returns the randomized wait before retry number attempt (0-based).
+func (c BackoffConfig) delay(attempt int) time.Duration {
+	interval := c.InitialInterval
+	for i := 0; i < attempt && interval < c.MaxInterval; i++ {
+		interval *= 2
+	}
+	interval = min(interval, c.MaxInterval)
+
+	// Equal jitter: keep half the interval, randomize the other half
+	half := interval / 2
+	return half + rand.N(interval-half+1)
+}
+
+// Retry calls operation until it succeeds, the context is cancelled or
+// MaxElapsed has passed, sleeping with exponential backoff in between.
+//
+// Parameters:
+//   - ctx: Context that aborts the retries (and the wait between them)
+//   - onRetry: Called after each failed attempt with the attempt number (1-based),
+//     the error and the delay before the next attempt; may be nil
+//   - operation: The operation to attempt
+//
+// Returns:
+//   - error: nil on success, otherwise the last error of operation (wrapping
+//     the context's error if retrying was cut short by ctx)
+func (c BackoffConfig) Retry(ctx context.Context, onRetry func(attempt int, err error, next time.Duration), operation func(context.Context) error) error {
+	start := time.Now()
+	for attempt := 0; ; attempt++ {
+		err := operation(ctx)
+		if err == nil {
+			return nil
+		}
+
+		wait := c.delay(attempt)
+		if c.MaxElapsed > 0 && time.Since(start)+wait > c.MaxElapsed {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt+1, err)
+		}
+		if onRetry != nil {
+			onRetry(attempt+1, err, wait)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w: %w", ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
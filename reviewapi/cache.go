@@ -0,0 +1,313 @@
+// Package reviewapi provides a read-through review cache for the Movie Review API.
+// This file implements ReviewCache, which keeps recently read reviews in
+// memory in front of any backend, and the decorators through which it
+// serves reads and sees writes.
+//
+// Behaviour:
+//   - Entries expire after the configured TTL and the least recently used
+//     entry is evicted when the cache is full
+//   - UpdateReview, DeleteReview, RestoreReview and RevertReview invalidate
+//     the review's entry, whatever their outcome
+//   - Concurrent misses for the same review share a single backend call,
+//     unless they carry different consistency tokens
+//   - Each entry remembers the WAL position it was read at; a request whose
+//     consistency token is newer misses, so a row read from a lagging
+//     replica is never served to a client that must see a later write
+//
+// The cache is local to the process: with several instances, a review
+// changed through another instance can be served stale for up to the TTL.
+package reviewapi
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// CacheConfig holds the review cache settings.
+type CacheConfig struct {
+	// Enabled turns on caching of GetReviewById results.
+	Enabled bool `yaml:"enabled"`
+
+	// Size is the maximum number of cached reviews.
+	Size int `yaml:"size"`
+
+	// TTL is how long a cached review is served before it is read again.
+	TTL time.Duration `yaml:"ttl"`
+}
+
+// Validate checks the cache settings.
+func (c CacheConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Size < 1 {
+		return fmt.Errorf("size must be at least 1")
+	}
+	if c.TTL <= 0 {
+		return fmt.Errorf("ttl must be positive")
+	}
+	return nil
+}
+
+// cacheEntry is a cached review and its expiry, stored in the LRU list.
+type cacheEntry struct {
+	id      int
+	review  Review
+	expires time.Time
+
+	// lsn is the WAL position the review was read at: it reflects every
+	// write up to it (0 when unknown, e.g. without replicas).
+	lsn uint64
+}
+
+// ReviewCache caches reviews by ID. The Storage returned by its Storage
+// method serves reads from it; every store whose writes change reviews must
+// be wrapped by it too (Storage, Trash and Revisions), so that they
+// invalidate it.
+type ReviewCache struct {
+	size    int
+	ttl     time.Duration
+	metrics *Metrics
+
+	// group deduplicates concurrent misses for the same review, consistency
+	// token and generation.
+	group singleflight.Group
+
+	mu sync.Mutex
+	// order holds *cacheEntry values, most recently used first.
+	order   *list.List
+	entries map[int]*list.Element
+	// generation is incremented by every invalidation. A load only stores
+	// its result if no invalidation happened while it ran, so a read racing
+	// with a write cannot cache the old review.
+	generation uint64
+}
+
+// NewReviewCache creates an empty review cache.
+//
+// Parameters:
+//   - cfg: Cache size and TTL
+//   - metrics: Where to record hits, misses and evictions (may be nil)
+//
+// Returns:
+//   - *ReviewCache: The cache, or nil when caching is disabled; the methods
+//     of a nil cache return the stores they are given unchanged
+func NewReviewCache(cfg CacheConfig, metrics *Metrics) *ReviewCache {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &ReviewCache{
+		size:    cfg.Size,
+		ttl:     cfg.TTL,
+		metrics: metrics,
+		order:   list.New(),
+		entries: make(map[int]*list.Element),
+	}
+}
+
+// Storage wraps next with the read-through cache for GetReviewById.
+func (c *ReviewCache) Storage(next Storage) Storage {
+	if c == nil {
+		return next
+	}
+	return &cachedStorage{ReviewCache: c, next: next}
+}
+
+// Trash wraps next so that restored reviews are invalidated.
+func (c *ReviewCache) Trash(next TrashStore) TrashStore {
+	if c == nil {
+		return next
+	}
+	return &cachedTrash{TrashStore: next, cache: c}
+}
+
+// Revisions wraps next so that reverted reviews are invalidated.
+func (c *ReviewCache) Revisions(next RevisionStore) RevisionStore {
+	if c == nil {
+		return next
+	}
+	return &cachedRevisions{RevisionStore: next, cache: c}
+}
+
+// cachedStorage is a Storage decorator caching reviews by ID.
+type cachedStorage struct {
+	*ReviewCache
+	next Storage
+}
+
+// cachedTrash is a TrashStore decorator invalidating restored reviews. The
+// other calls pass through: reviews in the trash are never cached, and
+// purged reviews were invalidated when they were deleted.
+type cachedTrash struct {
+	TrashStore
+	cache *ReviewCache
+}
+
+// RestoreReview restores the review and invalidates its cache entry.
+func (t *cachedTrash) RestoreReview(ctx context.Context, id int) (*Review, error) {
+	defer t.cache.invalidate(id)
+	return t.TrashStore.RestoreReview(ctx, id)
+}
+
+// cachedRevisions is a RevisionStore decorator invalidating reverted
+// reviews. Revisions themselves are not cached.
+type cachedRevisions struct {
+	RevisionStore
+	cache *ReviewCache
+}
+
+// RevertReview reverts the review and invalidates its cache entry.
+func (r *cachedRevisions) RevertReview(ctx context.Context, id, revision int) (*Review, error) {
+	// Invalidate even on failure: a timed out revert may still have committed
+	defer r.cache.invalidate(id)
+	return r.RevisionStore.RevertReview(ctx, id, revision)
+}
+
+// lookup returns a copy of the cached review with the given ID, if present,
+// not expired and read at minLSN or later.
+func (c *ReviewCache) lookup(id int, minLSN uint64, now time.Time) (*Review, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[id]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if now.After(entry.expires) {
+		c.removeLocked(element)
+		c.metrics.setCacheEntries(c.order.Len())
+		return nil, false
+	}
+	if entry.lsn < minLSN {
+		// Possibly older than the caller's writes; the entry still serves
+		// callers with older tokens until the reload replaces it
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	review := entry.review
+	return &review, true
+}
+
+// store caches review, read at WAL position lsn, unless an invalidation
+// happened after generation was read or a later read of it is cached.
+func (c *ReviewCache) store(review *Review, lsn, generation uint64, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	if element, ok := c.entries[review.ID]; ok {
+		if element.Value.(*cacheEntry).lsn > lsn {
+			return
+		}
+		c.removeLocked(element)
+	}
+	c.entries[review.ID] = c.order.PushFront(&cacheEntry{id: review.ID, review: *review, expires: now.Add(c.ttl), lsn: lsn})
+
+	for c.order.Len() > c.size {
+		c.removeLocked(c.order.Back())
+		c.metrics.cacheEvicted()
+	}
+	c.metrics.setCacheEntries(c.order.Len())
+}
+
+// invalidate drops the review with the given ID and discards loads in
+// flight: they do not store their result, and later misses do not join
+// them, since the generation is part of their key.
+func (c *ReviewCache) invalidate(id int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	if element, ok := c.entries[id]; ok {
+		c.removeLocked(element)
+		c.metrics.setCacheEntries(c.order.Len())
+	}
+}
+
+// removeLocked removes element from the cache. The caller holds mu.
+func (c *ReviewCache) removeLocked(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*cacheEntry).id)
+}
+
+// CreateReview passes through; new reviews are cached when first read.
+func (c *cachedStorage) CreateReview(ctx context.Context, review *Review) (string, error) {
+	return c.next.CreateReview(ctx, review)
+}
+
+// UpdateReview updates the review and invalidates its cache entry.
+func (c *cachedStorage) UpdateReview(ctx context.Context, review *Review) error {
+	// Invalidate even on failure: a timed out update may still have committed
+	defer c.invalidate(review.ID)
+	return c.next.UpdateReview(ctx, review)
+}
+
+// DeleteReview deletes the review and invalidates its cache entry.
+func (c *cachedStorage) DeleteReview(ctx context.Context, id int) error {
+	defer c.invalidate(id)
+	return c.next.DeleteReview(ctx, id)
+}
+
+// GetReviewById serves the review from the cache, or loads it from the
+// backend once for all concurrent callers and caches it. Errors, including
+// "not found", are not cached.
+//
+// Callers receive their own copy, so modifying it does not affect the cache.
+func (c *cachedStorage) GetReviewById(ctx context.Context, id int) (*Review, error) {
+	// Like the backends, do nothing for a caller that has already gone away
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get review: %w", contextError(ctx, err))
+	}
+
+	minLSN := consistencySessionFromContext(ctx).minLSN()
+	if review, ok := c.lookup(id, minLSN, time.Now()); ok {
+		c.metrics.cacheLookup(true)
+		return review, nil
+	}
+	c.metrics.cacheLookup(false)
+
+	c.mu.Lock()
+	generation := c.generation
+	c.mu.Unlock()
+
+	// The shared load must not be cancelled when the caller that started it
+	// goes away; the backend's operation budget still bounds it. It runs
+	// with that caller's consistency session, so only callers that must
+	// observe the same WAL position share it: a caller with a newer token
+	// must not get a row from a replica that has not replayed its writes.
+	// Misses after a write must not join a load that started before it, so
+	// the generation is part of the key too. The backend records the WAL
+	// position it read at, which decides the tokens the entry satisfies.
+	loadCtx, position := contextWithReadPosition(context.WithoutCancel(ctx))
+	key := fmt.Sprintf("%d@%d#%d", id, minLSN, generation)
+	results := c.group.DoChan(key, func() (any, error) {
+		review, err := c.next.GetReviewById(loadCtx, id)
+		if err != nil {
+			return nil, err
+		}
+		// The read satisfied the loading caller's token even if the backend
+		// could not tell its position
+		c.store(review, max(position.lsn.Load(), minLSN), generation, time.Now())
+		return review, nil
+	})
+
+	// Each caller still stops waiting when its own context is done
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("failed to get review: %w", contextError(ctx, ctx.Err()))
+	case result := <-results:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		review := *result.Val.(*Review)
+		return &review, nil
+	}
+}
@@ -0,0 +1,174 @@
+package reviewapi
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// laggingStorage is a backend with one replica that has replayed the WAL up
+// to replicaLSN, before the review's last update at primaryLSN. Like PgDb,
+// it serves reads from the replica unless the caller's consistency token is
+// newer, and records the position each read was served at.
+type laggingStorage struct {
+	Storage
+
+	replicaLSN, primaryLSN uint64
+	replica, primary       Review
+
+	mu    sync.Mutex
+	reads int
+}
+
+// GetReviewById returns the replica's or the primary's copy of the review.
+func (s *laggingStorage) GetReviewById(ctx context.Context, id int) (*Review, error) {
+	s.mu.Lock()
+	s.reads++
+	s.mu.Unlock()
+
+	review := s.replica
+	lsn := s.replicaLSN
+	if consistencySessionFromContext(ctx).minLSN() > s.replicaLSN {
+		review, lsn = s.primary, s.primaryLSN
+	}
+	recordReadPosition(ctx, lsn)
+	return &review, nil
+}
+
+// readCount returns the number of reads that reached the backend.
+func (s *laggingStorage) readCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reads
+}
+
+// withConsistencyToken returns ctx carrying a consistency token for lsn, as
+// consistencyMiddleware does for a request sending one.
+func withConsistencyToken(ctx context.Context, lsn uint64) context.Context {
+	return context.WithValue(ctx, consistencySessionKey{}, &consistencySession{lsn: lsn})
+}
+
+func TestReviewCacheConsistencyToken(t *testing.T) {
+	old, updated := *storedReview(7), *storedReview(7)
+	updated.Rating = "3/10"
+	backend := &laggingStorage{replicaLSN: 10, primaryLSN: 20, replica: old, primary: updated}
+	storage := NewReviewCache(CacheConfig{Enabled: true, Size: 10, TTL: time.Minute}, nil).Storage(backend)
+
+	steps := []struct {
+		name       string
+		ctx        context.Context
+		wantRating string
+		wantReads  int
+	}{
+		// A client without a token may read the lagging replica's copy
+		{"no token misses", context.Background(), old.Rating, 1},
+		{"no token hits", context.Background(), old.Rating, 1},
+		{"token already replayed hits", withConsistencyToken(context.Background(), 10), old.Rating, 1},
+		// The client that made the update must see it, not the cached row
+		{"newer token misses", withConsistencyToken(context.Background(), 20), updated.Rating, 2},
+		{"newer token hits the reloaded row", withConsistencyToken(context.Background(), 20), updated.Rating, 2},
+		{"no token hits the reloaded row", context.Background(), updated.Rating, 2},
+		{"token past every read misses", withConsistencyToken(context.Background(), 30), updated.Rating, 3},
+	}
+	for _, step := range steps {
+		review, err := storage.GetReviewById(step.ctx, 7)
+		if err != nil {
+			t.Fatalf("%s: GetReviewById: %v", step.name, err)
+		}
+		if review.Rating != step.wantRating {
+			t.Errorf("%s: rating %q, want %q", step.name, review.Rating, step.wantRating)
+		}
+		if got := backend.readCount(); got != step.wantReads {
+			t.Errorf("%s: %d backend reads, want %d", step.name, got, step.wantReads)
+		}
+	}
+}
+
+// blockingStorage is a backend whose first read holds the review as it was
+// when the read started until release is closed, like a slow query racing
+// with a write.
+type blockingStorage struct {
+	Storage
+
+	started chan struct{}
+	release chan struct{}
+
+	mu     sync.Mutex
+	review Review
+	reads  int
+}
+
+// GetReviewById returns the current review; the first call blocks.
+func (s *blockingStorage) GetReviewById(ctx context.Context, id int) (*Review, error) {
+	s.mu.Lock()
+	review := s.review
+	s.reads++
+	first := s.reads == 1
+	s.mu.Unlock()
+
+	if first {
+		close(s.started)
+		<-s.release
+	}
+	return &review, nil
+}
+
+// UpdateReview replaces the review.
+func (s *blockingStorage) UpdateReview(ctx context.Context, review *Review) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.review = *review
+	return nil
+}
+
+// readCount returns the number of reads that reached the backend.
+func (s *blockingStorage) readCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reads
+}
+
+// TestReviewCacheInvalidationRace checks that a read started before an
+// update neither caches the old review nor is joined by reads after it.
+func TestReviewCacheInvalidationRace(t *testing.T) {
+	old, updated := *storedReview(7), *storedReview(7)
+	updated.Rating = "3/10"
+	backend := &blockingStorage{started: make(chan struct{}), release: make(chan struct{}), review: old}
+	storage := NewReviewCache(CacheConfig{Enabled: true, Size: 10, TTL: time.Minute}, nil).Storage(backend)
+	ctx := context.Background()
+
+	// A read loads the old review and is held up
+	slow := make(chan *Review)
+	go func() {
+		review, err := storage.GetReviewById(ctx, 7)
+		if err != nil {
+			t.Errorf("slow GetReviewById: %v", err)
+		}
+		slow <- review
+	}()
+	<-backend.started
+
+	// An update lands meanwhile; a read after it must not wait for, or
+	// share, the load that started before it
+	if err := storage.UpdateReview(ctx, &updated); err != nil {
+		t.Fatalf("UpdateReview: %v", err)
+	}
+	review, err := storage.GetReviewById(ctx, 7)
+	if err != nil || review.Rating != updated.Rating {
+		t.Fatalf("GetReviewById after the update = %v, %v; want rating %q", review, err, updated.Rating)
+	}
+
+	// The slow read completes with what it read, but does not cache it
+	close(backend.release)
+	if review := <-slow; review.Rating != old.Rating {
+		t.Fatalf("slow read rating %q, want %q", review.Rating, old.Rating)
+	}
+	review, err = storage.GetReviewById(ctx, 7)
+	if err != nil || review.Rating != updated.Rating {
+		t.Fatalf("GetReviewById after the slow read = %v, %v; want rating %q", review, err, updated.Rating)
+	}
+	if got := backend.readCount(); got != 2 {
+		t.Fatalf("%d backend reads, want 2: the read after the update is cached", got)
+	}
+}
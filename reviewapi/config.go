@@ -0,0 +1,582 @@
+// Package reviewapi provides the configuration system for the Movie Review API.
+// This file defines the typed Config struct and loads it from a YAML file,
+// environment variables and command-line flags.
+//
+// Sources are applied in order of increasing precedence:
+//  1. Built-in defaults (DefaultConfig)
+//  2. YAML config file (-config flag or CONFIG_FILE environment variable)
+//  3. Environment variables (e.g. DB_HOST)
+//  4. Command-line flags (e.g. -database.host)
+//
+// The merged configuration is validated before the server starts and can be
+// printed with secrets redacted using the -print-config flag. Secrets may be
+// read from files through *_FILE environment variables (see lookupEnvOrFile).
+package reviewapi
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Supported values for Config.Environment.
+const (
+	environmentDevelopment = "development"
+	environmentProduction  = "production"
+)
+
+// defaultDBPassword is the development password used when none is configured.
+// The server refuses to start with it in production.
+const defaultDBPassword = "test"
+
+// Config is the complete application configuration.
+type Config struct {
+	// Environment is "development" or "production". Production mode refuses
+	// to start with default credentials.
+	Environment string `yaml:"environment"`
+
+	// Server holds the HTTP listener settings.
+	Server ServerConfig `yaml:"server"`
+
+	// Database holds the PostgreSQL connection and pool settings.
+	Database DBConfig `yaml:"database"`
+
+	// Resilience holds the storage retry policy and circuit breaker.
+	Resilience ResilienceConfig `yaml:"resilience"`
+
+	// Cache configures the in-memory review cache.
+	Cache CacheConfig `yaml:"cache"`
+
+	// Trash holds the retention of soft-deleted reviews.
+	Trash TrashConfig `yaml:"trash"`
+
+	// Webhooks holds the endpoints notified of review changes.
+	Webhooks WebhooksConfig `yaml:"webhooks"`
+
+	// Events configures the review event stream.
+	Events EventsConfig `yaml:"events"`
+
+	// Log holds the logging level and format.
+	Log LogConfig `yaml:"log"`
+
+	// Metrics configures the Prometheus metrics endpoint.
+	Metrics MetricsConfig `yaml:"metrics"`
+
+	// Tracing configures OpenTelemetry tracing and span export.
+	Tracing TracingConfig `yaml:"tracing"`
+}
+
+// ServerConfig holds the HTTP server settings.
+type ServerConfig struct {
+	// Addr is the address and port the server listens on (e.g., "0.0.0.0:8080").
+	Addr string `yaml:"addr"`
+
+	// ReadTimeout is the max time to read a request (prevents slow client attacks).
+	ReadTimeout time.Duration `yaml:"read_timeout"`
+
+	// WriteTimeout is the max time to write a response (prevents slow response attacks).
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+
+	// IdleTimeout is the max time a keep-alive connection may stay idle.
+	IdleTimeout time.Duration `yaml:"idle_timeout"`
+
+	// ShutdownTimeout is the max time in-flight requests get during graceful shutdown.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+
+	// DrainDelay is how long the server keeps serving after /readyz starts
+	// failing at shutdown, giving load balancers time to stop routing to it.
+	DrainDelay time.Duration `yaml:"drain_delay"`
+
+	// CacheControl maps route patterns (e.g. "/review/{id}") to the
+	// Cache-Control header sent with their successful GET responses.
+	// Responses of other routes and errors are sent with "no-store".
+	CacheControl map[string]string `yaml:"cache_control"`
+
+	// TLS configures HTTPS serving; plain HTTP is used when not enabled.
+	TLS TLSConfig `yaml:"tls"`
+
+	// AdminToken is the bearer token required by the /admin routes, which
+	// are not served when it is empty. Requests carrying it on other routes
+	// are made by the "admin" actor.
+	AdminToken Secret `yaml:"admin_token"`
+}
+
+// minAdminTokenLength keeps admin tokens long enough not to be guessed.
+const minAdminTokenLength = 16
+
+// DefaultConfig returns the configuration used when no other source
+// overrides a setting. The defaults are suited to local development.
+func DefaultConfig() Config {
+	return Config{
+		Environment: environmentDevelopment,
+		Server: ServerConfig{
+			Addr:            "0.0.0.0:8080",
+			ReadTimeout:     15 * time.Second,
+			WriteTimeout:    15 * time.Second,
+			IdleTimeout:     60 * time.Second,
+			ShutdownTimeout: 30 * time.Second,
+			DrainDelay:      5 * time.Second,
+			// Shared caches may store reviews but must revalidate each use
+			// (cheap thanks to Last-Modified and 304 responses).
+			CacheControl: map[string]string{
+				"/review/{id}": "public, no-cache",
+			},
+			TLS: TLSConfig{
+				MinVersion: "1.2",
+			},
+		},
+		Database: DBConfig{
+			Driver: driverPostgres,
+			Path:   "goBackend.db",
+
+			Host:     "localhost",
+			Port:     5432,
+			User:     "postgres",
+			Password: defaultDBPassword,
+			Name:     "postgres",
+
+			// Up to 25 open connections, all of which may stay idle to
+			// prevent connection churn under load.
+			MaxOpenConns: 25,
+			MaxIdleConns: 25,
+
+			// Connections are recycled every 5 minutes to balance freshness
+			// with reuse efficiency.
+			ConnMaxLifetime: 5 * time.Minute,
+
+			// Database operations exceeding this are cancelled.
+			QueryTimeout: 10 * time.Second,
+
+			// Wait up to 5 minutes for the database at startup.
+			ConnectRetry: BackoffConfig{
+				InitialInterval: 500 * time.Millisecond,
+				MaxInterval:     30 * time.Second,
+				MaxElapsed:      5 * time.Minute,
+			},
+
+			// Check read replicas (when configured) every 5 seconds.
+			ReplicaCheckInterval: 5 * time.Second,
+
+			// Apply pending schema migrations at startup.
+			AutoMigrate: true,
+		},
+		Resilience: ResilienceConfig{
+			// Up to 3 attempts, waiting 50ms then 100ms (with jitter).
+			Retry: StorageRetryConfig{
+				MaxAttempts:     3,
+				InitialInterval: 50 * time.Millisecond,
+				MaxInterval:     time.Second,
+			},
+			// Open when half of at least 20 calls in 10s fail; probe after 15s.
+			Breaker: BreakerConfig{
+				Enabled:      true,
+				FailureRatio: 0.5,
+				MinRequests:  20,
+				Window:       10 * time.Second,
+				OpenDuration: 15 * time.Second,
+			},
+		},
+		// Disabled by default: with several instances, reviews changed
+		// elsewhere are served stale for up to the TTL.
+		Cache: CacheConfig{
+			Size: 1000,
+			TTL:  30 * time.Second,
+		},
+		// Deleted reviews can be restored for 30 days.
+		Trash: TrashConfig{
+			Retention:     30 * 24 * time.Hour,
+			PurgeInterval: time.Hour,
+		},
+		// Poll every second; retry for about 5 hours before giving up, and
+		// keep delivered events for a week.
+		Webhooks: WebhooksConfig{
+			PollInterval: time.Second,
+			Timeout:      10 * time.Second,
+			MaxAttempts:  10,
+			RetryInitial: 10 * time.Second,
+			RetryMax:     time.Hour,
+			BatchSize:    50,
+			Retention:    7 * 24 * time.Hour,
+		},
+		Events: EventsConfig{
+			Enabled:    true,
+			KeepAlive:  15 * time.Second,
+			BufferSize: 256,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Metrics: MetricsConfig{
+			Enabled: true,
+			Path:    "/metrics",
+		},
+		Tracing: TracingConfig{
+			Enabled:     false,
+			Exporter:    "otlp",
+			Endpoint:    "http://localhost:4318",
+			File:        "traces.json",
+			ServiceName: "goBackend",
+			SampleRatio: 1,
+		},
+	}
+}
+
+// configSetting describes one configurable value and the names it can be
+// set by in each configuration source.
+type configSetting struct {
+	// name is the YAML path of the setting, also used as the flag name.
+	name string
+
+	// env is the environment variable that overrides the setting.
+	env string
+
+	// usage is the help text shown for the flag.
+	usage string
+
+	// field returns a pointer to the setting within cfg.
+	field func(cfg *Config) any
+}
+
+// configSettings lists every setting that can be overridden by environment
+// variables and flags. Field types supported are string, Secret, DSN, int,
+// float64, bool, time.Duration, []DSN (comma separated) and map[string]string
+// (see parseCachePolicies).
+//
+// Every environment variable also has a *_FILE variant (e.g. DB_PASSWORD_FILE)
+// that reads the value from a file, for secrets mounted by Docker or Kubernetes.
+var configSettings = []configSetting{
+	{"environment", "APP_ENV", "development or production", func(c *Config) any { return &c.Environment }},
+	{"server.addr", "SERVER_ADDR", "listen address", func(c *Config) any { return &c.Server.Addr }},
+	{"server.read_timeout", "SERVER_READ_TIMEOUT", "max time to read a request", func(c *Config) any { return &c.Server.ReadTimeout }},
+	{"server.write_timeout", "SERVER_WRITE_TIMEOUT", "max time to write a response", func(c *Config) any { return &c.Server.WriteTimeout }},
+	{"server.idle_timeout", "SERVER_IDLE_TIMEOUT", "keep-alive connection timeout", func(c *Config) any { return &c.Server.IdleTimeout }},
+	{"server.shutdown_timeout", "SERVER_SHUTDOWN_TIMEOUT", "graceful shutdown timeout", func(c *Config) any { return &c.Server.ShutdownTimeout }},
+	{"server.drain_delay", "SERVER_DRAIN_DELAY", "time to keep serving after readiness fails at shutdown", func(c *Config) any { return &c.Server.DrainDelay }},
+	{"server.cache_control", "SERVER_CACHE_CONTROL", "Cache-Control per route as route=policy pairs separated by ;", func(c *Config) any { return &c.Server.CacheControl }},
+	{"server.tls.cert_file", "TLS_CERT_FILE", "server certificate (PEM); enables HTTPS", func(c *Config) any { return &c.Server.TLS.CertFile }},
+	{"server.tls.key_file", "TLS_KEY_FILE", "server private key (PEM)", func(c *Config) any { return &c.Server.TLS.KeyFile }},
+	{"server.tls.min_version", "TLS_MIN_VERSION", "minimum TLS version (1.2 or 1.3)", func(c *Config) any { return &c.Server.TLS.MinVersion }},
+	{"server.tls.client_ca_file", "TLS_CLIENT_CA_FILE", "CA bundle for client certificates", func(c *Config) any { return &c.Server.TLS.ClientCAFile }},
+	{"server.tls.require_client_cert", "TLS_REQUIRE_CLIENT_CERT", "require verified client certificates", func(c *Config) any { return &c.Server.TLS.RequireClientCert }},
+	{"server.admin_token", "ADMIN_TOKEN", "bearer token for the /admin routes; unset disables them", func(c *Config) any { return &c.Server.AdminToken }},
+	{"database.driver", "DB_DRIVER", "storage backend: postgres or sqlite", func(c *Config) any { return &c.Database.Driver }},
+	{"database.path", "DB_PATH", "SQLite database file", func(c *Config) any { return &c.Database.Path }},
+	{"database.url", "DATABASE_URL", "full DSN or postgres:// URL", func(c *Config) any { return &c.Database.URL }},
+	{"database.host", "DB_HOST", "database host", func(c *Config) any { return &c.Database.Host }},
+	{"database.port", "DB_PORT", "database port", func(c *Config) any { return &c.Database.Port }},
+	{"database.user", "DB_USER", "database user", func(c *Config) any { return &c.Database.User }},
+	{"database.password", "DB_PASSWORD", "database password", func(c *Config) any { return &c.Database.Password }},
+	{"database.name", "DB_NAME", "database name", func(c *Config) any { return &c.Database.Name }},
+	{"database.sslmode", "DB_SSLMODE", "disable, require, verify-ca or verify-full", func(c *Config) any { return &c.Database.SSLMode }},
+	{"database.sslrootcert", "DB_SSLROOTCERT", "CA bundle for the database server", func(c *Config) any { return &c.Database.SSLRootCert }},
+	{"database.sslcert", "DB_SSLCERT", "database client certificate", func(c *Config) any { return &c.Database.SSLCert }},
+	{"database.sslkey", "DB_SSLKEY", "database client key", func(c *Config) any { return &c.Database.SSLKey }},
+	{"database.application_name", "DB_APPLICATION_NAME", "application name reported to PostgreSQL", func(c *Config) any { return &c.Database.ApplicationName }},
+	{"database.search_path", "DB_SEARCH_PATH", "schema search path", func(c *Config) any { return &c.Database.SearchPath }},
+	{"database.statement_timeout", "DB_STATEMENT_TIMEOUT", "server-side statement timeout", func(c *Config) any { return &c.Database.StatementTimeout }},
+	{"database.max_open_conns", "DB_MAX_OPEN_CONNS", "max open connections", func(c *Config) any { return &c.Database.MaxOpenConns }},
+	{"database.max_idle_conns", "DB_MAX_IDLE_CONNS", "max idle connections", func(c *Config) any { return &c.Database.MaxIdleConns }},
+	{"database.conn_max_lifetime", "DB_CONN_MAX_LIFETIME", "max connection reuse duration", func(c *Config) any { return &c.Database.ConnMaxLifetime }},
+	{"database.query_timeout", "DB_QUERY_TIMEOUT", "timeout for database operations", func(c *Config) any { return &c.Database.QueryTimeout }},
+	{"database.replicas", "DB_REPLICA_URLS", "comma separated read replica DSNs or URLs", func(c *Config) any { return &c.Database.Replicas }},
+	{"database.replica_check_interval", "DB_REPLICA_CHECK_INTERVAL", "time between replica health checks", func(c *Config) any { return &c.Database.ReplicaCheckInterval }},
+	{"database.connect_retry.initial_interval", "DB_CONNECT_INITIAL_INTERVAL", "first delay between database connection attempts", func(c *Config) any { return &c.Database.ConnectRetry.InitialInterval }},
+	{"database.connect_retry.max_interval", "DB_CONNECT_MAX_INTERVAL", "max delay between database connection attempts", func(c *Config) any { return &c.Database.ConnectRetry.MaxInterval }},
+	{"database.connect_retry.max_elapsed", "DB_CONNECT_MAX_ELAPSED", "give up connecting after this long (0 retries forever)", func(c *Config) any { return &c.Database.ConnectRetry.MaxElapsed }},
+	{"database.auto_migrate", "DB_AUTO_MIGRATE", "apply pending schema migrations at startup", func(c *Config) any { return &c.Database.AutoMigrate }},
+	{"database.timeouts.create", "DB_TIMEOUT_CREATE", "budget for creating a review (default query_timeout)", func(c *Config) any { return &c.Database.Timeouts.Create }},
+	{"database.timeouts.update", "DB_TIMEOUT_UPDATE", "budget for updating a review (default query_timeout)", func(c *Config) any { return &c.Database.Timeouts.Update }},
+	{"database.timeouts.delete", "DB_TIMEOUT_DELETE", "budget for deleting a review (default query_timeout)", func(c *Config) any { return &c.Database.Timeouts.Delete }},
+	{"database.timeouts.get", "DB_TIMEOUT_GET", "budget for fetching a review (default query_timeout)", func(c *Config) any { return &c.Database.Timeouts.Get }},
+	{"resilience.retry.max_attempts", "STORAGE_RETRY_MAX_ATTEMPTS", "attempts per storage call for transient errors (1 disables retries)", func(c *Config) any { return &c.Resilience.Retry.MaxAttempts }},
+	{"resilience.retry.initial_interval", "STORAGE_RETRY_INITIAL_INTERVAL", "first delay between storage retries", func(c *Config) any { return &c.Resilience.Retry.InitialInterval }},
+	{"resilience.retry.max_interval", "STORAGE_RETRY_MAX_INTERVAL", "max delay between storage retries", func(c *Config) any { return &c.Resilience.Retry.MaxInterval }},
+	{"resilience.breaker.enabled", "BREAKER_ENABLED", "fail fast while the database is degraded", func(c *Config) any { return &c.Resilience.Breaker.Enabled }},
+	{"resilience.breaker.failure_ratio", "BREAKER_FAILURE_RATIO", "fraction of failed calls that opens the circuit", func(c *Config) any { return &c.Resilience.Breaker.FailureRatio }},
+	{"resilience.breaker.min_requests", "BREAKER_MIN_REQUESTS", "calls per window before the ratio applies", func(c *Config) any { return &c.Resilience.Breaker.MinRequests }},
+	{"resilience.breaker.window", "BREAKER_WINDOW", "period over which calls are counted", func(c *Config) any { return &c.Resilience.Breaker.Window }},
+	{"resilience.breaker.open_duration", "BREAKER_OPEN_DURATION", "time the circuit stays open before a probe", func(c *Config) any { return &c.Resilience.Breaker.OpenDuration }},
+	{"cache.enabled", "CACHE_ENABLED", "cache reviews read by ID in memory", func(c *Config) any { return &c.Cache.Enabled }},
+	{"cache.size", "CACHE_SIZE", "max cached reviews", func(c *Config) any { return &c.Cache.Size }},
+	{"cache.ttl", "CACHE_TTL", "time a cached review is served", func(c *Config) any { return &c.Cache.TTL }},
+	{"trash.retention", "TRASH_RETENTION", "time a deleted review can be restored (0 keeps it forever)", func(c *Config) any { return &c.Trash.Retention }},
+	{"trash.purge_interval", "TRASH_PURGE_INTERVAL", "how often expired deleted reviews are purged", func(c *Config) any { return &c.Trash.PurgeInterval }},
+	{"webhooks.endpoints", "WEBHOOK_ENDPOINTS", "webhook URLs as name=url pairs separated by ;", func(c *Config) any { return &c.Webhooks.Endpoints }},
+	{"webhooks.secret", "WEBHOOK_SECRET", "HMAC key signing webhook requests", func(c *Config) any { return &c.Webhooks.Secret }},
+	{"webhooks.poll_interval", "WEBHOOK_POLL_INTERVAL", "how often due webhook deliveries are sent", func(c *Config) any { return &c.Webhooks.PollInterval }},
+	{"webhooks.timeout", "WEBHOOK_TIMEOUT", "timeout of a webhook request", func(c *Config) any { return &c.Webhooks.Timeout }},
+	{"webhooks.max_attempts", "WEBHOOK_MAX_ATTEMPTS", "attempts before a webhook delivery is dead", func(c *Config) any { return &c.Webhooks.MaxAttempts }},
+	{"webhooks.retry_initial", "WEBHOOK_RETRY_INITIAL", "first delay between webhook attempts", func(c *Config) any { return &c.Webhooks.RetryInitial }},
+	{"webhooks.retry_max", "WEBHOOK_RETRY_MAX", "max delay between webhook attempts", func(c *Config) any { return &c.Webhooks.RetryMax }},
+	{"webhooks.batch_size", "WEBHOOK_BATCH_SIZE", "max webhook deliveries sent at once", func(c *Config) any { return &c.Webhooks.BatchSize }},
+	{"webhooks.retention", "WEBHOOK_RETENTION", "time delivered events stay in the outbox (0 keeps them forever)", func(c *Config) any { return &c.Webhooks.Retention }},
+	{"events.enabled", "EVENTS_ENABLED", "serve the review event stream at /events", func(c *Config) any { return &c.Events.Enabled }},
+	{"events.keepalive", "EVENTS_KEEPALIVE", "interval of keep-alive comments on idle event streams", func(c *Config) any { return &c.Events.KeepAlive }},
+	{"events.buffer_size", "EVENTS_BUFFER_SIZE", "events queued per stream before a slow client is dropped", func(c *Config) any { return &c.Events.BufferSize }},
+	{"log.level", "LOG_LEVEL", "minimum log level: debug, info, warn or error", func(c *Config) any { return &c.Log.Level }},
+	{"log.format", "LOG_FORMAT", "log output format: json or text", func(c *Config) any { return &c.Log.Format }},
+	{"metrics.enabled", "METRICS_ENABLED", "expose Prometheus metrics", func(c *Config) any { return &c.Metrics.Enabled }},
+	{"metrics.path", "METRICS_PATH", "route serving Prometheus metrics", func(c *Config) any { return &c.Metrics.Path }},
+	{"tracing.enabled", "TRACING_ENABLED", "record and export OpenTelemetry traces", func(c *Config) any { return &c.Tracing.Enabled }},
+	{"tracing.exporter", "TRACING_EXPORTER", "trace exporter: otlp or file", func(c *Config) any { return &c.Tracing.Exporter }},
+	{"tracing.endpoint", "TRACING_ENDPOINT", "OTLP/HTTP collector URL", func(c *Config) any { return &c.Tracing.Endpoint }},
+	{"tracing.file", "TRACING_FILE", "file written by the file exporter", func(c *Config) any { return &c.Tracing.File }},
+	{"tracing.service_name", "TRACING_SERVICE_NAME", "service.name reported on spans", func(c *Config) any { return &c.Tracing.ServiceName }},
+	{"tracing.sample_ratio", "TRACING_SAMPLE_RATIO", "fraction of new traces recorded (0-1)", func(c *Config) any { return &c.Tracing.SampleRatio }},
+}
+
+// setConfigValue parses raw and stores it in the field pointed to by target.
+func setConfigValue(target any, raw string) error {
+	switch field := target.(type) {
+	case *string:
+		*field = raw
+	case *Secret:
+		*field = Secret(raw)
+	case *DSN:
+		*field = DSN(raw)
+	case *int:
+		value, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("expected an integer: %w", err)
+		}
+		*field = value
+	case *bool:
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("expected a boolean: %w", err)
+		}
+		*field = value
+	case *float64:
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("expected a number: %w", err)
+		}
+		*field = value
+	case *[]DSN:
+		var values []DSN
+		for _, value := range strings.Split(raw, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, DSN(value))
+			}
+		}
+		*field = values
+	case *map[string]string:
+		value, err := parseCachePolicies(raw)
+		if err != nil {
+			return err
+		}
+		*field = value
+	case *time.Duration:
+		value, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("expected a duration: %w", err)
+		}
+		*field = value
+	default:
+		return fmt.Errorf("unsupported setting type %T", target)
+	}
+	return nil
+}
+
+// LoadOptions carries command-line options that are not part of Config.
+type LoadOptions struct {
+	// ConfigFile is the YAML file that was loaded, if any.
+	ConfigFile string
+
+	// PrintConfig requests printing the redacted configuration and exiting.
+	PrintConfig bool
+}
+
+// LoadConfig builds the configuration from defaults, the config file,
+// environment variables and command-line flags, then validates it.
+//
+// Parameters:
+//   - args: Command-line arguments without the program name (os.Args[1:])
+//
+// Returns:
+//   - Config: The merged and validated configuration
+//   - LoadOptions: Options that only affect startup (config path, -print-config)
+//   - error: Non-nil if any source is malformed or validation fails;
+//     flag.ErrHelp is returned when -h is given
+func LoadConfig(args []string) (Config, LoadOptions, error) {
+	cfg := DefaultConfig()
+	var opts LoadOptions
+
+	// Parse flags first (to find -config), but apply their values last
+	type flagValue struct {
+		setting configSetting
+		raw     string
+	}
+	var flagValues []flagValue
+
+	flagSet := flag.NewFlagSet("goBackend", flag.ContinueOnError)
+	flagSet.StringVar(&opts.ConfigFile, "config", os.Getenv("CONFIG_FILE"), "path to a YAML config file (env CONFIG_FILE)")
+	flagSet.BoolVar(&opts.PrintConfig, "print-config", false, "print the effective configuration with secrets redacted and exit")
+	for _, setting := range configSettings {
+		usage := fmt.Sprintf("%s (env %s)", setting.usage, setting.env)
+		record := func(raw string) error {
+			flagValues = append(flagValues, flagValue{setting: setting, raw: raw})
+			return nil
+		}
+		if _, isBool := setting.field(&cfg).(*bool); isBool {
+			flagSet.BoolFunc(setting.name, usage, record)
+		} else {
+			flagSet.Func(setting.name, usage, record)
+		}
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return Config{}, opts, err
+	}
+
+	// Config file overrides the defaults
+	if opts.ConfigFile != "" {
+		if err := loadConfigFile(opts.ConfigFile, &cfg); err != nil {
+			return Config{}, opts, err
+		}
+	}
+
+	// Environment variables (or their *_FILE variants) override the config file
+	for _, setting := range configSettings {
+		raw, exists, err := lookupEnvOrFile(setting.env)
+		if err != nil {
+			return Config{}, opts, err
+		}
+		if !exists {
+			continue
+		}
+		if err := setConfigValue(setting.field(&cfg), raw); err != nil {
+			return Config{}, opts, fmt.Errorf("invalid %s: %w", setting.env, err)
+		}
+	}
+
+	// Flags override everything
+	for _, value := range flagValues {
+		if err := setConfigValue(value.setting.field(&cfg), value.raw); err != nil {
+			return Config{}, opts, fmt.Errorf("invalid -%s: %w", value.setting.name, err)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, opts, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return cfg, opts, nil
+}
+
+// loadConfigFile decodes a YAML config file on top of cfg.
+// Unknown keys are rejected so that typos do not go unnoticed.
+func loadConfigFile(path string, cfg *Config) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open config file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate checks the whole configuration for invalid settings.
+// In production mode it also rejects the built-in development credentials.
+func (c Config) Validate() error {
+	switch c.Environment {
+	case environmentDevelopment:
+	case environmentProduction:
+		if c.Database.Driver == driverPostgres && c.Database.URL == "" && c.Database.Password.Value() == defaultDBPassword {
+			return fmt.Errorf("refusing to start in production with the default database password; set DB_PASSWORD or DB_PASSWORD_FILE")
+		}
+	default:
+		return fmt.Errorf("environment must be %q or %q, got %q", environmentDevelopment, environmentProduction, c.Environment)
+	}
+	if err := c.Server.Validate(); err != nil {
+		return fmt.Errorf("server: %w", err)
+	}
+	if err := c.Database.Validate(); err != nil {
+		return fmt.Errorf("database: %w", err)
+	}
+	if err := c.Resilience.Validate(); err != nil {
+		return fmt.Errorf("resilience: %w", err)
+	}
+	if err := c.Cache.Validate(); err != nil {
+		return fmt.Errorf("cache: %w", err)
+	}
+	if err := c.Trash.Validate(); err != nil {
+		return fmt.Errorf("trash: %w", err)
+	}
+	if err := c.Webhooks.Validate(); err != nil {
+		return fmt.Errorf("webhooks: %w", err)
+	}
+	if err := c.Events.Validate(); err != nil {
+		return fmt.Errorf("events: %w", err)
+	}
+	if err := c.Log.Validate(); err != nil {
+		return fmt.Errorf("log: %w", err)
+	}
+	if c.Metrics.Enabled && !strings.HasPrefix(c.Metrics.Path, "/") {
+		return fmt.Errorf("metrics: path must start with /")
+	}
+	if err := c.Tracing.Validate(); err != nil {
+		return fmt.Errorf("tracing: %w", err)
+	}
+	return nil
+}
+
+// Validate checks the HTTP server settings.
+func (c ServerConfig) Validate() error {
+	if c.Addr == "" {
+		return fmt.Errorf("listen address must be set")
+	}
+	timeouts := map[string]time.Duration{
+		"read_timeout":     c.ReadTimeout,
+		"write_timeout":    c.WriteTimeout,
+		"idle_timeout":     c.IdleTimeout,
+		"shutdown_timeout": c.ShutdownTimeout,
+	}
+	for name, timeout := range timeouts {
+		if timeout <= 0 {
+			return fmt.Errorf("%s must be positive", name)
+		}
+	}
+	if c.DrainDelay < 0 {
+		return fmt.Errorf("drain_delay must not be negative")
+	}
+	if err := validateCachePolicies(c.CacheControl); err != nil {
+		return err
+	}
+	if c.TLS.Enabled() {
+		if c.TLS.CertFile == "" || c.TLS.KeyFile == "" {
+			return fmt.Errorf("both TLS certificate and key files must be set")
+		}
+		if _, err := parseTLSVersion(c.TLS.MinVersion); err != nil {
+			return err
+		}
+	}
+	if c.TLS.RequireClientCert && c.TLS.ClientCAFile == "" {
+		return fmt.Errorf("requiring client certificates needs a client CA file")
+	}
+	if c.AdminToken != "" && len(c.AdminToken) < minAdminTokenLength {
+		return fmt.Errorf("admin_token must be at least %d characters", minAdminTokenLength)
+	}
+	return nil
+}
+
+// String renders the configuration as YAML. Secrets are typed as Secret or
+// DSN and therefore appear redacted.
+func (c Config) String() string {
+	out, err := yaml.Marshal(c)
+	if err != nil {
+		return fmt.Sprintf("<config: %v>", err)
+	}
+	return string(out)
+}
@@ -0,0 +1,49 @@
+package reviewapi
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestLoadConfigPrecedence checks that each source overrides the previous
+// one: defaults, then the config file, then the environment, then flags.
+func TestLoadConfigPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	file := "database:\n  host: file-host\n  name: file-name\n  user: file-user\nserver:\n  read_timeout: 20s\n"
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatalf("write config file: %v", err)
+	}
+	// Settings left to the file and defaults must not come from the
+	// environment running the test
+	for _, env := range []string{"DB_HOST", "DB_PORT"} {
+		t.Setenv(env, "")
+		os.Unsetenv(env)
+	}
+	t.Setenv("DB_NAME", "env-name")
+	t.Setenv("DB_USER", "env-user")
+	t.Setenv("SERVER_READ_TIMEOUT", "25s")
+
+	cfg, opts, err := LoadConfig([]string{"-config", path, "-database.user", "flag-user", "-server.read_timeout", "30s"})
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if opts.ConfigFile != path {
+		t.Errorf("ConfigFile %q, want %q", opts.ConfigFile, path)
+	}
+	for _, tc := range []struct {
+		setting   string
+		got, want any
+	}{
+		{"database.port (default)", cfg.Database.Port, 5432},
+		{"database.host (file)", cfg.Database.Host, "file-host"},
+		{"database.name (env over file)", cfg.Database.Name, "env-name"},
+		{"database.user (flag over env and file)", cfg.Database.User, "flag-user"},
+		{"server.read_timeout (flag over env and file)", cfg.Server.ReadTimeout, 30 * time.Second},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("%s = %v, want %v", tc.setting, tc.got, tc.want)
+		}
+	}
+}
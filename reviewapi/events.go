@@ -0,0 +1,821 @@
+// Package reviewapi provides the review event stream for the Movie Review API.
+// This file implements GET /events, a Server-Sent Events stream of the
+// review changes recorded in the outbox (outbox.go), and the feeds that
+// bring committed events to it on each backend.
+//
+// Behaviour:
+//   - PostgreSQL announces every outbox event with NOTIFY (a trigger on
+//     outbox_events); each instance LISTENs on a dedicated connection, so
+//     clients see the changes made through any instance. The trigger is on
+//     the outbox rather than on reviews: the event ID it announces is what
+//     clients resume from, only the outbox row exists for every change with
+//     its type and payload, and purges change reviews without an event
+//   - SQLite is written by a single process, so SqliteDb broadcasts the
+//     events it commits in process
+//   - An EventBroadcaster fans the events out to the connected clients; a
+//     client that falls behind is disconnected rather than slowing the others
+//   - Event IDs are assigned in commit order (see eventOrderLockID), so
+//     the events after a given ID are exactly those not seen yet
+//   - Clients resume with the Last-Event-ID header (sent automatically by
+//     EventSource on reconnect): the events after it are replayed from the
+//     outbox, for as long as the outbox keeps them (webhooks.retention). A
+//     client resuming from an event older than that first gets a gap event
+//
+// API Endpoints:
+//   - GET /events - Stream review changes, optionally for one review or director
+package reviewapi
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// reviewEventsChannel is the PostgreSQL NOTIFY channel announcing the ID of
+// every event written to the outbox (see migration 0007).
+const reviewEventsChannel = "review_events"
+
+// eventPageSize is the number of events read from the outbox at once, when
+// replaying or catching up.
+const eventPageSize = 500
+
+// eventListenerPing is how often the LISTEN connection is checked while no
+// notification arrives, so a silently dropped connection is noticed.
+const eventListenerPing = 90 * time.Second
+
+// eventColumns are the outbox_events columns read by scanReviewEvent, in order.
+const eventColumns = "id, eventType, createdAt, reviewId, payload"
+
+// EventsConfig holds the review event stream settings.
+type EventsConfig struct {
+	// Enabled serves GET /events and starts the event feed.
+	Enabled bool `yaml:"enabled"`
+
+	// KeepAlive is how often an idle stream sends a comment, so that
+	// proxies do not close it.
+	KeepAlive time.Duration `yaml:"keepalive"`
+
+	// BufferSize is the number of events queued for a client before it is
+	// disconnected as too slow.
+	BufferSize int `yaml:"buffer_size"`
+}
+
+// Validate checks the event stream settings.
+func (c EventsConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.KeepAlive <= 0 {
+		return fmt.Errorf("keepalive must be positive")
+	}
+	if c.BufferSize < 1 {
+		return fmt.Errorf("buffer_size must be at least 1")
+	}
+	return nil
+}
+
+// EventsFromNow is the afterID of PublishReviewEvents starting with the
+// events committed from now on, rather than after a given event.
+const EventsFromNow int64 = -1
+
+// EventLog is implemented by the backends to read the review events of the
+// outbox, which GET /events replays to clients resuming a stream.
+type EventLog interface {
+	// ListReviewEvents returns up to limit events of the outbox with an ID
+	// greater than afterID, in ID order.
+	ListReviewEvents(ctx context.Context, afterID int64, limit int) ([]*ReviewEvent, error)
+
+	// OldestReviewEventID returns the ID of the oldest event the outbox
+	// still keeps, or the ID the next event will get when it keeps none.
+	OldestReviewEventID(ctx context.Context) (int64, error)
+}
+
+// eventGapType is the type of the event telling a resuming client that
+// events after its Last-Event-ID were pruned from the outbox.
+const eventGapType = "gap"
+
+// EventGap is the data of a gap event: the events after LastEventID and
+// before OldestEventID may have been missed, and the client should reload
+// the reviews it follows.
+type EventGap struct {
+	// LastEventID is the Last-Event-ID the client resumed from.
+	LastEventID int64 `json:"lastEventId"`
+
+	// OldestEventID is the oldest event that could still be replayed.
+	OldestEventID int64 `json:"oldestEventId"`
+}
+
+// ReviewEventSource is implemented by the backends to feed the review
+// events they commit to an EventBroadcaster.
+type ReviewEventSource interface {
+	// PublishReviewEvents publishes the events committed after afterID
+	// (or from now on, with EventsFromNow) to broadcaster until ctx is
+	// done. It returns the last event it published, so that a restarted
+	// feed resumes without a gap, and ErrNotReady before Connect has
+	// completed.
+	PublishReviewEvents(ctx context.Context, broadcaster *EventBroadcaster, afterID int64) (int64, error)
+}
+
+// EventBroadcaster fans review events out to the subscribed event streams.
+// It is safe for concurrent use.
+type EventBroadcaster struct {
+	bufferSize int
+	metrics    *Metrics
+
+	mu            sync.Mutex
+	subscriptions map[*EventSubscription]struct{}
+}
+
+// EventSubscription receives the events published after it was created.
+type EventSubscription struct {
+	broadcaster *EventBroadcaster
+
+	// events queues the published events.
+	events chan *ReviewEvent
+
+	// dropped is closed when the queue overflowed; the subscriber must
+	// stop and resume from the last event it handled.
+	dropped chan struct{}
+}
+
+// NewEventBroadcaster creates a broadcaster queueing up to bufferSize events
+// per subscriber.
+//
+// Parameters:
+//   - bufferSize: The number of events queued for each subscriber
+//   - metrics: Metrics registry for the subscriber gauge (may be nil)
+//
+// Returns:
+//   - *EventBroadcaster: A broadcaster without subscribers
+func NewEventBroadcaster(bufferSize int, metrics *Metrics) *EventBroadcaster {
+	return &EventBroadcaster{
+		bufferSize:    bufferSize,
+		metrics:       metrics,
+		subscriptions: make(map[*EventSubscription]struct{}),
+	}
+}
+
+// Subscribe returns a subscription to the events published from now on.
+// Close it when done.
+func (b *EventBroadcaster) Subscribe() *EventSubscription {
+	subscription := &EventSubscription{
+		broadcaster: b,
+		events:      make(chan *ReviewEvent, b.bufferSize),
+		dropped:     make(chan struct{}),
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscriptions[subscription] = struct{}{}
+	b.metrics.setEventSubscribers(len(b.subscriptions))
+	return subscription
+}
+
+// Publish queues event for every subscriber. Subscribers whose queue is full
+// are dropped instead of blocking the others.
+func (b *EventBroadcaster) Publish(event *ReviewEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for subscription := range b.subscriptions {
+		select {
+		case subscription.events <- event:
+		default:
+			delete(b.subscriptions, subscription)
+			close(subscription.dropped)
+			b.metrics.eventSubscriberDropped()
+		}
+	}
+	b.metrics.setEventSubscribers(len(b.subscriptions))
+}
+
+// Events returns the queue of published events.
+func (s *EventSubscription) Events() <-chan *ReviewEvent {
+	return s.events
+}
+
+// Dropped returns a channel closed when the queue overflowed; the subscriber
+// must then stop and resume from the last event it handled.
+func (s *EventSubscription) Dropped() <-chan struct{} {
+	return s.dropped
+}
+
+// Close ends the subscription.
+func (s *EventSubscription) Close() {
+	b := s.broadcaster
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subscriptions, s)
+	b.metrics.setEventSubscribers(len(b.subscriptions))
+}
+
+// StartEventFeed publishes the events committed to source to broadcaster
+// until stop is called. The feed is restarted with backoff when it fails,
+// and waits while the backend is still connecting. A restarted feed resumes
+// after the last event published, so the events committed during the
+// backoff reach the connected streams too.
+//
+// Parameters:
+//   - source: The backend whose events are published
+//   - broadcaster: The broadcaster of the event streams
+//   - logger: Structured logger for feed failures
+//
+// Returns:
+//   - stop: Stops the feed and waits for it to finish
+func StartEventFeed(source ReviewEventSource, broadcaster *EventBroadcaster, logger *slog.Logger) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	retry := BackoffConfig{InitialInterval: time.Second, MaxInterval: 30 * time.Second}
+
+	go func() {
+		defer close(done)
+		lastID := EventsFromNow
+		for attempt := 0; ; attempt++ {
+			var err error
+			lastID, err = source.PublishReviewEvents(ctx, broadcaster, lastID)
+			if ctx.Err() != nil {
+				return
+			}
+			if !errors.Is(err, ErrNotReady) {
+				logger.Warn("review event feed failed, restarting", "component", "events", "error", err)
+			}
+
+			timer := time.NewTimer(retry.delay(attempt))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// scanReviewEvent reads the eventColumns of row into event.
+func scanReviewEvent(row rowScanner, event *ReviewEvent) error {
+	var payload []byte
+	err := row.Scan(
+		&event.ID,
+		&event.Type,
+		&event.OccurredAt,
+		&event.ReviewID,
+		&payload)
+	event.Review = json.RawMessage(payload)
+	return err
+}
+
+// scanReviewEvents reads every event of rows and closes them. It returns an
+// empty slice rather than nil when there is none.
+func scanReviewEvents(rows *sql.Rows) ([]*ReviewEvent, error) {
+	defer rows.Close()
+	events := []*ReviewEvent{}
+	for rows.Next() {
+		event := &ReviewEvent{}
+		if err := scanReviewEvent(rows, event); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// ListReviewEvents returns up to limit events of the outbox with an ID
+// greater than afterID, in ID order.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout control
+//   - afterID: The ID of the last event already seen (0 for the oldest)
+//   - limit: The maximum number of events returned
+//
+// Returns:
+//   - []*ReviewEvent: The events, empty if there is none
+//   - error: Non-nil if the query fails
+func (pg *PgDb) ListReviewEvents(ctx context.Context, afterID int64, limit int) (_ []*ReviewEvent, err error) {
+	ctx, finish := pg.beginOperation(ctx, "listEvents", "SELECT", 0)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !pg.ready.Load() {
+		return nil, ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, pg.timeouts.Get)
+	defer cancel()
+
+	// Served by a caught-up replica if any; a stale statement is retried once
+	var events []*ReviewEvent
+	err = pg.withReadStatement(ctx, pg.stmtListEvents, func(s *replicaStatements) *preparedStmt { return s.listEvents }, func(stmt *sql.Stmt) error {
+		rows, err := stmt.QueryContext(ctx, afterID, limit)
+		if err != nil {
+			return err
+		}
+		events, err = scanReviewEvents(rows)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list review events: %w", contextError(ctx, err))
+	}
+	return events, nil
+}
+
+// OldestReviewEventID returns the ID of the oldest event the outbox keeps,
+// or the ID of the next event when it is empty.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout control
+//
+// Returns:
+//   - int64: The ID; events with a lower ID can no longer be replayed
+//   - error: Non-nil if the query fails
+func (pg *PgDb) OldestReviewEventID(ctx context.Context) (_ int64, err error) {
+	ctx, finish := pg.beginOperation(ctx, "oldestEvent", "SELECT", 0)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !pg.ready.Load() {
+		return 0, ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, pg.timeouts.Get)
+	defer cancel()
+
+	// Always on the primary: a replica's copy of the sequence may run ahead
+	var id int64
+	err = pg.withStatement(ctx, pg.stmtOldestEvent, true, func(stmt *sql.Stmt) error {
+		return stmt.QueryRowContext(ctx).Scan(&id)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to read the oldest review event: %w", contextError(ctx, err))
+	}
+	return id, nil
+}
+
+// latestEventID returns the ID of the newest event of the outbox, or 0.
+func (pg *PgDb) latestEventID(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, pg.timeouts.Get)
+	defer cancel()
+	var id int64
+	err := pg.withStatement(ctx, pg.stmtLatestEvent, true, func(stmt *sql.Stmt) error {
+		return stmt.QueryRowContext(ctx).Scan(&id)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to read the latest review event: %w", contextError(ctx, err))
+	}
+	return id, nil
+}
+
+// PublishReviewEvents LISTENs for the events announced by the outbox
+// trigger, on a connection of its own, and publishes each of them to
+// broadcaster. LISTEN runs first, so an event committed at any time after
+// is either announced or found by the catch-up that follows. After the
+// connection was lost and re-established, the events written meanwhile are
+// read from the outbox.
+//
+// Parameters:
+//   - ctx: Context that stops the feed
+//   - broadcaster: Where the events are published
+//   - afterID: The last event already published, or EventsFromNow
+//
+// Returns:
+//   - int64: The last event published, to resume from
+//   - error: nil once ctx is done; non-nil if the client is not connected
+//     yet or reading the outbox fails
+func (pg *PgDb) PublishReviewEvents(ctx context.Context, broadcaster *EventBroadcaster, afterID int64) (int64, error) {
+	if !pg.ready.Load() {
+		return afterID, ErrNotReady
+	}
+
+	// Announced events must be read where they were committed, not from a
+	// replica that has not replayed them yet
+	ctx = contextWithPrimaryReads(ctx)
+
+	// The listener reconnects by itself; only report why it had to
+	onEvent := func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			pg.logger.Warn("review event listener connection lost", "error", err)
+		}
+	}
+	listener := pq.NewListener(pg.connStr, time.Second, time.Minute, onEvent)
+	defer listener.Close()
+	if err := listener.Listen(reviewEventsChannel); err != nil {
+		return afterID, fmt.Errorf("listen for review events: %w", err)
+	}
+
+	lastID := afterID
+	if lastID == EventsFromNow {
+		id, err := pg.latestEventID(ctx)
+		if err != nil {
+			return afterID, err
+		}
+		lastID = id
+	}
+
+	// caughtUp holds the events published by the last catch-up, whose
+	// notifications may still be queued: they are not published twice
+	caughtUp := map[int64]bool{}
+
+	// publishAfter publishes the events after lastID, up to the newest
+	publishAfter := func() error {
+		clear(caughtUp)
+		for {
+			events, err := pg.ListReviewEvents(ctx, lastID, eventPageSize)
+			if err != nil {
+				return err
+			}
+			for _, event := range events {
+				broadcaster.Publish(event)
+				caughtUp[event.ID] = true
+				lastID = max(lastID, event.ID)
+			}
+			if len(events) < eventPageSize {
+				return nil
+			}
+		}
+	}
+
+	// Publish what was committed while the feed was not listening
+	if err := publishAfter(); err != nil {
+		return lastID, err
+	}
+
+	ping := time.NewTicker(eventListenerPing)
+	defer ping.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return lastID, nil
+		case <-ping.C:
+			go listener.Ping()
+		case notification := <-listener.Notify:
+			// A nil notification follows a reconnect: catch up on the gap
+			if notification == nil {
+				if err := publishAfter(); err != nil {
+					return lastID, err
+				}
+				continue
+			}
+
+			// The notification only carries the ID; read the event itself
+			id, err := strconv.ParseInt(notification.Extra, 10, 64)
+			if err != nil {
+				pg.logger.Warn("malformed review event notification", "payload", notification.Extra)
+				continue
+			}
+			if caughtUp[id] {
+				delete(caughtUp, id)
+				continue
+			}
+			events, err := pg.ListReviewEvents(ctx, id-1, 1)
+			if err != nil {
+				return lastID, err
+			}
+			if len(events) == 1 && events[0].ID == id {
+				broadcaster.Publish(events[0])
+				lastID = max(lastID, id)
+			}
+		}
+	}
+}
+
+// eventWritten wakes the event feed after a committed change wrote an event
+// to the outbox. It never blocks: a pending wake-up covers several changes.
+func (s *SqliteDb) eventWritten() {
+	select {
+	case s.eventsWritten <- struct{}{}:
+	default:
+	}
+}
+
+// ListReviewEvents returns up to limit events of the outbox with an ID
+// greater than afterID, in ID order (see PgDb.ListReviewEvents).
+func (s *SqliteDb) ListReviewEvents(ctx context.Context, afterID int64, limit int) (_ []*ReviewEvent, err error) {
+	ctx, finish := s.beginOperation(ctx, "listEvents", "SELECT", 0)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !s.ready.Load() {
+		return nil, ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Get)
+	defer cancel()
+
+	rows, err := s.stmtListEvents.QueryContext(ctx, afterID, limit)
+	var events []*ReviewEvent
+	if err == nil {
+		events, err = scanReviewEvents(rows)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list review events: %w", contextError(ctx, err))
+	}
+	return events, nil
+}
+
+// OldestReviewEventID returns the ID of the oldest event the outbox keeps,
+// or the ID of the next event when it is empty (see PgDb.OldestReviewEventID).
+func (s *SqliteDb) OldestReviewEventID(ctx context.Context) (_ int64, err error) {
+	ctx, finish := s.beginOperation(ctx, "oldestEvent", "SELECT", 0)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !s.ready.Load() {
+		return 0, ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Get)
+	defer cancel()
+
+	var id int64
+	if err := s.stmtOldestEvent.QueryRowContext(ctx).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to read the oldest review event: %w", contextError(ctx, err))
+	}
+	return id, nil
+}
+
+// PublishReviewEvents publishes the events written by this client's changes
+// to broadcaster (see ReviewEventSource). Writes are serialized, so events
+// commit in ID order and are read from the outbox after each change.
+func (s *SqliteDb) PublishReviewEvents(ctx context.Context, broadcaster *EventBroadcaster, afterID int64) (int64, error) {
+	if !s.ready.Load() {
+		return afterID, ErrNotReady
+	}
+	lastID := afterID
+	if lastID == EventsFromNow {
+		if err := s.stmtLatestEvent.QueryRowContext(ctx).Scan(&lastID); err != nil {
+			return afterID, fmt.Errorf("failed to read the latest review event: %w", contextError(ctx, err))
+		}
+	}
+
+	// Publish the events written since afterID, then after each wake-up
+	for {
+		for {
+			events, err := s.ListReviewEvents(ctx, lastID, eventPageSize)
+			if err != nil {
+				return lastID, err
+			}
+			for _, event := range events {
+				broadcaster.Publish(event)
+				lastID = event.ID
+			}
+			if len(events) < eventPageSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return lastID, nil
+		case <-s.eventsWritten:
+		}
+	}
+}
+
+// eventFilter selects the events streamed to a client.
+type eventFilter struct {
+	// reviewID selects the events of one review (0 selects all).
+	reviewID int
+
+	// director selects the events of reviews of one director, compared
+	// case-insensitively ("" selects all).
+	director string
+}
+
+// parseEventFilter builds an eventFilter from the query parameters of a
+// GET /events request.
+func parseEventFilter(request *http.Request) (eventFilter, error) {
+	params := request.URL.Query()
+	filter := eventFilter{director: strings.TrimSpace(params.Get("director"))}
+	if raw := params.Get("review"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 1 {
+			return filter, fmt.Errorf("invalid review %q: must be a positive review id", raw)
+		}
+		filter.reviewID = value
+	}
+	return filter, nil
+}
+
+// matches reports whether event passes the filter.
+func (f eventFilter) matches(event *ReviewEvent) bool {
+	if f.reviewID != 0 && event.ReviewID != f.reviewID {
+		return false
+	}
+	if f.director != "" {
+		var review struct {
+			Director string `json:"director"`
+		}
+		if json.Unmarshal(event.Review, &review) != nil || !strings.EqualFold(review.Director, f.director) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseLastEventID reads the Last-Event-ID header of a GET /events request.
+// resume is false when the header is absent.
+func parseLastEventID(request *http.Request) (id int64, resume bool, err error) {
+	raw := request.Header.Get("Last-Event-ID")
+	if raw == "" {
+		return 0, false, nil
+	}
+	id, err = strconv.ParseInt(raw, 10, 64)
+	if err != nil || id < 0 {
+		return 0, false, fmt.Errorf("invalid Last-Event-ID %q: must be an event id", raw)
+	}
+	return id, true, nil
+}
+
+// eventStream writes review events in the Server-Sent Events format.
+type eventStream struct {
+	writer     http.ResponseWriter
+	controller *http.ResponseController
+	filter     eventFilter
+}
+
+// send writes event, if it passes the filter, and flushes it to the client.
+func (s *eventStream) send(event *ReviewEvent) error {
+	if !s.filter.matches(event) {
+		return nil
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encode review event: %w", err)
+	}
+	if _, err := fmt.Fprintf(s.writer, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+		return err
+	}
+	return s.controller.Flush()
+}
+
+// sendGap writes a gap event, which no filter rejects, and flushes it. It
+// has no id, so the client's Last-Event-ID is unchanged.
+func (s *eventStream) sendGap(gap EventGap) error {
+	data, err := json.Marshal(gap)
+	if err != nil {
+		return fmt.Errorf("encode event gap: %w", err)
+	}
+	if _, err := fmt.Fprintf(s.writer, "event: %s\ndata: %s\n\n", eventGapType, data); err != nil {
+		return err
+	}
+	return s.controller.Flush()
+}
+
+// keepAlive writes a comment, which clients ignore, and flushes it.
+func (s *eventStream) keepAlive() error {
+	if _, err := fmt.Fprint(s.writer, ": keepalive\n\n"); err != nil {
+		return err
+	}
+	return s.controller.Flush()
+}
+
+// handleEvents handles GET /events requests.
+// It streams review changes as Server-Sent Events until the client
+// disconnects or the server shuts down. Each event is sent as
+//
+//	id: <event id>
+//	event: <review.created, review.updated, review.deleted or review.restored>
+//	data: <the event as JSON, see ReviewEvent>
+//
+// When the events right after Last-Event-ID are no longer kept, the replay
+// starts with
+//
+//	event: gap
+//	data: {"lastEventId": <Last-Event-ID>, "oldestEventId": <first event kept>}
+//
+// Query Parameters:
+//   - review: Only events of the review with this ID (optional)
+//   - director: Only events of reviews of this director, case-insensitive
+//     (optional)
+//
+// Headers:
+//   - Last-Event-ID: Replay the events after this one before streaming
+//     (optional; EventSource sends it on reconnect)
+//
+// Response:
+//   - 200 OK: The event stream (text/event-stream)
+//   - 400 Bad Request: If a filter or Last-Event-ID is invalid
+//   - 499/503/504: If the replay is cancelled or times out (see statusForError)
+//
+// Example Request:
+//
+//	GET /events?director=Christopher%20Nolan
+//	Last-Event-ID: 311
+func (server *APIServer) handleEvents(writer http.ResponseWriter, request *http.Request) error {
+	filter, err := parseEventFilter(request)
+	if err != nil {
+		return err
+	}
+	lastID, resume, err := parseLastEventID(request)
+	if err != nil {
+		return err
+	}
+
+	// Subscribe before reading the outbox, so that no event is committed
+	// between the replay and the live stream unseen
+	subscription := server.events.Subscribe()
+	defer subscription.Close()
+
+	ctx := request.Context()
+	var replay []*ReviewEvent
+	var gap *EventGap
+	if resume {
+		if replay, err = server.eventLog.ListReviewEvents(ctx, lastID, eventPageSize); err != nil {
+			return err
+		}
+
+		// Read the oldest event after the replay: events pruned meanwhile
+		// are in the replay already
+		oldest, err := server.eventLog.OldestReviewEventID(ctx)
+		if err != nil {
+			return err
+		}
+		if len(replay) > 0 {
+			oldest = min(oldest, replay[0].ID)
+		}
+		// A rolled back change also leaves an unused ID, so a gap may be
+		// reported for events that never existed; never the reverse
+		if oldest > lastID+1 {
+			gap = &EventGap{LastEventID: lastID, OldestEventID: oldest}
+		}
+	}
+
+	// The stream outlives the server's write timeout
+	controller := http.NewResponseController(writer)
+	controller.SetWriteDeadline(time.Time{})
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.Header().Set("X-Accel-Buffering", "no")
+	writer.WriteHeader(http.StatusOK)
+	stream := &eventStream{writer: writer, controller: controller, filter: filter}
+
+	// Errors can no longer be reported once streaming; the client
+	// reconnects and resumes from the last event it received
+	if gap != nil {
+		if err := stream.sendGap(*gap); err != nil {
+			return nil
+		}
+	}
+	for resume {
+		for _, event := range replay {
+			if err := stream.send(event); err != nil {
+				return nil
+			}
+			lastID = event.ID
+		}
+		if len(replay) < eventPageSize {
+			break
+		}
+		if replay, err = server.eventLog.ListReviewEvents(ctx, lastID, eventPageSize); err != nil {
+			server.logger.WarnContext(ctx, "replay review events", "error", err)
+			return nil
+		}
+	}
+	if err := controller.Flush(); err != nil {
+		return nil
+	}
+
+	keepAlive := time.NewTicker(server.eventsKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-server.streamsDone:
+			return nil
+		case <-subscription.dropped:
+			return nil
+		case <-keepAlive.C:
+			if err := stream.keepAlive(); err != nil {
+				return nil
+			}
+		case event := <-subscription.events:
+			// Skip the events already replayed
+			if resume && event.ID <= lastID {
+				continue
+			}
+			if err := stream.send(event); err != nil {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,7 @@
+package reviewapi
+
+// Test helpers for the external reviewapi_test package.
+var (
+	NewTestSqliteDb = newTestSqliteDb
+	NewTestPgDb     = newTestPgDb
+)
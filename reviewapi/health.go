@@ -0,0 +1,124 @@
+// Package reviewapi provides health endpoints for the Movie Review API.
+// This file implements the liveness (/healthz) and readiness (/readyz)
+// probes used by orchestrators and load balancers.
+//
+// Liveness only reports that the process is serving HTTP. Readiness also
+// verifies the database and flips to not-ready as soon as graceful shutdown
+// begins, so load balancers stop routing traffic before connections close.
+package reviewapi
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// readinessTimeout bounds the checks performed for one readiness probe.
+const readinessTimeout = 2 * time.Second
+
+// ReadinessChecker is implemented by components that can tell whether the
+// service is able to handle requests (e.g. PgDb).
+type ReadinessChecker interface {
+	// CheckReady returns nil when ready, or an error describing why not.
+	CheckReady(ctx context.Context) error
+}
+
+// CheckReady verifies that Connect has completed, that the database is
+// reachable, that every prepared statement exists and still works on the
+// server, and that the schema is at the version this build expects.
+func (pg *PgDb) CheckReady(ctx context.Context) error {
+	if !pg.ready.Load() {
+		return fmt.Errorf("database connecting: %w", ErrNotReady)
+	}
+	if err := pg.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("database unreachable: %w", err)
+	}
+
+	for name, stmt := range map[string]*preparedStmt{
+		"create":             pg.stmtCreate,
+		"update":             pg.stmtUpdate,
+		"delete":             pg.stmtDelete,
+		"getById":            pg.stmtGetById,
+		"listDeleted":        pg.stmtListDeleted,
+		"restore":            pg.stmtRestore,
+		"purge":              pg.stmtPurge,
+		"lockById":           pg.stmtLockById,
+		"insertRevision":     pg.stmtInsertRevision,
+		"listRevisions":      pg.stmtListRevisions,
+		"getRevision":        pg.stmtGetRevision,
+		"insertAudit":        pg.stmtInsertAudit,
+		"listAudit":          pg.stmtListAudit,
+		"insertEvent":        pg.stmtInsertEvent,
+		"fanOutEvents":       pg.stmtFanOutEvents,
+		"claimDeliveries":    pg.stmtClaimDeliveries,
+		"leaseDeliveries":    pg.stmtLeaseDeliveries,
+		"completeDelivery":   pg.stmtCompleteDelivery,
+		"pruneOutbox":        pg.stmtPruneOutbox,
+		"listDeadDeliveries": pg.stmtListDeadDeliveries,
+		"retryDelivery":      pg.stmtRetryDelivery,
+		"listEvents":         pg.stmtListEvents,
+		"latestEvent":        pg.stmtLatestEvent,
+		"oldestEvent":        pg.stmtOldestEvent,
+	} {
+		if stmt == nil || stmt.get() == nil {
+			return fmt.Errorf("prepared statement %s missing", name)
+		}
+	}
+
+	// Run a statement on the server: a backend that lost it (e.g. after a
+	// failover) answers 26000, and a failed re-preparation makes us not ready
+	var review Review
+	err := pg.withStatement(ctx, pg.stmtGetById, true, func(stmt *sql.Stmt) error {
+		return scanReview(stmt.QueryRowContext(ctx, -1), &review)
+	})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("prepared statement getById failed: %w", err)
+	}
+
+	current, err := pg.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if current < pg.schemaVersion {
+		return fmt.Errorf("schema version %d behind expected %d", current, pg.schemaVersion)
+	}
+	return nil
+}
+
+// handleHealthz handles GET /healthz (liveness).
+// It always returns 200 while the process can serve HTTP.
+//
+// Example Response:
+//
+//	{"status": "ok"}
+func (server *APIServer) handleHealthz(writer http.ResponseWriter, request *http.Request) {
+	WriteJSON(writer, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleReadyz handles GET /readyz (readiness).
+//
+// Response:
+//   - 200 OK: {"status": "ready"}
+//   - 503 Service Unavailable: {"status": "shutting down"} once graceful
+//     shutdown has begun, or {"status": "not ready"} when a readiness check
+//     fails; the reason is logged, not sent, since the probe is public
+func (server *APIServer) handleReadyz(writer http.ResponseWriter, request *http.Request) {
+	if server.shuttingDown.Load() {
+		WriteJSON(writer, http.StatusServiceUnavailable, map[string]string{"status": "shutting down"})
+		return
+	}
+
+	if server.readiness != nil {
+		ctx, cancel := context.WithTimeout(request.Context(), readinessTimeout)
+		defer cancel()
+		if err := server.readiness.CheckReady(ctx); err != nil {
+			server.logger.WarnContext(ctx, "readiness check failed", "error", err)
+			WriteJSON(writer, http.StatusServiceUnavailable, map[string]string{"status": "not ready"})
+			return
+		}
+	}
+	WriteJSON(writer, http.StatusOK, map[string]string{"status": "ready"})
+}
@@ -0,0 +1,177 @@
+// Package reviewapi provides HTTP response caching for the Movie Review API.
+// This file sets Cache-Control headers from per-route policies and answers
+// conditional requests (If-None-Match, If-Modified-Since) with 304 Not
+// Modified, so that browsers and CDNs can cache read traffic and revalidate
+// it cheaply.
+//
+// Policies are keyed by chi route pattern (e.g. "/review/{id}") and only
+// apply to successful GET and HEAD responses (200 and 304). Every other
+// response, including errors, is sent with "Cache-Control: no-store".
+package reviewapi
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// validateCachePolicies checks the per-route Cache-Control policies.
+func validateCachePolicies(policies map[string]string) error {
+	for route, policy := range policies {
+		if !strings.HasPrefix(route, "/") {
+			return fmt.Errorf("cache policy route %q must start with /", route)
+		}
+		if strings.ContainsAny(policy, "\r\n") {
+			return fmt.Errorf("cache policy for %q must be a single line", route)
+		}
+	}
+	return nil
+}
+
+// parseCachePolicies parses "route=policy" pairs separated by semicolons,
+// e.g. "/review/{id}=public, max-age=60;/healthz=no-store". Cache-Control
+// values use commas, so pairs are separated by semicolons instead.
+func parseCachePolicies(raw string) (map[string]string, error) {
+	policies := make(map[string]string)
+	for _, pair := range strings.Split(raw, ";") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		route, policy, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected route=policy, got %q", pair)
+		}
+		policies[strings.TrimSpace(route)] = strings.TrimSpace(policy)
+	}
+	return policies, nil
+}
+
+// cacheControlWriter sets the Cache-Control header of the matched route's
+// policy just before the response header is written, once chi has resolved
+// the route pattern.
+type cacheControlWriter struct {
+	http.ResponseWriter
+
+	request     *http.Request
+	policies    map[string]string
+	wroteHeader bool
+}
+
+// WriteHeader applies the policy before delegating.
+func (w *cacheControlWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.applyPolicy(status)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write applies the policy for an implicit 200 before delegating.
+func (w *cacheControlWriter) Write(body []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(body)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (w *cacheControlWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// applyPolicy sets Cache-Control unless the handler already did.
+func (w *cacheControlWriter) applyPolicy(status int) {
+	header := w.ResponseWriter.Header()
+	if header.Get("Cache-Control") != "" {
+		return
+	}
+
+	cacheable := (w.request.Method == http.MethodGet || w.request.Method == http.MethodHead) &&
+		(status == http.StatusOK || status == http.StatusNotModified)
+	if cacheable {
+		if routeContext := chi.RouteContext(w.request.Context()); routeContext != nil {
+			if policy := w.policies[routeContext.RoutePattern()]; policy != "" {
+				header.Set("Cache-Control", policy)
+				return
+			}
+		}
+	}
+	header.Set("Cache-Control", "no-store")
+}
+
+// cacheControlMiddleware sets Cache-Control on every response from the
+// per-route policies.
+//
+// It must run inside the chi router (router.Use) so that the route pattern
+// is available when the response is written.
+func cacheControlMiddleware(policies map[string]string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			next.ServeHTTP(&cacheControlWriter{ResponseWriter: writer, request: request, policies: policies}, request)
+		})
+	}
+}
+
+// entityTag returns the weak ETag of a resource last changed at modified.
+// Every change moves modified, at full precision, so it identifies the
+// version of the resource.
+func entityTag(modified time.Time) string {
+	return fmt.Sprintf(`W/"%x"`, modified.UnixNano())
+}
+
+// matchesEntityTag reports whether the If-None-Match value header lists etag
+// or is "*", comparing tags weakly (ignoring the W/ prefix).
+func matchesEntityTag(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkNotModified sets ETag and Last-Modified and reports whether the
+// client's copy, described by If-None-Match or else If-Modified-Since, is
+// still current. If so it has already written 304 Not Modified and the
+// caller must not write a body.
+//
+// HTTP dates have one-second precision, so a change in the same second as
+// the client's copy keeps the same Last-Modified. If-Modified-Since is
+// therefore compared with the full-precision modified time: a review changed
+// after the start of the second the client has is sent again, and clients
+// keeping the ETag revalidate exactly.
+//
+// Parameters:
+//   - writer: The response writer
+//   - request: The request, possibly carrying If-None-Match or If-Modified-Since
+//   - modified: When the resource last changed
+//
+// Returns:
+//   - bool: true if 304 was written
+func checkNotModified(writer http.ResponseWriter, request *http.Request, modified time.Time) bool {
+	if modified.IsZero() {
+		return false
+	}
+	etag := entityTag(modified)
+	writer.Header().Set("ETag", etag)
+	writer.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+
+	// If-None-Match takes precedence over If-Modified-Since (RFC 9110 13.2.2)
+	if match := request.Header.Get("If-None-Match"); match != "" {
+		if !matchesEntityTag(match, etag) {
+			return false
+		}
+	} else {
+		since, err := http.ParseTime(request.Header.Get("If-Modified-Since"))
+		if err != nil || modified.After(since) {
+			return false
+		}
+	}
+	writer.WriteHeader(http.StatusNotModified)
+	return true
+}
@@ -0,0 +1,104 @@
+// Package reviewapi provides structured logging for the Movie Review API.
+// This file builds the application's log/slog logger from configuration.
+// The logger is created once in main and injected into the storage layer,
+// the HTTP server and the domain constructors.
+//
+// Records logged with a context (e.g. logger.InfoContext(ctx, ...)) carry the
+// request ID and trace/span IDs stored in that context, so storage log lines
+// can be correlated with the access log entry and the trace of the request
+// that caused them.
+package reviewapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// LogConfig holds the logging settings.
+type LogConfig struct {
+	// Level is the minimum level logged: debug, info, warn or error.
+	Level string `yaml:"level"`
+
+	// Format selects the output encoding: json or text.
+	Format string `yaml:"format"`
+}
+
+// parseLogLevel converts a level name into a slog.Level.
+func parseLogLevel(level string) (slog.Level, error) {
+	var parsed slog.Level
+	if err := parsed.UnmarshalText([]byte(strings.TrimSpace(level))); err != nil {
+		return 0, fmt.Errorf("unknown log level %q (use debug, info, warn or error)", level)
+	}
+	return parsed, nil
+}
+
+// Validate checks the logging settings.
+func (c LogConfig) Validate() error {
+	if _, err := parseLogLevel(c.Level); err != nil {
+		return err
+	}
+	switch c.Format {
+	case "json", "text":
+		return nil
+	default:
+		return fmt.Errorf("unknown log format %q (use json or text)", c.Format)
+	}
+}
+
+// NewLogger creates the application logger writing to out.
+//
+// Parameters:
+//   - cfg: Level and format settings
+//   - out: Destination of log records (usually os.Stderr)
+//
+// Returns:
+//   - *slog.Logger: The configured logger
+//   - error: Non-nil if the level or format is invalid
+func NewLogger(cfg LogConfig, out io.Writer) (*slog.Logger, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	level, _ := parseLogLevel(cfg.Level)
+	options := &slog.HandlerOptions{Level: level}
+
+	if cfg.Format == "text" {
+		return slog.New(contextHandler{slog.NewTextHandler(out, options)}), nil
+	}
+	return slog.New(contextHandler{slog.NewJSONHandler(out, options)}), nil
+}
+
+// contextHandler is a slog.Handler that adds request scoped attributes found
+// in the record's context (the request ID and active trace span) to every record.
+type contextHandler struct {
+	slog.Handler
+}
+
+// Handle adds the request_id, trace_id and span_id attributes when the
+// context carries them.
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(
+			slog.String("trace_id", spanContext.TraceID().String()),
+			slog.String("span_id", spanContext.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs keeps the context handling when attributes are added.
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup keeps the context handling when a group is opened.
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,520 @@
+// Package reviewapi provides Prometheus metrics for the Movie Review API.
+// This file defines the application's metrics, the HTTP middleware and
+// store decorators that record them, and the /metrics endpoint handler.
+//
+// Exposed metrics:
+//   - http_requests_total{method,route,status}: Requests served
+//   - http_request_duration_seconds{method,route,status}: Request latency
+//   - storage_operation_duration_seconds{operation,outcome}: Latency per method of the review, trash and revision stores
+//   - storage_statement_reprepares_total{statement}: Stale prepared statements prepared again
+//   - storage_statement_retries_total{statement,outcome}: Calls repeated after re-preparing
+//   - storage_retries_total{operation}: Storage calls retried after a transient error
+//   - storage_circuit_rejections_total{operation}: Calls rejected by the open circuit breaker
+//   - storage_circuit_state: Breaker state (0 closed, 1 half-open, 2 open)
+//   - review_cache_requests_total{result}: Review cache lookups (hit or miss)
+//   - review_cache_evictions_total, review_cache_entries: Cache evictions and size
+//   - storage_reads_total{target}: Reads served by the primary or each replica
+//   - storage_replica_healthy{replica}: 1 while a replica passes health checks
+//   - reviews_created_total, reviews_deleted_total: Business counters
+//   - reviews_restored_total, reviews_purged_total: Trash counters
+//   - webhook_deliveries_total{webhook,outcome}: Webhook attempts (delivered, retry or dead)
+//   - event_stream_clients, event_stream_dropped_total: Connected and dropped /events clients
+//   - go_sql_*{db_name}: Connection pool gauges from sql.DB.Stats()
+//   - go_* and process_*: Go runtime and process metrics
+package reviewapi
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// MetricsConfig holds the metrics endpoint settings.
+type MetricsConfig struct {
+	// Enabled exposes the metrics endpoint and records metrics.
+	Enabled bool `yaml:"enabled"`
+
+	// Path is the route serving the Prometheus text format.
+	Path string `yaml:"path"`
+}
+
+// Metrics holds the application's Prometheus collectors and the registry
+// they are registered with. A nil *Metrics is valid and records nothing,
+// so components do not need to check whether metrics are enabled.
+type Metrics struct {
+	// registry is private to the application so tests can create
+	// independent instances without global registration conflicts.
+	registry *prometheus.Registry
+
+	httpRequests    *prometheus.CounterVec
+	httpDuration    *prometheus.HistogramVec
+	storageDuration *prometheus.HistogramVec
+	reprepares      *prometheus.CounterVec
+	retries         *prometheus.CounterVec
+	storageRetries  *prometheus.CounterVec
+	rejections      *prometheus.CounterVec
+	breakerState    prometheus.Gauge
+	cacheRequests   *prometheus.CounterVec
+	reads           *prometheus.CounterVec
+	replicaHealthy  *prometheus.GaugeVec
+	cacheEvictions  prometheus.Counter
+	cacheEntries    prometheus.Gauge
+	reviewsCreated  prometheus.Counter
+	reviewsDeleted  prometheus.Counter
+	reviewsRestored prometheus.Counter
+	reviewsPurged   prometheus.Counter
+	webhooks        *prometheus.CounterVec
+	streamClients   prometheus.Gauge
+	streamDropped   prometheus.Counter
+}
+
+// NewMetrics creates and registers the application metrics, including the
+// Go runtime and process collectors.
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests served, by method, route pattern and status code.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latency, by method, route pattern and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		storageDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "storage_operation_duration_seconds",
+			Help:    "Storage operation latency, by operation and outcome (ok or error).",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"operation", "outcome"}),
+		reprepares: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "storage_statement_reprepares_total",
+			Help: "Prepared statements prepared again after the server invalidated them, by statement.",
+		}, []string{"statement"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "storage_statement_retries_total",
+			Help: "Calls repeated with a re-prepared statement, by statement and outcome (ok or error).",
+		}, []string{"statement", "outcome"}),
+		storageRetries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "storage_retries_total",
+			Help: "Storage calls retried after a transient error, by operation.",
+		}, []string{"operation"}),
+		rejections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "storage_circuit_rejections_total",
+			Help: "Storage calls rejected because the circuit breaker was open, by operation.",
+		}, []string{"operation"}),
+		breakerState: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "storage_circuit_state",
+			Help: "Storage circuit breaker state: 0 closed, 1 half-open, 2 open.",
+		}),
+		cacheRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "review_cache_requests_total",
+			Help: "Review cache lookups, by result (hit or miss).",
+		}, []string{"result"}),
+		cacheEvictions: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "review_cache_evictions_total",
+			Help: "Reviews evicted from the cache to make room.",
+		}),
+		cacheEntries: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "review_cache_entries",
+			Help: "Reviews currently cached.",
+		}),
+		reads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "storage_reads_total",
+			Help: "Reads by target: primary or the replica name.",
+		}, []string{"target"}),
+		replicaHealthy: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "storage_replica_healthy",
+			Help: "Whether a read replica passes its health checks (1) or not (0).",
+		}, []string{"replica"}),
+		reviewsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "reviews_created_total",
+			Help: "Reviews successfully created.",
+		}),
+		reviewsDeleted: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "reviews_deleted_total",
+			Help: "Reviews successfully deleted.",
+		}),
+		reviewsRestored: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "reviews_restored_total",
+			Help: "Reviews successfully restored from the trash.",
+		}),
+		reviewsPurged: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "reviews_purged_total",
+			Help: "Deleted reviews permanently removed after the retention period.",
+		}),
+		webhooks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "webhook_deliveries_total",
+			Help: "Webhook delivery attempts, by webhook and outcome (delivered, retry or dead).",
+		}, []string{"webhook", "outcome"}),
+		streamClients: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "event_stream_clients",
+			Help: "Clients connected to the review event stream.",
+		}),
+		streamDropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "event_stream_dropped_total",
+			Help: "Event stream clients disconnected for falling behind.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.storageDuration,
+		m.reprepares,
+		m.retries,
+		m.storageRetries,
+		m.rejections,
+		m.breakerState,
+		m.cacheRequests,
+		m.reads,
+		m.replicaHealthy,
+		m.cacheEvictions,
+		m.cacheEntries,
+		m.reviewsCreated,
+		m.reviewsDeleted,
+		m.reviewsRestored,
+		m.reviewsPurged,
+		m.webhooks,
+		m.streamClients,
+		m.streamDropped,
+	)
+	return m
+}
+
+// Handler returns the HTTP handler serving the metrics in Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// RegisterDBStats exposes the connection pool statistics of db
+// (open, in-use and idle connections, wait count and wait duration)
+// as go_sql_* gauges labeled with name.
+func (m *Metrics) RegisterDBStats(name string, db *sql.DB) error {
+	if m == nil {
+		return nil
+	}
+	return m.registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// Middleware records the request count and latency of every request.
+// It must run inside the chi router (router.Use) so that requests are
+// labeled with their route pattern rather than the raw path, which keeps
+// label cardinality bounded.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	if m == nil {
+		return next
+	}
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: writer}
+
+		next.ServeHTTP(recorder, request)
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		route := "unmatched"
+		if routeContext := chi.RouteContext(request.Context()); routeContext != nil && routeContext.RoutePattern() != "" {
+			route = routeContext.RoutePattern()
+		}
+
+		labels := prometheus.Labels{"method": request.Method, "route": route, "status": strconv.Itoa(status)}
+		m.httpRequests.With(labels).Inc()
+		m.httpDuration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+// observeStorage records the latency and outcome of a storage operation.
+func (m *Metrics) observeStorage(operation string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	m.storageDuration.WithLabelValues(operation, outcome).Observe(time.Since(start).Seconds())
+}
+
+// statementReprepared counts a stale statement that was prepared again.
+func (m *Metrics) statementReprepared(statement string) {
+	if m == nil {
+		return
+	}
+	m.reprepares.WithLabelValues(statement).Inc()
+}
+
+// statementRetried records the outcome of a call repeated after re-preparing.
+func (m *Metrics) statementRetried(statement string, err error) {
+	if m == nil {
+		return
+	}
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	m.retries.WithLabelValues(statement, outcome).Inc()
+}
+
+// storageRetried counts a storage call retried after a transient error.
+func (m *Metrics) storageRetried(operation string) {
+	if m == nil {
+		return
+	}
+	m.storageRetries.WithLabelValues(operation).Inc()
+}
+
+// circuitRejected counts a call rejected by the open circuit breaker.
+func (m *Metrics) circuitRejected(operation string) {
+	if m == nil {
+		return
+	}
+	m.rejections.WithLabelValues(operation).Inc()
+}
+
+// setBreakerState exports the circuit breaker state.
+func (m *Metrics) setBreakerState(state int) {
+	if m == nil {
+		return
+	}
+	m.breakerState.Set(float64(state))
+}
+
+// cacheLookup counts a review cache hit or miss.
+func (m *Metrics) cacheLookup(hit bool) {
+	if m == nil {
+		return
+	}
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.cacheRequests.WithLabelValues(result).Inc()
+}
+
+// cacheEvicted counts a review evicted from the cache.
+func (m *Metrics) cacheEvicted() {
+	if m == nil {
+		return
+	}
+	m.cacheEvictions.Inc()
+}
+
+// setCacheEntries exports the number of cached reviews.
+func (m *Metrics) setCacheEntries(entries int) {
+	if m == nil {
+		return
+	}
+	m.cacheEntries.Set(float64(entries))
+}
+
+// storageRead counts a read served by target ("primary" or a replica name).
+func (m *Metrics) storageRead(target string) {
+	if m == nil {
+		return
+	}
+	m.reads.WithLabelValues(target).Inc()
+}
+
+// setReplicaHealthy exports the health of a read replica.
+func (m *Metrics) setReplicaHealthy(replica string, healthy bool) {
+	if m == nil {
+		return
+	}
+	value := 0.0
+	if healthy {
+		value = 1
+	}
+	m.replicaHealthy.WithLabelValues(replica).Set(value)
+}
+
+// webhookAttempted counts an attempt at a webhook delivery by its outcome.
+func (m *Metrics) webhookAttempted(webhook, outcome string) {
+	if m == nil {
+		return
+	}
+	m.webhooks.WithLabelValues(webhook, outcome).Inc()
+}
+
+// setEventSubscribers exports the number of event stream clients.
+func (m *Metrics) setEventSubscribers(subscribers int) {
+	if m == nil {
+		return
+	}
+	m.streamClients.Set(float64(subscribers))
+}
+
+// eventSubscriberDropped counts an event stream client that fell behind.
+func (m *Metrics) eventSubscriberDropped() {
+	if m == nil {
+		return
+	}
+	m.streamDropped.Inc()
+}
+
+// instrumentedStorage is a Storage decorator that records operation
+// latencies and the business counters for any backend.
+type instrumentedStorage struct {
+	next    Storage
+	metrics *Metrics
+}
+
+// NewInstrumentedStorage wraps next so every call is measured.
+//
+// Parameters:
+//   - next: The storage backend to instrument
+//   - metrics: Where to record measurements (nil returns next unchanged)
+//
+// Returns:
+//   - Storage: The instrumented storage
+func NewInstrumentedStorage(next Storage, metrics *Metrics) Storage {
+	if metrics == nil {
+		return next
+	}
+	return &instrumentedStorage{next: next, metrics: metrics}
+}
+
+// CreateReview records the latency and counts successful creations.
+func (s *instrumentedStorage) CreateReview(ctx context.Context, review *Review) (string, error) {
+	start := time.Now()
+	result, err := s.next.CreateReview(ctx, review)
+	s.metrics.observeStorage("CreateReview", start, err)
+	if err == nil {
+		s.metrics.reviewsCreated.Inc()
+	}
+	return result, err
+}
+
+// UpdateReview records the latency of the update.
+func (s *instrumentedStorage) UpdateReview(ctx context.Context, review *Review) error {
+	start := time.Now()
+	err := s.next.UpdateReview(ctx, review)
+	s.metrics.observeStorage("UpdateReview", start, err)
+	return err
+}
+
+// DeleteReview records the latency and counts successful deletions.
+func (s *instrumentedStorage) DeleteReview(ctx context.Context, id int) error {
+	start := time.Now()
+	err := s.next.DeleteReview(ctx, id)
+	s.metrics.observeStorage("DeleteReview", start, err)
+	if err == nil {
+		s.metrics.reviewsDeleted.Inc()
+	}
+	return err
+}
+
+// GetReviewById records the latency of the lookup.
+func (s *instrumentedStorage) GetReviewById(ctx context.Context, id int) (*Review, error) {
+	start := time.Now()
+	review, err := s.next.GetReviewById(ctx, id)
+	s.metrics.observeStorage("GetReviewById", start, err)
+	return review, err
+}
+
+// instrumentedTrash is a TrashStore decorator that records operation
+// latencies and the trash counters.
+type instrumentedTrash struct {
+	next    TrashStore
+	metrics *Metrics
+}
+
+// NewInstrumentedTrash wraps next so every call is measured.
+//
+// Parameters:
+//   - next: The trash store to instrument
+//   - metrics: Where to record measurements (nil returns next unchanged)
+//
+// Returns:
+//   - TrashStore: The instrumented trash store
+func NewInstrumentedTrash(next TrashStore, metrics *Metrics) TrashStore {
+	if metrics == nil {
+		return next
+	}
+	return &instrumentedTrash{next: next, metrics: metrics}
+}
+
+// ListDeletedReviews records the latency of the listing.
+func (s *instrumentedTrash) ListDeletedReviews(ctx context.Context, limit int) ([]*Review, error) {
+	start := time.Now()
+	reviews, err := s.next.ListDeletedReviews(ctx, limit)
+	s.metrics.observeStorage("ListDeletedReviews", start, err)
+	return reviews, err
+}
+
+// RestoreReview records the latency and counts successful restores.
+func (s *instrumentedTrash) RestoreReview(ctx context.Context, id int) (*Review, error) {
+	start := time.Now()
+	review, err := s.next.RestoreReview(ctx, id)
+	s.metrics.observeStorage("RestoreReview", start, err)
+	if err == nil {
+		s.metrics.reviewsRestored.Inc()
+	}
+	return review, err
+}
+
+// PurgeDeletedReviews records the latency and counts purged reviews.
+func (s *instrumentedTrash) PurgeDeletedReviews(ctx context.Context, cutoff time.Time) (int, error) {
+	start := time.Now()
+	purged, err := s.next.PurgeDeletedReviews(ctx, cutoff)
+	s.metrics.observeStorage("PurgeDeletedReviews", start, err)
+	s.metrics.reviewsPurged.Add(float64(purged))
+	return purged, err
+}
+
+// instrumentedRevisions is a RevisionStore decorator that records operation
+// latencies.
+type instrumentedRevisions struct {
+	next    RevisionStore
+	metrics *Metrics
+}
+
+// NewInstrumentedRevisions wraps next so every call is measured.
+//
+// Parameters:
+//   - next: The revision store to instrument
+//   - metrics: Where to record measurements (nil returns next unchanged)
+//
+// Returns:
+//   - RevisionStore: The instrumented revision store
+func NewInstrumentedRevisions(next RevisionStore, metrics *Metrics) RevisionStore {
+	if metrics == nil {
+		return next
+	}
+	return &instrumentedRevisions{next: next, metrics: metrics}
+}
+
+// ListRevisions records the latency of the listing.
+func (s *instrumentedRevisions) ListRevisions(ctx context.Context, id int) ([]*Revision, error) {
+	start := time.Now()
+	revisions, err := s.next.ListRevisions(ctx, id)
+	s.metrics.observeStorage("ListRevisions", start, err)
+	return revisions, err
+}
+
+// GetRevision records the latency of the lookup.
+func (s *instrumentedRevisions) GetRevision(ctx context.Context, id, revision int) (*Revision, error) {
+	start := time.Now()
+	result, err := s.next.GetRevision(ctx, id, revision)
+	s.metrics.observeStorage("GetRevision", start, err)
+	return result, err
+}
+
+// RevertReview records the latency of the revert.
+func (s *instrumentedRevisions) RevertReview(ctx context.Context, id, revision int) (*Review, error) {
+	start := time.Now()
+	review, err := s.next.RevertReview(ctx, id, revision)
+	s.metrics.observeStorage("RevertReview", start, err)
+	return review, err
+}
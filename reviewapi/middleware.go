@@ -0,0 +1,283 @@
+// Package reviewapi provides HTTP middleware for the Movie Review API.
+// This file implements request ID assignment and propagation, identification
+// of the actor making the request, and structured access logging with one
+// entry per request.
+//
+// The request ID is stored in the request context so that handlers, error
+// responses (ApiError) and storage log lines can all be correlated with the
+// access log entry for the same request.
+//
+// The actor is an authenticated identity: the common name of a verified
+// client certificate (mutual TLS), or "admin" for a request carrying the
+// admin token. The X-Actor header is not proof of anything, so it is only
+// kept as the claimed actor, recorded apart from the actor in the audit log.
+//
+// The /admin routes additionally require the admin token as a bearer token.
+package reviewapi
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// requestIDHeader is the header used to accept and return request IDs.
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds client supplied request IDs to keep logs sane.
+const maxRequestIDLength = 128
+
+// requestIDKey is the context key under which the request ID is stored.
+type requestIDKey struct{}
+
+// ContextWithRequestID returns a copy of ctx carrying the request ID.
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID stored in ctx, or "" if none.
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// newRequestID generates a random 128-bit request ID encoded as hex.
+func newRequestID() string {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		// crypto/rand does not fail on supported platforms; fall back to time
+		return hex.EncodeToString([]byte(time.Now().Format(time.RFC3339Nano)))
+	}
+	return hex.EncodeToString(buf[:])
+}
+
+// validRequestID reports whether a client supplied request ID is safe to
+// reuse: non-empty, bounded in length and limited to printable ASCII without
+// spaces, so it cannot inject content into logs or headers.
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] <= ' ' || requestID[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// requestIDMiddleware accepts a valid X-Request-ID header from the client or
+// generates a new ID, stores it in the request context and echoes it in the
+// response headers.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		requestID := request.Header.Get(requestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+
+		writer.Header().Set(requestIDHeader, requestID)
+		ctx := ContextWithRequestID(request.Context(), requestID)
+		next.ServeHTTP(writer, request.WithContext(ctx))
+	})
+}
+
+// actorHeader is the header naming the claimed, unverified actor.
+const actorHeader = "X-Actor"
+
+// AnonymousActor is the actor of unauthenticated requests, and of storage
+// calls made outside a request.
+const AnonymousActor = "anonymous"
+
+// AdminActor is the actor of requests authenticated by the admin token.
+const AdminActor = "admin"
+
+// SystemActor is the actor of background jobs such as the trash purge.
+const SystemActor = "system"
+
+// actorKey is the context key under which the actor is stored.
+type actorKey struct{}
+
+// ContextWithActor returns a copy of ctx carrying the actor.
+func ContextWithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor stored in ctx, or "anonymous" if none.
+func ActorFromContext(ctx context.Context) string {
+	if actor, _ := ctx.Value(actorKey{}).(string); actor != "" {
+		return actor
+	}
+	return AnonymousActor
+}
+
+// claimedActorKey is the context key under which the claimed actor is stored.
+type claimedActorKey struct{}
+
+// ContextWithClaimedActor returns a copy of ctx carrying the actor claimed
+// by the X-Actor header.
+func ContextWithClaimedActor(ctx context.Context, claimed string) context.Context {
+	return context.WithValue(ctx, claimedActorKey{}, claimed)
+}
+
+// ClaimedActorFromContext returns the actor claimed by the request of ctx,
+// or "" if none. Unlike ActorFromContext, it is not authenticated.
+func ClaimedActorFromContext(ctx context.Context) string {
+	claimed, _ := ctx.Value(claimedActorKey{}).(string)
+	return claimed
+}
+
+// clientIPKey is the context key under which the client IP is stored.
+type clientIPKey struct{}
+
+// ContextWithClientIP returns a copy of ctx carrying the client IP.
+func ContextWithClientIP(ctx context.Context, clientIP string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, clientIP)
+}
+
+// ClientIPFromContext returns the client IP stored in ctx, or "" if none.
+func ClientIPFromContext(ctx context.Context) string {
+	clientIP, _ := ctx.Value(clientIPKey{}).(string)
+	return clientIP
+}
+
+// actorMiddleware stores who makes the request in its context: the actor,
+// authenticated (the common name of the verified client certificate, else
+// "admin" for a request carrying adminToken, else "anonymous"), the actor
+// claimed by a valid X-Actor header (same rules as request IDs), and the IP
+// address of the connection.
+func actorMiddleware(adminToken Secret) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			actor := AnonymousActor
+			if tls := request.TLS; tls != nil && len(tls.VerifiedChains) > 0 && tls.VerifiedChains[0][0].Subject.CommonName != "" {
+				actor = tls.VerifiedChains[0][0].Subject.CommonName
+			} else if hasBearerToken(request, adminToken) {
+				actor = AdminActor
+			}
+
+			claimed := request.Header.Get(actorHeader)
+			if !validRequestID(claimed) {
+				claimed = ""
+			}
+
+			clientIP, _, err := net.SplitHostPort(request.RemoteAddr)
+			if err != nil {
+				clientIP = request.RemoteAddr
+			}
+
+			ctx := ContextWithActor(request.Context(), actor)
+			ctx = ContextWithClaimedActor(ctx, claimed)
+			ctx = ContextWithClientIP(ctx, clientIP)
+			next.ServeHTTP(writer, request.WithContext(ctx))
+		})
+	}
+}
+
+// hasBearerToken reports whether request carries token as a bearer token.
+// It is always false when token is empty.
+func hasBearerToken(request *http.Request, token Secret) bool {
+	if token == "" {
+		return false
+	}
+	// Compare in constant time so the token cannot be guessed byte by byte
+	expected := []byte("Bearer " + token.Value())
+	return subtle.ConstantTimeCompare([]byte(request.Header.Get("Authorization")), expected) == 1
+}
+
+// requireAdminToken rejects requests that do not carry token as a bearer
+// token with 401 Unauthorized. It protects the /admin routes.
+func requireAdminToken(token Secret) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			if !hasBearerToken(request, token) {
+				writer.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				WriteJSON(writer, http.StatusUnauthorized, ApiError{
+					Error:     "admin token required",
+					RequestID: RequestIDFromContext(request.Context()),
+				})
+				return
+			}
+			next.ServeHTTP(writer, request)
+		})
+	}
+}
+
+// statusRecorder wraps an http.ResponseWriter to capture the status code and
+// the number of body bytes written.
+type statusRecorder struct {
+	http.ResponseWriter
+
+	status int
+	bytes  int
+}
+
+// WriteHeader records the status code before delegating.
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the bytes written; an implicit 200 is recorded if
+// WriteHeader was not called.
+func (r *statusRecorder) Write(body []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(body)
+	r.bytes += n
+	return n, err
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// accessLogMiddleware writes one structured log entry per request with the
+// method, matched route pattern, status, response size and latency.
+//
+// It must run inside the chi router (router.Use) so that the route pattern
+// is available once the request has been routed.
+func accessLogMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			start := time.Now()
+			recorder := &statusRecorder{ResponseWriter: writer}
+
+			next.ServeHTTP(recorder, request)
+
+			// A handler that writes nothing results in an implicit 200
+			status := recorder.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			route := ""
+			if routeContext := chi.RouteContext(request.Context()); routeContext != nil {
+				route = routeContext.RoutePattern()
+			}
+
+			logger.LogAttrs(request.Context(), slog.LevelInfo, "http request",
+				slog.String("method", request.Method),
+				slog.String("route", route),
+				slog.String("path", request.URL.Path),
+				slog.Int("status", status),
+				slog.Int("bytes", recorder.bytes),
+				slog.Duration("duration", time.Since(start)),
+				slog.String("remote_addr", request.RemoteAddr),
+				slog.String("actor", ActorFromContext(request.Context())),
+				slog.String("claimed_actor", ClaimedActorFromContext(request.Context())),
+			)
+		})
+	}
+}
@@ -0,0 +1,175 @@
+// Package reviewapi provides schema migrations for the Movie Review API.
+// This file applies the SQL files embedded from the migrations directory in
+// version order and records each applied version in schema_migrations.
+//
+// Migration files are named <version>_<description>.sql (e.g.
+// 0001_create_reviews.sql). Each runs in its own transaction together with
+// the insert of its version, so a failed migration leaves no partial state.
+package reviewapi
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// postgresMigrations holds the PostgreSQL migration files.
+//
+//go:embed migrations/postgres/*.sql
+var postgresMigrations embed.FS
+
+// sqliteMigrations holds the SQLite migration files. Each PostgreSQL
+// migration has a SQLite counterpart with the same version.
+//
+//go:embed migrations/sqlite/*.sql
+var sqliteMigrations embed.FS
+
+// migrationLockID is the PostgreSQL advisory lock key held while migrating,
+// so that several instances starting at once do not race.
+const migrationLockID = 727274
+
+// migration is a single versioned schema change.
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// loadMigrations reads and orders the migrations in dir of fsys.
+//
+// Returns:
+//   - []migration: Migrations sorted by ascending version
+//   - error: Non-nil if a file is misnamed, unreadable or versions repeat
+func loadMigrations(fsys fs.FS, dir string) ([]migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+
+	var migrations []migration
+	seen := map[int]string{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		prefix, _, found := strings.Cut(entry.Name(), "_")
+		version, err := strconv.Atoi(prefix)
+		if !found || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: name must start with a positive version and an underscore", entry.Name())
+		}
+		if other, duplicate := seen[version]; duplicate {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, entry.Name(), version)
+		}
+		seen[version] = entry.Name()
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", entry.Name(), err)
+		}
+		migrations = append(migrations, migration{version: version, name: entry.Name(), sql: string(content)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	return migrations, nil
+}
+
+// latestMigrationVersion returns the highest migration version in dir of
+// fsys, i.e. the schema version this build expects.
+func latestMigrationVersion(fsys fs.FS, dir string) (int, error) {
+	migrations, err := loadMigrations(fsys, dir)
+	if err != nil {
+		return 0, err
+	}
+	if len(migrations) == 0 {
+		return 0, nil
+	}
+	return migrations[len(migrations)-1].version, nil
+}
+
+// Migrate applies every embedded migration newer than the database's current
+// schema version. It holds an advisory lock for the duration so concurrent
+// instances apply each migration exactly once.
+//
+// Parameters:
+//   - ctx: Context for cancellation of the migration run
+//
+// Returns:
+//   - error: Non-nil if any migration fails; earlier migrations stay applied
+func (pg *PgDb) Migrate(ctx context.Context) error {
+	migrations, err := loadMigrations(postgresMigrations, "migrations/postgres")
+	if err != nil {
+		return err
+	}
+
+	// Advisory locks are per session, so pin a single connection
+	conn, err := pg.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire migration connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID)
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS public.schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name VARCHAR NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	var current int
+	if err := conn.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM public.schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("begin migration %s: %w", m.name, err)
+		}
+		if _, err := tx.ExecContext(ctx, m.sql); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("apply migration %s: %w", m.name, err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO public.schema_migrations (version, name) VALUES ($1, $2)`, m.version, m.name); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("record migration %s: %w", m.name, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit migration %s: %w", m.name, err)
+		}
+		pg.logger.InfoContext(ctx, "applied migration", "version", m.version, "name", m.name)
+	}
+	return nil
+}
+
+// SchemaVersion returns the highest applied migration version, or 0 when no
+// migration has been applied yet.
+func (pg *PgDb) SchemaVersion(ctx context.Context) (int, error) {
+	var exists bool
+	if err := pg.db.QueryRowContext(ctx, `SELECT to_regclass('public.schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return 0, fmt.Errorf("check schema_migrations: %w", err)
+	}
+	if !exists {
+		return 0, nil
+	}
+
+	var version int
+	if err := pg.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM public.schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("read schema version: %w", err)
+	}
+	return version, nil
+}
@@ -0,0 +1,11 @@
+-- Creates the reviews table. IF NOT EXISTS keeps this compatible with
+-- databases where the table was created manually from the README.
+CREATE TABLE IF NOT EXISTS public.reviews (
+    id SERIAL PRIMARY KEY,
+    title VARCHAR NOT NULL,
+    director VARCHAR NOT NULL,
+    rating VARCHAR NOT NULL,
+    releaseDate VARCHAR NOT NULL,
+    reviewNotes VARCHAR NOT NULL,
+    dateCreated VARCHAR NOT NULL
+);
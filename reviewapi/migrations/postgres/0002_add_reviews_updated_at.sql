@@ -0,0 +1,4 @@
+-- Records when each review last changed, for Last-Modified and conditional
+-- GET. Existing rows are stamped with the time of the migration.
+ALTER TABLE public.reviews
+    ADD COLUMN IF NOT EXISTS updatedAt TIMESTAMPTZ NOT NULL DEFAULT now();
@@ -0,0 +1,7 @@
+-- Soft delete: deleted reviews keep their row with the deletion time until
+-- they are restored or purged. Reads skip rows with deletedAt set.
+ALTER TABLE public.reviews
+    ADD COLUMN IF NOT EXISTS deletedAt TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS reviews_deleted_at_idx
+    ON public.reviews (deletedAt) WHERE deletedAt IS NOT NULL;
@@ -0,0 +1,25 @@
+-- Revision history: every version of a review, written in the same
+-- transaction as the create or update that produced it. Existing reviews get
+-- their current content as revision 1, with an unknown author.
+CREATE TABLE IF NOT EXISTS public.review_revisions (
+    reviewId INTEGER NOT NULL REFERENCES public.reviews (id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    author VARCHAR NOT NULL,
+    createdAt TIMESTAMPTZ NOT NULL,
+    changedFields VARCHAR NOT NULL,
+    title VARCHAR NOT NULL,
+    director VARCHAR NOT NULL,
+    releaseDate VARCHAR NOT NULL,
+    rating VARCHAR NOT NULL,
+    reviewNotes VARCHAR NOT NULL,
+    PRIMARY KEY (reviewId, revision)
+);
+
+INSERT INTO public.review_revisions (
+    reviewId, revision, author, createdAt, changedFields,
+    title, director, releaseDate, rating, reviewNotes
+)
+SELECT id, 1, '', updatedAt, 'title,director,releaseDate,rating,reviewNotes',
+    title, director, releaseDate, rating, reviewNotes
+FROM public.reviews
+ON CONFLICT DO NOTHING;
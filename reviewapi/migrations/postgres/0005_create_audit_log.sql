@@ -0,0 +1,30 @@
+-- Audit log: one row per review changed by a mutating storage call, written
+-- in the same transaction as the change. Rows outlive the reviews they
+-- describe, so there is no foreign key, and triggers reject any UPDATE or
+-- DELETE to keep the log append-only.
+CREATE TABLE IF NOT EXISTS public.audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    occurredAt TIMESTAMPTZ NOT NULL,
+    actor VARCHAR NOT NULL,
+    action VARCHAR NOT NULL,
+    reviewId INTEGER NOT NULL,
+    before JSONB,
+    after JSONB,
+    requestId VARCHAR NOT NULL,
+    clientIp VARCHAR NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_occurred_at_idx ON public.audit_log (occurredAt);
+CREATE INDEX IF NOT EXISTS audit_log_actor_idx ON public.audit_log (actor, id);
+
+CREATE OR REPLACE FUNCTION public.audit_log_append_only() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$;
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON public.audit_log;
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE ON public.audit_log
+    FOR EACH ROW EXECUTE FUNCTION public.audit_log_append_only();
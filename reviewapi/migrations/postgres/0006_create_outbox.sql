@@ -0,0 +1,31 @@
+-- Transactional outbox: one event per review change, written in the same
+-- transaction as the change, and one delivery per event and configured
+-- webhook. The dispatcher fans new events out to deliveries, then sends the
+-- deliveries that are due until they succeed or run out of attempts.
+CREATE TABLE IF NOT EXISTS public.outbox_events (
+    id BIGSERIAL PRIMARY KEY,
+    eventType VARCHAR NOT NULL,
+    reviewId INTEGER NOT NULL,
+    payload JSONB NOT NULL,
+    createdAt TIMESTAMPTZ NOT NULL DEFAULT now(),
+    fannedOut BOOLEAN NOT NULL DEFAULT false
+);
+
+CREATE INDEX IF NOT EXISTS outbox_events_new_idx ON public.outbox_events (id) WHERE NOT fannedOut;
+
+CREATE TABLE IF NOT EXISTS public.webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    eventId BIGINT NOT NULL REFERENCES public.outbox_events (id) ON DELETE CASCADE,
+    webhook VARCHAR NOT NULL,
+    status VARCHAR NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    nextAttemptAt TIMESTAMPTZ NOT NULL DEFAULT now(),
+    lastError VARCHAR NOT NULL DEFAULT '',
+    updatedAt TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (eventId, webhook)
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx
+    ON public.webhook_deliveries (nextAttemptAt) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_dead_idx
+    ON public.webhook_deliveries (id) WHERE status = 'dead';
@@ -0,0 +1,16 @@
+-- Review event notifications: every event written to the outbox is announced
+-- on the review_events channel with its id as payload, so that each instance
+-- listening with LISTEN streams the changes made by all of them. NOTIFY is
+-- delivered at commit, in commit order, and never for rolled back changes.
+CREATE OR REPLACE FUNCTION public.notify_review_event() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    PERFORM pg_notify('review_events', NEW.id::text);
+    RETURN NULL;
+END;
+$$;
+
+DROP TRIGGER IF EXISTS outbox_events_notify ON public.outbox_events;
+CREATE TRIGGER outbox_events_notify
+    AFTER INSERT ON public.outbox_events
+    FOR EACH ROW EXECUTE FUNCTION public.notify_review_event();
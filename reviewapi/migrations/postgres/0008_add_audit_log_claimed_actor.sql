@@ -0,0 +1,4 @@
+-- Claimed actor: the unverified X-Actor header of the request that made the
+-- change, kept apart from the authenticated actor. Entries written before
+-- this migration have none.
+ALTER TABLE public.audit_log ADD COLUMN IF NOT EXISTS claimedActor VARCHAR NOT NULL DEFAULT '';
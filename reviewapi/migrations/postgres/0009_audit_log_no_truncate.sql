@@ -0,0 +1,6 @@
+-- Row triggers do not fire for TRUNCATE, which would empty the append-only
+-- audit log in one statement: reject it with a statement trigger too.
+DROP TRIGGER IF EXISTS audit_log_no_truncate ON public.audit_log;
+CREATE TRIGGER audit_log_no_truncate
+    BEFORE TRUNCATE ON public.audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION public.audit_log_append_only();
@@ -0,0 +1,10 @@
+-- Creates the reviews table with the same columns as the PostgreSQL schema.
+CREATE TABLE IF NOT EXISTS reviews (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    title TEXT NOT NULL,
+    director TEXT NOT NULL,
+    rating TEXT NOT NULL,
+    releaseDate TEXT NOT NULL,
+    reviewNotes TEXT NOT NULL,
+    dateCreated TEXT NOT NULL
+);
@@ -0,0 +1,6 @@
+-- Records when each review last changed, for Last-Modified and conditional
+-- GET. SQLite only allows constant defaults in ADD COLUMN, so existing rows
+-- are stamped with the time of the migration afterwards.
+ALTER TABLE reviews
+    ADD COLUMN updatedAt TIMESTAMP NOT NULL DEFAULT '1970-01-01T00:00:00Z';
+UPDATE reviews SET updatedAt = strftime('%Y-%m-%dT%H:%M:%fZ', 'now');
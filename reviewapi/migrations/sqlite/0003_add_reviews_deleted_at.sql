@@ -0,0 +1,6 @@
+-- Soft delete: deleted reviews keep their row with the deletion time until
+-- they are restored or purged. Reads skip rows with deletedAt set.
+ALTER TABLE reviews ADD COLUMN deletedAt TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS reviews_deleted_at_idx
+    ON reviews (deletedAt) WHERE deletedAt IS NOT NULL;
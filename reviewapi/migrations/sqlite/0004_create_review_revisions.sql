@@ -0,0 +1,24 @@
+-- Revision history: every version of a review, written in the same
+-- transaction as the create or update that produced it. Existing reviews get
+-- their current content as revision 1, with an unknown author.
+CREATE TABLE IF NOT EXISTS review_revisions (
+    reviewId INTEGER NOT NULL REFERENCES reviews (id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    author TEXT NOT NULL,
+    createdAt TIMESTAMP NOT NULL,
+    changedFields TEXT NOT NULL,
+    title TEXT NOT NULL,
+    director TEXT NOT NULL,
+    releaseDate TEXT NOT NULL,
+    rating TEXT NOT NULL,
+    reviewNotes TEXT NOT NULL,
+    PRIMARY KEY (reviewId, revision)
+);
+
+INSERT OR IGNORE INTO review_revisions (
+    reviewId, revision, author, createdAt, changedFields,
+    title, director, releaseDate, rating, reviewNotes
+)
+SELECT id, 1, '', updatedAt, 'title,director,releaseDate,rating,reviewNotes',
+    title, director, releaseDate, rating, reviewNotes
+FROM reviews;
@@ -0,0 +1,28 @@
+-- Audit log: one row per review changed by a mutating storage call, written
+-- in the same transaction as the change. Rows outlive the reviews they
+-- describe, so there is no foreign key, and triggers reject any UPDATE or
+-- DELETE to keep the log append-only.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    occurredAt TIMESTAMP NOT NULL,
+    actor TEXT NOT NULL,
+    action TEXT NOT NULL,
+    reviewId INTEGER NOT NULL,
+    before TEXT,
+    after TEXT,
+    requestId TEXT NOT NULL,
+    clientIp TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_occurred_at_idx ON audit_log (occurredAt);
+CREATE INDEX IF NOT EXISTS audit_log_actor_idx ON audit_log (actor, id);
+
+CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
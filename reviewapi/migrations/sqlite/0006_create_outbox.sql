@@ -0,0 +1,31 @@
+-- Transactional outbox: one event per review change, written in the same
+-- transaction as the change, and one delivery per event and configured
+-- webhook. The dispatcher fans new events out to deliveries, then sends the
+-- deliveries that are due until they succeed or run out of attempts.
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    eventType TEXT NOT NULL,
+    reviewId INTEGER NOT NULL,
+    payload TEXT NOT NULL,
+    createdAt TIMESTAMP NOT NULL,
+    fannedOut INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS outbox_events_new_idx ON outbox_events (id) WHERE fannedOut = 0;
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    eventId INTEGER NOT NULL REFERENCES outbox_events (id) ON DELETE CASCADE,
+    webhook TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    nextAttemptAt TIMESTAMP NOT NULL,
+    lastError TEXT NOT NULL DEFAULT '',
+    updatedAt TIMESTAMP NOT NULL,
+    UNIQUE (eventId, webhook)
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx
+    ON webhook_deliveries (nextAttemptAt) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_dead_idx
+    ON webhook_deliveries (id) WHERE status = 'dead';
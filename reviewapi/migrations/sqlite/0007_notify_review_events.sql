@@ -0,0 +1,5 @@
+-- Review event notifications: SQLite has no LISTEN/NOTIFY, and a database
+-- file is written by a single process, so SqliteDb broadcasts the events it
+-- commits in process instead (see events.go). This migration only keeps the
+-- versions of both backends aligned.
+SELECT 1;
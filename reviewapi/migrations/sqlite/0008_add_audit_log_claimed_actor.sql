@@ -0,0 +1,4 @@
+-- Claimed actor: the unverified X-Actor header of the request that made the
+-- change, kept apart from the authenticated actor. Entries written before
+-- this migration have none.
+ALTER TABLE audit_log ADD COLUMN claimedActor TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,5 @@
+-- SQLite has no TRUNCATE: an unqualified DELETE is its equivalent, and the
+-- audit_log_no_delete trigger already rejects it (a table with triggers is
+-- never emptied by the truncate optimization). This migration only keeps
+-- the versions of both backends aligned.
+SELECT 1;
@@ -0,0 +1,524 @@
+// Package reviewapi provides the transactional outbox for the Movie Review API.
+// This file writes an event for every review change in the same transaction
+// as the change, and implements the queries of the webhook dispatcher
+// (webhooks.go) on each backend.
+//
+// Behaviour:
+//   - CreateReview, UpdateReview, DeleteReview and RestoreReview write a
+//     review.created, review.updated, review.deleted or review.restored
+//     event to outbox_events; a change is never committed without its event
+//   - The dispatcher fans each new event out to one pending delivery per
+//     configured webhook (webhook_deliveries), then claims the deliveries
+//     that are due, leasing them so that other instances skip them
+//   - A delivery ends up delivered, or dead once its attempts run out; dead
+//     deliveries can be listed and retried through the admin routes
+//   - Events whose deliveries have all been delivered are pruned after the
+//     retention period
+package reviewapi
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Event types, one per kind of review change.
+const (
+	EventReviewCreated  = "review.created"
+	EventReviewUpdated  = "review.updated"
+	EventReviewDeleted  = "review.deleted"
+	EventReviewRestored = "review.restored"
+)
+
+// Delivery statuses.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// deliveryColumns are the columns read by scanDelivery, in order, from
+// webhook_deliveries d joined with outbox_events e.
+const deliveryColumns = `d.id, d.webhook, d.status, d.attempts, d.nextAttemptAt, d.lastError, d.updatedAt,
+	e.id, e.eventType, e.createdAt, e.reviewId, e.payload`
+
+// WebhookOutbox is implemented by the backends for the webhook dispatcher.
+type WebhookOutbox interface {
+	// ClaimWebhookDeliveries fans new events out to one delivery per webhook,
+	// then returns up to limit due deliveries and postpones them by lease,
+	// so that they are not claimed again while being sent.
+	ClaimWebhookDeliveries(ctx context.Context, webhooks []string, limit int, lease time.Duration) ([]*WebhookDelivery, error)
+
+	// CompleteWebhookDelivery records the outcome of an attempt.
+	CompleteWebhookDelivery(ctx context.Context, id int64, result WebhookResult) error
+
+	// PruneOutbox removes the events created before cutoff whose deliveries
+	// have all been delivered, and returns how many were removed.
+	PruneOutbox(ctx context.Context, cutoff time.Time) (int, error)
+}
+
+// eventPayload encodes review for the payload column of an event. It
+// returns a string so that the PostgreSQL driver sends it as text for the
+// JSONB column.
+func eventPayload(review *Review) (string, error) {
+	data, err := json.Marshal(review)
+	if err != nil {
+		return "", fmt.Errorf("encode event review: %w", err)
+	}
+	return string(data), nil
+}
+
+// scanDelivery reads the deliveryColumns of row into delivery.
+func scanDelivery(row rowScanner, delivery *WebhookDelivery) error {
+	var payload []byte
+	err := row.Scan(
+		&delivery.ID,
+		&delivery.Webhook,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&delivery.LastError,
+		&delivery.UpdatedAt,
+		&delivery.Event.ID,
+		&delivery.Event.Type,
+		&delivery.Event.OccurredAt,
+		&delivery.Event.ReviewID,
+		&payload)
+	delivery.Event.Review = json.RawMessage(payload)
+	return err
+}
+
+// scanDeliveries reads every delivery of rows and closes them. It returns
+// an empty slice rather than nil when there is none.
+func scanDeliveries(rows *sql.Rows) ([]*WebhookDelivery, error) {
+	defer rows.Close()
+	deliveries := []*WebhookDelivery{}
+	for rows.Next() {
+		delivery := &WebhookDelivery{}
+		if err := scanDelivery(rows, delivery); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// retryDeliveryResult turns the outcome of a dead delivery retry into
+// RetryWebhookDelivery's error, mapping no rows to ErrNotFound.
+func retryDeliveryResult(ctx context.Context, id int64, err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("dead webhook delivery with id %d %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to retry webhook delivery: %w", contextError(ctx, err))
+	}
+	return nil
+}
+
+// eventOrderLockID is the PostgreSQL advisory lock key under which events
+// take their ID. The lock is held until the transaction ends, so events
+// commit in ID order: readers following the outbox by ID (the event feed and
+// resuming event streams) never see an event commit behind one they already
+// passed. Writes only queue for it between their last statement and commit.
+const eventOrderLockID = 727275
+
+// insertEvent writes an event of the given type for review within tx. It
+// must be the last statement of tx, since it holds eventOrderLockID from
+// then on.
+func (pg *PgDb) insertEvent(ctx context.Context, tx *sql.Tx, eventType string, review *Review) error {
+	payload, err := eventPayload(review)
+	if err != nil {
+		return err
+	}
+	return pg.withStatement(ctx, pg.stmtInsertEvent, false, func(stmt *sql.Stmt) error {
+		_, err := tx.StmtContext(ctx, stmt).ExecContext(ctx, eventType, review.ID, payload, eventOrderLockID)
+		return err
+	})
+}
+
+// ClaimWebhookDeliveries fans new events out and claims the due deliveries
+// (see WebhookOutbox). Rows locked by another instance are skipped, so
+// several instances can dispatch at once.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout control
+//   - webhooks: The names of the configured webhooks
+//   - limit: The maximum number of events fanned out and of deliveries claimed
+//   - lease: How long the claimed deliveries are hidden from other claims
+//
+// Returns:
+//   - []*WebhookDelivery: The claimed deliveries, oldest due first
+//   - error: Non-nil if the queries fail
+func (pg *PgDb) ClaimWebhookDeliveries(ctx context.Context, webhooks []string, limit int, lease time.Duration) (_ []*WebhookDelivery, err error) {
+	ctx, finish := pg.beginOperation(ctx, "claimDeliveries", "UPDATE", 0)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !pg.ready.Load() {
+		return nil, ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, pg.timeouts.Update)
+	defer cancel()
+
+	var deliveries []*WebhookDelivery
+	err = pg.inTx(ctx, func(tx *sql.Tx) error {
+		// Fan new events out to one delivery per webhook
+		err := pg.withStatement(ctx, pg.stmtFanOutEvents, false, func(stmt *sql.Stmt) error {
+			_, err := tx.StmtContext(ctx, stmt).ExecContext(ctx, pq.StringArray(webhooks), limit)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		// Lock the due deliveries, then postpone them by the lease
+		err = pg.withStatement(ctx, pg.stmtClaimDeliveries, false, func(stmt *sql.Stmt) error {
+			rows, err := tx.StmtContext(ctx, stmt).QueryContext(ctx, limit)
+			if err != nil {
+				return err
+			}
+			deliveries, err = scanDeliveries(rows)
+			return err
+		})
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+		ids := make(pq.Int64Array, len(deliveries))
+		for i, delivery := range deliveries {
+			ids[i] = delivery.ID
+		}
+		return pg.withStatement(ctx, pg.stmtLeaseDeliveries, false, func(stmt *sql.Stmt) error {
+			_, err := tx.StmtContext(ctx, stmt).ExecContext(ctx, ids, lease.Seconds())
+			return err
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", contextError(ctx, err))
+	}
+	return deliveries, nil
+}
+
+// CompleteWebhookDelivery records the outcome of a delivery attempt.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout control
+//   - id: The unique identifier of the delivery
+//   - result: The new status, retry delay and error of the delivery
+//
+// Returns:
+//   - error: Non-nil if the update fails
+func (pg *PgDb) CompleteWebhookDelivery(ctx context.Context, id int64, result WebhookResult) (err error) {
+	ctx, finish := pg.beginOperation(ctx, "completeDelivery", "UPDATE", 0)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !pg.ready.Load() {
+		return ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, pg.timeouts.Update)
+	defer cancel()
+
+	err = pg.withStatement(ctx, pg.stmtCompleteDelivery, false, func(stmt *sql.Stmt) error {
+		_, err := stmt.ExecContext(ctx, id, result.Status, result.RetryIn.Seconds(), result.Error)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to complete webhook delivery: %w", contextError(ctx, err))
+	}
+	return nil
+}
+
+// PruneOutbox removes the fully delivered events created before cutoff,
+// together with their deliveries.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout control
+//   - cutoff: Events created at or after this time are kept
+//
+// Returns:
+//   - int: The number of events removed
+//   - error: Non-nil if the deletion fails
+func (pg *PgDb) PruneOutbox(ctx context.Context, cutoff time.Time) (_ int, err error) {
+	ctx, finish := pg.beginOperation(ctx, "pruneOutbox", "DELETE", 0)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !pg.ready.Load() {
+		return 0, ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, pg.timeouts.Delete)
+	defer cancel()
+
+	var result sql.Result
+	err = pg.withStatement(ctx, pg.stmtPruneOutbox, false, func(stmt *sql.Stmt) (err error) {
+		result, err = stmt.ExecContext(ctx, cutoff)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune outbox: %w", contextError(ctx, err))
+	}
+	pruned, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return int(pruned), nil
+}
+
+// ListDeadWebhookDeliveries returns up to limit dead deliveries, most
+// recent first.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout control
+//   - limit: The maximum number of deliveries returned
+//
+// Returns:
+//   - []*WebhookDelivery: The dead deliveries, empty if there are none
+//   - error: Non-nil if the query fails
+func (pg *PgDb) ListDeadWebhookDeliveries(ctx context.Context, limit int) (_ []*WebhookDelivery, err error) {
+	ctx, finish := pg.beginOperation(ctx, "listDeadDeliveries", "SELECT", 0)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !pg.ready.Load() {
+		return nil, ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, pg.timeouts.Get)
+	defer cancel()
+
+	// Reads are idempotent, so a stale statement is retried once
+	var deliveries []*WebhookDelivery
+	err = pg.withStatement(ctx, pg.stmtListDeadDeliveries, true, func(stmt *sql.Stmt) error {
+		rows, err := stmt.QueryContext(ctx, limit)
+		if err != nil {
+			return err
+		}
+		deliveries, err = scanDeliveries(rows)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead webhook deliveries: %w", contextError(ctx, err))
+	}
+	return deliveries, nil
+}
+
+// RetryWebhookDelivery makes a dead delivery pending again, due now and
+// with its attempts reset.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout control
+//   - id: The unique identifier of the delivery
+//
+// Returns:
+//   - *WebhookDelivery: The delivery, pending again
+//   - error: Non-nil if the update fails; wraps ErrNotFound if there is no
+//     dead delivery with the given ID
+func (pg *PgDb) RetryWebhookDelivery(ctx context.Context, id int64) (_ *WebhookDelivery, err error) {
+	ctx, finish := pg.beginOperation(ctx, "retryDelivery", "UPDATE", 0)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !pg.ready.Load() {
+		return nil, ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, pg.timeouts.Update)
+	defer cancel()
+
+	// No returned row means there is no such dead delivery
+	delivery := &WebhookDelivery{}
+	err = pg.withStatement(ctx, pg.stmtRetryDelivery, false, func(stmt *sql.Stmt) error {
+		return scanDelivery(stmt.QueryRowContext(ctx, id), delivery)
+	})
+	if err = retryDeliveryResult(ctx, id, err); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// insertEvent writes an event of the given type for review within tx (see
+// PgDb.insertEvent).
+func (s *SqliteDb) insertEvent(ctx context.Context, tx *sql.Tx, eventType string, review *Review) error {
+	payload, err := eventPayload(review)
+	if err != nil {
+		return err
+	}
+	_, err = tx.StmtContext(ctx, s.stmtInsertEvent).ExecContext(ctx, eventType, review.ID, payload, time.Now().UTC())
+	return err
+}
+
+// ClaimWebhookDeliveries fans new events out and claims the due deliveries
+// (see PgDb.ClaimWebhookDeliveries). The transaction holds the write lock,
+// so concurrent claims wait instead of skipping rows.
+func (s *SqliteDb) ClaimWebhookDeliveries(ctx context.Context, webhooks []string, limit int, lease time.Duration) (_ []*WebhookDelivery, err error) {
+	ctx, finish := s.beginOperation(ctx, "claimDeliveries", "UPDATE", 0)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !s.ready.Load() {
+		return nil, ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Update)
+	defer cancel()
+
+	// The webhook names are passed as a JSON array for json_each
+	names, err := json.Marshal(webhooks)
+	if err != nil {
+		return nil, fmt.Errorf("encode webhook names: %w", err)
+	}
+
+	var deliveries []*WebhookDelivery
+	now := time.Now().UTC()
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		// Fan new events out to one delivery per webhook
+		if _, err := tx.StmtContext(ctx, s.stmtFanOutEvents).ExecContext(ctx, string(names), limit, now); err != nil {
+			return err
+		}
+		if _, err := tx.StmtContext(ctx, s.stmtMarkFannedOut).ExecContext(ctx, limit); err != nil {
+			return err
+		}
+
+		// Read the due deliveries, then postpone them by the lease
+		rows, err := tx.StmtContext(ctx, s.stmtClaimDeliveries).QueryContext(ctx, now, limit)
+		if err != nil {
+			return err
+		}
+		if deliveries, err = scanDeliveries(rows); err != nil {
+			return err
+		}
+		leaseStmt := tx.StmtContext(ctx, s.stmtLeaseDelivery)
+		for _, delivery := range deliveries {
+			if _, err := leaseStmt.ExecContext(ctx, now.Add(lease), now, delivery.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", contextError(ctx, err))
+	}
+	return deliveries, nil
+}
+
+// CompleteWebhookDelivery records the outcome of a delivery attempt (see
+// PgDb.CompleteWebhookDelivery).
+func (s *SqliteDb) CompleteWebhookDelivery(ctx context.Context, id int64, result WebhookResult) (err error) {
+	ctx, finish := s.beginOperation(ctx, "completeDelivery", "UPDATE", 0)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !s.ready.Load() {
+		return ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Update)
+	defer cancel()
+
+	now := time.Now().UTC()
+	if _, err := s.stmtCompleteDelivery.ExecContext(ctx, result.Status, now.Add(result.RetryIn), result.Error, now, id); err != nil {
+		return fmt.Errorf("failed to complete webhook delivery: %w", contextError(ctx, err))
+	}
+	return nil
+}
+
+// PruneOutbox removes the fully delivered events created before cutoff,
+// together with their deliveries (see PgDb.PruneOutbox).
+func (s *SqliteDb) PruneOutbox(ctx context.Context, cutoff time.Time) (_ int, err error) {
+	ctx, finish := s.beginOperation(ctx, "pruneOutbox", "DELETE", 0)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !s.ready.Load() {
+		return 0, ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Delete)
+	defer cancel()
+
+	result, err := s.stmtPruneOutbox.ExecContext(ctx, cutoff.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune outbox: %w", contextError(ctx, err))
+	}
+	pruned, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return int(pruned), nil
+}
+
+// ListDeadWebhookDeliveries returns up to limit dead deliveries, most
+// recent first (see PgDb.ListDeadWebhookDeliveries).
+func (s *SqliteDb) ListDeadWebhookDeliveries(ctx context.Context, limit int) (_ []*WebhookDelivery, err error) {
+	ctx, finish := s.beginOperation(ctx, "listDeadDeliveries", "SELECT", 0)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !s.ready.Load() {
+		return nil, ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Get)
+	defer cancel()
+
+	rows, err := s.stmtListDeadDeliveries.QueryContext(ctx, limit)
+	var deliveries []*WebhookDelivery
+	if err == nil {
+		deliveries, err = scanDeliveries(rows)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead webhook deliveries: %w", contextError(ctx, err))
+	}
+	return deliveries, nil
+}
+
+// RetryWebhookDelivery makes a dead delivery pending again (see
+// PgDb.RetryWebhookDelivery).
+func (s *SqliteDb) RetryWebhookDelivery(ctx context.Context, id int64) (_ *WebhookDelivery, err error) {
+	ctx, finish := s.beginOperation(ctx, "retryDelivery", "UPDATE", 0)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !s.ready.Load() {
+		return nil, ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Update)
+	defer cancel()
+
+	delivery := &WebhookDelivery{}
+	now := time.Now().UTC()
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		// No returned row means there is no such dead delivery
+		var retried int64
+		if err := tx.StmtContext(ctx, s.stmtRetryDelivery).QueryRowContext(ctx, now, id).Scan(&retried); err != nil {
+			return err
+		}
+		return scanDelivery(tx.StmtContext(ctx, s.stmtGetDelivery).QueryRowContext(ctx, id), delivery)
+	})
+	if err = retryDeliveryResult(ctx, id, err); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
@@ -0,0 +1,166 @@
+// Package reviewapi provides self-healing prepared statements for the Movie Review API.
+// This file wraps *sql.Stmt so that a statement invalidated on the server is
+// prepared again instead of failing every later call.
+//
+// PostgreSQL invalidates prepared statements in two common situations:
+//   - After a failover or connection reset, the new backend does not know the
+//     statement ("prepared statement ... does not exist", SQLSTATE 26000)
+//   - After a schema change alters a result type ("cached plan must not
+//     change result type", SQLSTATE 0A000)
+//
+// In both cases the statement was never executed, so repeating an idempotent
+// call after re-preparing it is safe. A call that picked up a statement just
+// before it was replaced can also find it closed; it was not executed
+// either, so it is repeated with the new statement whatever the call.
+package reviewapi
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/lib/pq"
+)
+
+// preparedStmt is a named prepared statement that can be replaced at runtime.
+type preparedStmt struct {
+	// name identifies the statement in logs and metrics (e.g. "getById").
+	name string
+
+	// query is the SQL text, kept so the statement can be prepared again.
+	query string
+
+	// db is the pool the statement is prepared on.
+	db *sql.DB
+
+	mu   sync.RWMutex
+	stmt *sql.Stmt
+}
+
+// prepareStmt prepares query on db as the statement called name.
+func prepareStmt(ctx context.Context, db *sql.DB, name, query string) (*preparedStmt, error) {
+	stmt, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("prepare %s: %w", name, err)
+	}
+	return &preparedStmt{name: name, query: query, db: db, stmt: stmt}, nil
+}
+
+// get returns the current statement.
+func (p *preparedStmt) get() *sql.Stmt {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.stmt
+}
+
+// reprepare replaces stale with a freshly prepared statement. If another
+// caller already replaced it, nothing is done, so concurrent failures of the
+// same statement cause a single re-preparation.
+//
+// The stale statement is closed in the background: sql.Stmt.Close waits for
+// executions still using it, which must not hold up the caller.
+//
+// Returns:
+//   - bool: Whether this call prepared a new statement
+//   - error: Non-nil if preparing failed (the stale statement is kept)
+func (p *preparedStmt) reprepare(ctx context.Context, stale *sql.Stmt) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stmt != stale {
+		return false, nil
+	}
+
+	stmt, err := p.db.PrepareContext(ctx, p.query)
+	if err != nil {
+		return false, fmt.Errorf("reprepare %s: %w", p.name, err)
+	}
+	p.stmt = stmt
+	go stale.Close()
+	return true, nil
+}
+
+// close closes the current statement.
+func (p *preparedStmt) close() error {
+	return p.get().Close()
+}
+
+// isStaleStatement reports whether err means the prepared statement is no
+// longer valid and must be prepared again.
+func isStaleStatement(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "26000": // invalid_sql_statement_name
+			return true
+		case "0A000": // feature_not_supported, raised for replanned result types
+			return strings.Contains(pqErr.Message, "cached plan must not change result type")
+		}
+	}
+	return false
+}
+
+// errStatementClosedText is the message of the error database/sql returns
+// when a closed *sql.Stmt is executed outside a transaction (Stmt.connStmt,
+// unchanged from Go 1.0 through Go 1.27). It is an unexported errors.New
+// value, so the text is the only way to recognize it; TestClosedStatement
+// fails if a Go release changes it.
+const errStatementClosedText = "sql: statement is closed"
+
+// isClosedStatement reports whether err means the statement was closed
+// before the call could run, so the call never reached the database.
+// Transactions re-prepare closed statements instead (Tx.StmtContext).
+func isClosedStatement(err error) bool {
+	return err != nil && err.Error() == errStatementClosedText
+}
+
+// withStatement runs call with the current statement of p. If the statement
+// turns out to be stale it is prepared again and, when retry is set, call
+// runs once more with the new statement.
+//
+// Only idempotent calls may set retry. Non-idempotent calls still get the
+// statement re-prepared, so the next call succeeds, but return the error.
+// A statement closed by a concurrent re-preparation is the exception: the
+// call never ran, so it runs again with the new statement either way.
+//
+// Parameters:
+//   - ctx: Context for preparing the replacement statement
+//   - p: The statement to use
+//   - retry: Whether call may be repeated after re-preparing
+//   - call: The database call, receiving the statement to execute
+//
+// Returns:
+//   - error: The error of the last call, or of re-preparing
+func (pg *PgDb) withStatement(ctx context.Context, p *preparedStmt, retry bool, call func(*sql.Stmt) error) error {
+	stmt := p.get()
+	err := call(stmt)
+	if isClosedStatement(err) {
+		if current := p.get(); current != stmt {
+			err = call(current)
+			pg.metrics.statementRetried(p.name, err)
+		}
+		return err
+	}
+	if !isStaleStatement(err) {
+		return err
+	}
+
+	prepared, prepErr := p.reprepare(ctx, stmt)
+	if prepErr != nil {
+		pg.logger.ErrorContext(ctx, "statement re-preparation failed", "statement", p.name, "error", prepErr)
+		return errors.Join(err, prepErr)
+	}
+	if prepared {
+		pg.metrics.statementReprepared(p.name)
+		pg.logger.WarnContext(ctx, "re-prepared stale statement", "statement", p.name, "cause", err)
+	}
+	if !retry {
+		return err
+	}
+
+	err = call(p.get())
+	pg.metrics.statementRetried(p.name, err)
+	return err
+}
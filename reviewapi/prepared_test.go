@@ -0,0 +1,117 @@
+package reviewapi
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+// newTestStatement prepares query as a preparedStmt on an in-memory SQLite
+// database, so the re-preparation logic can run without PostgreSQL.
+func newTestStatement(t *testing.T, query string) *preparedStmt {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	p, err := prepareStmt(context.Background(), db, "test", query)
+	if err != nil {
+		t.Fatalf("prepareStmt: %v", err)
+	}
+	t.Cleanup(func() { p.close() })
+	return p
+}
+
+// TestClosedStatement pins the database/sql error that isClosedStatement
+// matches by its text.
+func TestClosedStatement(t *testing.T) {
+	p := newTestStatement(t, "SELECT 1")
+	stmt := p.get()
+	if err := stmt.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	var one int
+	err := stmt.QueryRow().Scan(&one)
+	if !isClosedStatement(err) {
+		t.Fatalf("query on a closed statement: got %v, want %q", err, errStatementClosedText)
+	}
+	if isStaleStatement(err) {
+		t.Fatalf("isStaleStatement(%v) = true, want false: nothing to re-prepare", err)
+	}
+}
+
+// TestWithStatementClosedByReprepare checks that a call that picked up a
+// statement replaced and closed meanwhile runs again with the new statement,
+// even when it may not be retried after a stale statement error.
+func TestWithStatementClosedByReprepare(t *testing.T) {
+	p := newTestStatement(t, "SELECT 1")
+	pg := &PgDb{logger: discardLogger()}
+	ctx := context.Background()
+
+	calls := 0
+	err := pg.withStatement(ctx, p, false, func(stmt *sql.Stmt) error {
+		calls++
+		if calls == 1 {
+			// Another caller re-prepares the statement and the stale one is
+			// closed before this call runs
+			if prepared, err := p.reprepare(ctx, stmt); err != nil || !prepared {
+				t.Fatalf("reprepare = %v, %v; want a new statement", prepared, err)
+			}
+			if err := stmt.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+		}
+		var one int
+		return stmt.QueryRowContext(ctx).Scan(&one)
+	})
+	if err != nil || calls != 2 {
+		t.Fatalf("withStatement = %v after %d calls, want success on the second", err, calls)
+	}
+}
+
+// TestWithStatementStale checks that a statement the server no longer knows
+// (SQLSTATE 26000) is prepared again, and that only a call allowed to retry
+// runs again with the new statement.
+func TestWithStatementStale(t *testing.T) {
+	stale := &pq.Error{Code: "26000", Message: `prepared statement "1" does not exist`}
+	for _, tc := range []struct {
+		name      string
+		retry     bool
+		wantErr   error
+		wantCalls int
+	}{
+		{"retried", true, nil, 2},
+		{"not retried", false, stale, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := newTestStatement(t, "SELECT 1")
+			pg := &PgDb{logger: discardLogger()}
+			ctx := context.Background()
+			first := p.get()
+
+			var used []*sql.Stmt
+			err := pg.withStatement(ctx, p, tc.retry, func(stmt *sql.Stmt) error {
+				used = append(used, stmt)
+				if len(used) == 1 {
+					return stale
+				}
+				var one int
+				return stmt.QueryRowContext(ctx).Scan(&one)
+			})
+			if err != tc.wantErr || len(used) != tc.wantCalls {
+				t.Fatalf("withStatement = %v after %d calls, want %v after %d", err, len(used), tc.wantErr, tc.wantCalls)
+			}
+			if p.get() == first {
+				t.Fatalf("statement was not prepared again")
+			}
+			if tc.retry && used[1] != p.get() {
+				t.Fatalf("retry did not use the new statement")
+			}
+		})
+	}
+}
@@ -0,0 +1,454 @@
+// Package reviewapi provides read replica routing for the Movie Review API.
+// This file lets PgDb send reads to PostgreSQL streaming replicas while
+// writes go to the primary.
+//
+// Routing:
+//   - Replicas are checked every DBConfig.ReplicaCheckInterval; a replica
+//     that fails a check or a read is skipped until a later check succeeds
+//   - Reads (reviews by ID, the trash, revisions, the audit log and the
+//     event log) go to healthy replicas in turn and fall back to the
+//     primary when none is usable or the chosen replica's connection fails
+//   - Reads that must see every committed change, like the event feed
+//     following NOTIFY, use the primary (contextWithPrimaryReads)
+//
+// Read-your-writes consistency:
+//
+// After a write, the response carries an X-Consistency-Token header holding
+// the primary's WAL position (LSN) once the write committed. A client that
+// sends the token back on later requests is only served by replicas that
+// have replayed at least that position, and by the primary otherwise, so it
+// always sees its own writes. Clients that send no token may read data that
+// lags the primary by the replication delay.
+package reviewapi
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// consistencyTokenHeader carries the read-your-writes token.
+const consistencyTokenHeader = "X-Consistency-Token"
+
+// replica is a read-only PostgreSQL server and its health.
+type replica struct {
+	// name identifies the replica in logs and metrics (e.g. "replica-1").
+	name string
+
+	// db is the replica's connection pool.
+	db *sql.DB
+
+	// stmts are prepared by the first successful health check.
+	stmts atomic.Pointer[replicaStatements]
+
+	// healthy is set while the last check succeeded.
+	healthy atomic.Bool
+
+	// replayedLSN is the WAL position replayed as of the last check.
+	replayedLSN atomic.Uint64
+}
+
+// replicaStatements are the read statements prepared on a replica.
+type replicaStatements struct {
+	getById       *preparedStmt
+	listDeleted   *preparedStmt
+	listRevisions *preparedStmt
+	getRevision   *preparedStmt
+	listAudit     *preparedStmt
+	listEvents    *preparedStmt
+}
+
+// prepareReplicaStatements prepares the read statements on db.
+func prepareReplicaStatements(ctx context.Context, db *sql.DB) (*replicaStatements, error) {
+	stmts := &replicaStatements{}
+	for _, s := range []struct {
+		stmt  **preparedStmt
+		name  string
+		query string
+	}{
+		{&stmts.getById, "getById", queryGetReviewById},
+		{&stmts.listDeleted, "listDeleted", queryListDeleted},
+		{&stmts.listRevisions, "listRevisions", queryListRevisions},
+		{&stmts.getRevision, "getRevision", queryGetRevision},
+		{&stmts.listAudit, "listAudit", queryListAudit},
+		{&stmts.listEvents, "listEvents", queryListEvents},
+	} {
+		stmt, err := prepareStmt(ctx, db, s.name, s.query)
+		if err != nil {
+			stmts.close()
+			return nil, err
+		}
+		*s.stmt = stmt
+	}
+	return stmts, nil
+}
+
+// close closes the statements prepared so far.
+func (s *replicaStatements) close() {
+	for _, stmt := range []*preparedStmt{s.getById, s.listDeleted, s.listRevisions, s.getRevision, s.listAudit, s.listEvents} {
+		if stmt != nil {
+			stmt.close()
+		}
+	}
+}
+
+// parseLSN converts a PostgreSQL LSN ("16/B374D848") into a number.
+func parseLSN(lsn string) (uint64, error) {
+	high, low, ok := strings.Cut(lsn, "/")
+	if !ok {
+		return 0, fmt.Errorf("invalid LSN %q", lsn)
+	}
+	hi, err := strconv.ParseUint(high, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid LSN %q", lsn)
+	}
+	lo, err := strconv.ParseUint(low, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid LSN %q", lsn)
+	}
+	return hi<<32 | lo, nil
+}
+
+// formatLSN converts a number back into PostgreSQL's LSN notation.
+func formatLSN(lsn uint64) string {
+	return fmt.Sprintf("%X/%X", lsn>>32, lsn&0xFFFFFFFF)
+}
+
+// consistencySession tracks the WAL position a client must observe: the
+// one of its token, advanced by the writes of the current request.
+type consistencySession struct {
+	mu  sync.Mutex
+	lsn uint64
+}
+
+// minLSN returns the position reads must have replayed (0 for none).
+func (s *consistencySession) minLSN() uint64 {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lsn
+}
+
+// advance raises the position to lsn if it is later.
+func (s *consistencySession) advance(lsn uint64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lsn = max(s.lsn, lsn)
+}
+
+// consistencySessionKey is the context key of the request's session.
+type consistencySessionKey struct{}
+
+// consistencySessionFromContext returns the request's session, or nil.
+func consistencySessionFromContext(ctx context.Context) *consistencySession {
+	session, _ := ctx.Value(consistencySessionKey{}).(*consistencySession)
+	return session
+}
+
+// readPosition receives the WAL position a read was served at, for callers
+// that keep the row and must know which tokens it satisfies (the review
+// cache). A position of 0 means unknown.
+type readPosition struct {
+	lsn atomic.Uint64
+}
+
+// readPositionKey is the context key of the read's *readPosition.
+type readPositionKey struct{}
+
+// contextWithReadPosition returns a copy of ctx in which the backend records
+// the WAL position of the next read, and the position to read it back from.
+func contextWithReadPosition(ctx context.Context) (context.Context, *readPosition) {
+	position := &readPosition{}
+	return context.WithValue(ctx, readPositionKey{}, position), position
+}
+
+// recordReadPosition records that the read of ctx saw every write up to lsn.
+// It does nothing when the caller did not ask for the position.
+func recordReadPosition(ctx context.Context, lsn uint64) {
+	if position, _ := ctx.Value(readPositionKey{}).(*readPosition); position != nil {
+		position.lsn.Store(lsn)
+	}
+}
+
+// wantsReadPosition reports whether the caller of ctx asked for the read
+// position, so that the primary's position is only queried when needed.
+func wantsReadPosition(ctx context.Context) bool {
+	position, _ := ctx.Value(readPositionKey{}).(*readPosition)
+	return position != nil
+}
+
+// primaryReadsKey is the context key marking reads that must use the primary.
+type primaryReadsKey struct{}
+
+// contextWithPrimaryReads returns a copy of ctx whose reads are served by
+// the primary, for callers that must see every committed change.
+func contextWithPrimaryReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryReadsKey{}, true)
+}
+
+// consistencyWriter sets the consistency token header just before the
+// response header is written, after the handler's writes have run.
+type consistencyWriter struct {
+	http.ResponseWriter
+
+	session     *consistencySession
+	wroteHeader bool
+}
+
+// WriteHeader adds the token before delegating.
+func (w *consistencyWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if lsn := w.session.minLSN(); lsn != 0 {
+			w.ResponseWriter.Header().Set(consistencyTokenHeader, formatLSN(lsn))
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write adds the token for an implicit 200 before delegating.
+func (w *consistencyWriter) Write(body []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(body)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (w *consistencyWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// consistencyMiddleware starts a consistency session for every request from
+// its X-Consistency-Token header (an invalid token is ignored) and returns
+// the session's latest position in the response header.
+func consistencyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		session := &consistencySession{}
+		if token := request.Header.Get(consistencyTokenHeader); token != "" {
+			if lsn, err := parseLSN(token); err == nil {
+				session.lsn = lsn
+			}
+		}
+
+		ctx := context.WithValue(request.Context(), consistencySessionKey{}, session)
+		next.ServeHTTP(&consistencyWriter{ResponseWriter: writer, session: session}, request.WithContext(ctx))
+	})
+}
+
+// openReplicas opens a pool for each replica with the pool settings of cfg.
+// Nothing is contacted until the first health check.
+func openReplicas(cfg DBConfig, metrics *Metrics) ([]*replica, error) {
+	var replicas []*replica
+	for i, dsn := range cfg.Replicas {
+		replicaCfg := cfg
+		replicaCfg.URL = dsn
+		db, err := openPool(replicaCfg)
+		if err != nil {
+			closeReplicas(replicas)
+			return nil, fmt.Errorf("replica %d: %w", i+1, err)
+		}
+
+		r := &replica{name: fmt.Sprintf("replica-%d", i+1), db: db}
+		replicas = append(replicas, r)
+		if err := metrics.RegisterDBStats(r.name, db); err != nil {
+			closeReplicas(replicas)
+			return nil, fmt.Errorf("register pool metrics: %w", err)
+		}
+	}
+	return replicas, nil
+}
+
+// closeReplicas closes the statements and pools of replicas.
+func closeReplicas(replicas []*replica) {
+	for _, r := range replicas {
+		if stmts := r.stmts.Load(); stmts != nil {
+			stmts.close()
+		}
+		r.db.Close()
+	}
+}
+
+// startReplicaChecks checks every replica now and then every interval
+// until Close is called.
+func (pg *PgDb) startReplicaChecks() {
+	if len(pg.replicas) == 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	pg.stopReplicaChecks = func() {
+		cancel()
+		<-done
+	}
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(pg.replicaCheckInterval)
+		defer ticker.Stop()
+		for {
+			for _, r := range pg.replicas {
+				pg.checkReplica(ctx, r)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// checkReplica refreshes the health and replayed WAL position of r,
+// preparing its statements on the first successful check.
+func (pg *PgDb) checkReplica(ctx context.Context, r *replica) {
+	ctx, cancel := context.WithTimeout(ctx, pg.replicaCheckInterval)
+	defer cancel()
+
+	// A promoted or misconfigured "replica" reports no replay position
+	var lsnText string
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COALESCE(pg_last_wal_replay_lsn(), pg_current_wal_lsn())::text`).Scan(&lsnText)
+	if err != nil {
+		pg.setReplicaHealth(r, fmt.Errorf("health check: %w", err))
+		return
+	}
+	lsn, err := parseLSN(lsnText)
+	if err != nil {
+		pg.setReplicaHealth(r, err)
+		return
+	}
+
+	if r.stmts.Load() == nil {
+		stmts, err := prepareReplicaStatements(ctx, r.db)
+		if err != nil {
+			pg.setReplicaHealth(r, err)
+			return
+		}
+		r.stmts.Store(stmts)
+	}
+	r.replayedLSN.Store(lsn)
+	pg.setReplicaHealth(r, nil)
+}
+
+// setReplicaHealth marks r healthy (err == nil) or not, logging changes.
+func (pg *PgDb) setReplicaHealth(r *replica, err error) {
+	healthy := err == nil
+	if r.healthy.Swap(healthy) == healthy {
+		return
+	}
+	pg.metrics.setReplicaHealthy(r.name, healthy)
+	if healthy {
+		pg.logger.Info("replica healthy", "replica", r.name)
+	} else {
+		pg.logger.Warn("replica unhealthy, reads fall back", "replica", r.name, "error", err)
+	}
+}
+
+// pickReplica returns the next healthy replica that has replayed at least
+// minLSN, or nil when the primary must serve the read.
+func (pg *PgDb) pickReplica(minLSN uint64) *replica {
+	count := len(pg.replicas)
+	if count == 0 {
+		return nil
+	}
+	start := int(pg.nextReplica.Add(1))
+	for i := 0; i < count; i++ {
+		r := pg.replicas[(start+i)%count]
+		if r.healthy.Load() && r.replayedLSN.Load() >= minLSN {
+			return r
+		}
+	}
+	return nil
+}
+
+// withReadStatement runs the read call with the statement pick selects on a
+// replica that satisfies the consistency session of ctx, or with primary.
+// When the replica's connection fails, the replica leaves the rotation and
+// call runs again on the primary. The position the read was served at is
+// recorded for callers that asked (contextWithReadPosition).
+//
+// Parameters:
+//   - ctx: Context of the read; contextWithPrimaryReads forces the primary
+//   - primary: The statement on the primary
+//   - pick: Selects the same statement among a replica's statements
+//   - call: The read, receiving the statement to execute; it may run twice
+//
+// Returns:
+//   - error: The error of the last call
+func (pg *PgDb) withReadStatement(ctx context.Context, primary *preparedStmt, pick func(*replicaStatements) *preparedStmt, call func(*sql.Stmt) error) error {
+	// Prefer a replica that has caught up with the client's own writes
+	if forced, _ := ctx.Value(primaryReadsKey{}).(bool); !forced {
+		if r := pg.pickReplica(consistencySessionFromContext(ctx).minLSN()); r != nil {
+			// The replica has replayed at least this far; it only moves forward
+			replayed := r.replayedLSN.Load()
+			err := pg.withStatement(ctx, pick(r.stmts.Load()), true, call)
+			if classifyError(err) != errorConnection {
+				pg.metrics.storageRead(r.name)
+				recordReadPosition(ctx, replayed)
+				return err
+			}
+			// The replica is gone: take it out of rotation and use the primary
+			pg.setReplicaHealth(r, err)
+		}
+	}
+
+	pg.metrics.storageRead("primary")
+	pg.recordPrimaryPosition(ctx)
+	return pg.withStatement(ctx, primary, true, call)
+}
+
+// recordPrimaryPosition records the primary's current WAL position as the
+// read position of ctx, before a read from the primary: the read sees at
+// least the writes up to it. It does nothing without replicas, since no
+// consistency tokens are issued then, or when the caller did not ask.
+func (pg *PgDb) recordPrimaryPosition(ctx context.Context) {
+	if len(pg.replicas) == 0 || !wantsReadPosition(ctx) {
+		return
+	}
+
+	lsn, err := pg.primaryLSN(ctx)
+	if err != nil {
+		// Unknown (0): the row is then only reused by callers without a token
+		pg.logger.WarnContext(ctx, "read WAL position before read", "error", err)
+		return
+	}
+	recordReadPosition(ctx, lsn)
+}
+
+// recordWritePosition advances the request's consistency session to the
+// primary's current WAL position, which covers the write just committed.
+// It does nothing without replicas or outside an HTTP request.
+func (pg *PgDb) recordWritePosition(ctx context.Context) {
+	session := consistencySessionFromContext(ctx)
+	if len(pg.replicas) == 0 || session == nil {
+		return
+	}
+
+	lsn, err := pg.primaryLSN(ctx)
+	if err != nil {
+		pg.logger.WarnContext(ctx, "read WAL position after write", "error", err)
+		return
+	}
+	session.advance(lsn)
+}
+
+// primaryLSN returns the primary's current WAL position.
+func (pg *PgDb) primaryLSN(ctx context.Context) (uint64, error) {
+	var lsnText string
+	if err := pg.db.QueryRowContext(ctx, `SELECT pg_current_wal_lsn()::text`).Scan(&lsnText); err != nil {
+		return 0, err
+	}
+	return parseLSN(lsnText)
+}
@@ -0,0 +1,588 @@
+// Package reviewapi provides fault tolerance for the Movie Review API's storage.
+// This file implements Resilience, which retries transient database errors
+// with backoff and fails fast through a circuit breaker while the database
+// is degraded, instead of letting every request wait out its full operation
+// budget, and the decorators applying it to each store.
+//
+// Error classes (lib/pq SQLSTATE codes):
+//   - Rolled back (40001 serialization_failure, 40P01 deadlock_detected): the
+//     statement had no effect, so every operation may be retried
+//   - Connection lost (class 08, 57P01 admin_shutdown, driver.ErrBadConn,
+//     network errors): the outcome is unknown, so only reads are retried;
+//     a repeated write could duplicate its review, revision, audit entry or
+//     outbox event
+//
+// Both classes, and operation timeouts, count as failures for the breaker.
+// Cancellations and application errors (e.g. review not found) do not, and
+// a cancelled probe leaves a half-open circuit half-open.
+//
+// All stores share one breaker, since they share one database.
+package reviewapi
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrCircuitOpen is returned without calling the backend while the circuit
+// breaker is open.
+var ErrCircuitOpen = errors.New("storage circuit open")
+
+// ResilienceConfig holds the retry and circuit breaker settings applied to
+// storage operations.
+type ResilienceConfig struct {
+	// Retry controls retries of transient errors.
+	Retry StorageRetryConfig `yaml:"retry"`
+
+	// Breaker controls the circuit breaker.
+	Breaker BreakerConfig `yaml:"breaker"`
+}
+
+// StorageRetryConfig controls retries of a single storage operation.
+type StorageRetryConfig struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	// 1 disables retries.
+	MaxAttempts int `yaml:"max_attempts"`
+
+	// InitialInterval is the base delay before the first retry.
+	InitialInterval time.Duration `yaml:"initial_interval"`
+
+	// MaxInterval caps the delay between attempts.
+	MaxInterval time.Duration `yaml:"max_interval"`
+}
+
+// BreakerConfig controls when the circuit breaker opens.
+type BreakerConfig struct {
+	// Enabled turns on the circuit breaker.
+	Enabled bool `yaml:"enabled"`
+
+	// FailureRatio opens the circuit when this fraction of the calls in the
+	// current window failed (0 to 1).
+	FailureRatio float64 `yaml:"failure_ratio"`
+
+	// MinRequests is the number of calls a window needs before FailureRatio
+	// is evaluated, so a handful of errors at low traffic do not trip it.
+	MinRequests int `yaml:"min_requests"`
+
+	// Window is the length of the period over which calls are counted.
+	Window time.Duration `yaml:"window"`
+
+	// OpenDuration is how long the circuit stays open before a single
+	// probe call is let through to test the backend.
+	OpenDuration time.Duration `yaml:"open_duration"`
+}
+
+// Validate checks the retry and breaker settings.
+func (c ResilienceConfig) Validate() error {
+	if c.Retry.MaxAttempts < 1 {
+		return fmt.Errorf("retry max attempts must be at least 1")
+	}
+	if c.Retry.MaxAttempts > 1 {
+		backoff := BackoffConfig{InitialInterval: c.Retry.InitialInterval, MaxInterval: c.Retry.MaxInterval}
+		if err := backoff.Validate(); err != nil {
+			return fmt.Errorf("retry: %w", err)
+		}
+	}
+	if !c.Breaker.Enabled {
+		return nil
+	}
+	if c.Breaker.FailureRatio <= 0 || c.Breaker.FailureRatio > 1 {
+		return fmt.Errorf("breaker failure ratio must be in (0, 1]")
+	}
+	if c.Breaker.MinRequests < 1 {
+		return fmt.Errorf("breaker min requests must be at least 1")
+	}
+	if c.Breaker.Window <= 0 || c.Breaker.OpenDuration <= 0 {
+		return fmt.Errorf("breaker window and open duration must be positive")
+	}
+	return nil
+}
+
+// errorClass describes how a storage error may be handled.
+type errorClass int
+
+const (
+	// errorPermanent errors are returned as is and do not trip the breaker.
+	errorPermanent errorClass = iota
+
+	// errorRolledBack errors left no effect and may always be retried.
+	errorRolledBack
+
+	// errorConnection errors have an unknown outcome; only idempotent
+	// operations may be retried.
+	errorConnection
+
+	// errorTimeout errors exceeded their budget; they are not retried (the
+	// budget is spent) but count as failures.
+	errorTimeout
+)
+
+// classifyError determines the errorClass of a storage error.
+func classifyError(err error) errorClass {
+	if err == nil || errors.Is(err, ErrCanceled) || errors.Is(err, ErrNotReady) {
+		return errorPermanent
+	}
+	if errors.Is(err, ErrTimeout) {
+		return errorTimeout
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch {
+		case pqErr.Code == "40001", pqErr.Code == "40P01":
+			return errorRolledBack
+		case pqErr.Code.Class() == "08", pqErr.Code == "57P01":
+			return errorConnection
+		}
+		return errorPermanent
+	}
+
+	var netErr net.Error
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF) || errors.As(err, &netErr) ||
+		strings.Contains(err.Error(), "connection reset by peer") {
+		return errorConnection
+	}
+	return errorPermanent
+}
+
+// breakerState is the state of the circuit breaker.
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerHalfOpen
+	breakerOpen
+)
+
+// String returns the state name used in logs.
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// circuitBreaker counts call outcomes in fixed windows and rejects calls
+// while open. After OpenDuration a single probe is allowed (half-open);
+// its success closes the circuit, its failure opens it again.
+type circuitBreaker struct {
+	cfg     BreakerConfig
+	logger  *slog.Logger
+	metrics *Metrics
+
+	mu          sync.Mutex
+	state       breakerState
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	probing     bool
+}
+
+// allow reports whether a call may proceed, and whether it is the probe
+// deciding if a half-open circuit closes.
+func (b *circuitBreaker) allow(now time.Time) (allowed, probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if now.Sub(b.openedAt) < b.cfg.OpenDuration {
+			return false, false
+		}
+		b.setState(breakerHalfOpen)
+		fallthrough
+	case breakerHalfOpen:
+		if b.probing {
+			return false, false
+		}
+		b.probing = true
+		return true, true
+	default:
+		return true, false
+	}
+}
+
+// record registers the outcome of an allowed call. Outcomes of calls that
+// started before the circuit opened are ignored until it closes again.
+func (b *circuitBreaker) record(now time.Time, failed, probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if probe {
+		b.probing = false
+		if failed {
+			b.open(now)
+		} else {
+			b.setState(breakerClosed)
+			b.windowStart, b.requests, b.failures = now, 0, 0
+		}
+		return
+	}
+	if b.state != breakerClosed {
+		return
+	}
+
+	if now.Sub(b.windowStart) >= b.cfg.Window {
+		b.windowStart, b.requests, b.failures = now, 0, 0
+	}
+	b.requests++
+	if failed {
+		b.failures++
+	}
+	if b.requests >= b.cfg.MinRequests && float64(b.failures) >= b.cfg.FailureRatio*float64(b.requests) {
+		b.open(now)
+	}
+}
+
+// abandonProbe frees the probe slot of a half-open circuit without a
+// verdict, when the probe was cancelled: the next call probes instead.
+func (b *circuitBreaker) abandonProbe() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// open moves the breaker to the open state. The caller holds mu.
+func (b *circuitBreaker) open(now time.Time) {
+	b.openedAt = now
+	b.setState(breakerOpen)
+}
+
+// setState changes the state, logging and exporting transitions. The caller holds mu.
+func (b *circuitBreaker) setState(state breakerState) {
+	if b.state == state {
+		return
+	}
+	b.logger.Warn("storage circuit state changed", "from", b.state.String(), "to", state.String(),
+		"failures", b.failures, "requests", b.requests)
+	b.state = state
+	b.metrics.setBreakerState(int(state))
+}
+
+// Resilience is the retry policy and circuit breaker shared by the stores
+// of one database. Its methods wrap each store with them.
+type Resilience struct {
+	retry   StorageRetryConfig
+	breaker *circuitBreaker // nil when disabled
+	logger  *slog.Logger
+	metrics *Metrics
+}
+
+// NewResilience creates the retry policy and circuit breaker of cfg.
+//
+// Parameters:
+//   - cfg: Retry and breaker settings
+//   - logger: Logger for retries and breaker transitions
+//   - metrics: Where to record retries and breaker state (may be nil)
+//
+// Returns:
+//   - *Resilience: The policy, or nil when retries and the breaker are both
+//     disabled; the methods of a nil policy return the stores they are
+//     given unchanged
+func NewResilience(cfg ResilienceConfig, logger *slog.Logger, metrics *Metrics) *Resilience {
+	if cfg.Retry.MaxAttempts <= 1 && !cfg.Breaker.Enabled {
+		return nil
+	}
+	logger = logger.With("component", "resilience")
+	r := &Resilience{retry: cfg.Retry, logger: logger, metrics: metrics}
+	if cfg.Breaker.Enabled {
+		r.breaker = &circuitBreaker{cfg: cfg.Breaker, logger: logger, metrics: metrics}
+	}
+	return r
+}
+
+// Storage wraps next with the retry policy and circuit breaker.
+func (r *Resilience) Storage(next Storage) Storage {
+	if r == nil {
+		return next
+	}
+	return &resilientStorage{Resilience: r, next: next}
+}
+
+// Trash wraps next with the retry policy and circuit breaker.
+func (r *Resilience) Trash(next TrashStore) TrashStore {
+	if r == nil {
+		return next
+	}
+	return &resilientTrash{Resilience: r, next: next}
+}
+
+// Revisions wraps next with the retry policy and circuit breaker.
+func (r *Resilience) Revisions(next RevisionStore) RevisionStore {
+	if r == nil {
+		return next
+	}
+	return &resilientRevisions{Resilience: r, next: next}
+}
+
+// Audit wraps next with the retry policy and circuit breaker.
+func (r *Resilience) Audit(next AuditStore) AuditStore {
+	if r == nil {
+		return next
+	}
+	return &resilientAudit{Resilience: r, next: next}
+}
+
+// WebhookAdmin wraps next with the retry policy and circuit breaker.
+func (r *Resilience) WebhookAdmin(next WebhookAdmin) WebhookAdmin {
+	if r == nil {
+		return next
+	}
+	return &resilientWebhookAdmin{Resilience: r, next: next}
+}
+
+// EventLog wraps next with the retry policy and circuit breaker.
+func (r *Resilience) EventLog(next EventLog) EventLog {
+	if r == nil {
+		return next
+	}
+	return &resilientEventLog{Resilience: r, next: next}
+}
+
+// do runs call under the breaker, retrying transient errors.
+//
+// Parameters:
+//   - ctx: Context aborting the call and the waits between attempts
+//   - operation: The operation name used in logs and metrics
+//   - idempotent: Whether call may be repeated after a connection error
+//   - call: The storage call
+//
+// Returns:
+//   - error: ErrCircuitOpen if rejected, otherwise the last error of call
+func (r *Resilience) do(ctx context.Context, operation string, idempotent bool, call func(context.Context) error) error {
+	backoff := BackoffConfig{InitialInterval: r.retry.InitialInterval, MaxInterval: r.retry.MaxInterval}
+
+	for attempt := 0; ; attempt++ {
+		var probe bool
+		if r.breaker != nil {
+			var allowed bool
+			if allowed, probe = r.breaker.allow(time.Now()); !allowed {
+				r.metrics.circuitRejected(operation)
+				return fmt.Errorf("%s: %w", operation, ErrCircuitOpen)
+			}
+		}
+
+		err := call(ctx)
+		class := classifyError(err)
+		if r.breaker != nil {
+			if probe && errors.Is(err, ErrCanceled) {
+				// The caller left before the probe showed anything
+				r.breaker.abandonProbe()
+			} else {
+				r.breaker.record(time.Now(), class != errorPermanent, probe)
+			}
+		}
+
+		retryable := class == errorRolledBack || (class == errorConnection && idempotent)
+		if !retryable || attempt+1 >= r.retry.MaxAttempts {
+			return err
+		}
+
+		wait := backoff.delay(attempt)
+		r.metrics.storageRetried(operation)
+		r.logger.WarnContext(ctx, "retrying storage operation", "operation", operation,
+			"attempt", attempt+1, "retry_in", wait, "error", err)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// resilientStorage is a Storage decorator that retries transient errors and
+// fails fast through the circuit breaker.
+type resilientStorage struct {
+	*Resilience
+	next Storage
+}
+
+// CreateReview retries only errors that guarantee the insert was rolled
+// back, since repeating it after a lost connection could duplicate the review.
+func (s *resilientStorage) CreateReview(ctx context.Context, review *Review) (result string, err error) {
+	err = s.do(ctx, "CreateReview", false, func(ctx context.Context) (err error) {
+		result, err = s.next.CreateReview(ctx, review)
+		return err
+	})
+	return result, err
+}
+
+// UpdateReview retries only errors that guarantee the update was rolled
+// back: a committed update also recorded a revision, an audit entry and an
+// outbox event, which a repeat after a lost connection would duplicate.
+func (s *resilientStorage) UpdateReview(ctx context.Context, review *Review) error {
+	return s.do(ctx, "UpdateReview", false, func(ctx context.Context) error {
+		return s.next.UpdateReview(ctx, review)
+	})
+}
+
+// DeleteReview retries only errors that guarantee the delete was rolled
+// back, like UpdateReview: a committed delete also recorded an audit entry
+// and an outbox event.
+func (s *resilientStorage) DeleteReview(ctx context.Context, id int) error {
+	return s.do(ctx, "DeleteReview", false, func(ctx context.Context) error {
+		return s.next.DeleteReview(ctx, id)
+	})
+}
+
+// GetReviewById retries transient errors.
+func (s *resilientStorage) GetReviewById(ctx context.Context, id int) (review *Review, err error) {
+	err = s.do(ctx, "GetReviewById", true, func(ctx context.Context) (err error) {
+		review, err = s.next.GetReviewById(ctx, id)
+		return err
+	})
+	return review, err
+}
+
+// resilientTrash is a TrashStore decorator applying the Resilience policy.
+type resilientTrash struct {
+	*Resilience
+	next TrashStore
+}
+
+// ListDeletedReviews retries transient errors.
+func (t *resilientTrash) ListDeletedReviews(ctx context.Context, limit int) (reviews []*Review, err error) {
+	err = t.do(ctx, "ListDeletedReviews", true, func(ctx context.Context) (err error) {
+		reviews, err = t.next.ListDeletedReviews(ctx, limit)
+		return err
+	})
+	return reviews, err
+}
+
+// RestoreReview retries only errors that guarantee the restore was rolled
+// back, since it records an audit entry and an outbox event.
+func (t *resilientTrash) RestoreReview(ctx context.Context, id int) (review *Review, err error) {
+	err = t.do(ctx, "RestoreReview", false, func(ctx context.Context) (err error) {
+		review, err = t.next.RestoreReview(ctx, id)
+		return err
+	})
+	return review, err
+}
+
+// PurgeDeletedReviews retries only errors that guarantee the purge was
+// rolled back; a purge lost to a connection error runs again at the next
+// interval.
+func (t *resilientTrash) PurgeDeletedReviews(ctx context.Context, cutoff time.Time) (purged int, err error) {
+	err = t.do(ctx, "PurgeDeletedReviews", false, func(ctx context.Context) (err error) {
+		purged, err = t.next.PurgeDeletedReviews(ctx, cutoff)
+		return err
+	})
+	return purged, err
+}
+
+// resilientRevisions is a RevisionStore decorator applying the Resilience policy.
+type resilientRevisions struct {
+	*Resilience
+	next RevisionStore
+}
+
+// ListRevisions retries transient errors.
+func (r *resilientRevisions) ListRevisions(ctx context.Context, id int) (revisions []*Revision, err error) {
+	err = r.do(ctx, "ListRevisions", true, func(ctx context.Context) (err error) {
+		revisions, err = r.next.ListRevisions(ctx, id)
+		return err
+	})
+	return revisions, err
+}
+
+// GetRevision retries transient errors.
+func (r *resilientRevisions) GetRevision(ctx context.Context, id, revision int) (result *Revision, err error) {
+	err = r.do(ctx, "GetRevision", true, func(ctx context.Context) (err error) {
+		result, err = r.next.GetRevision(ctx, id, revision)
+		return err
+	})
+	return result, err
+}
+
+// RevertReview retries only errors that guarantee the revert was rolled
+// back, since it records a revision, an audit entry and an outbox event.
+func (r *resilientRevisions) RevertReview(ctx context.Context, id, revision int) (review *Review, err error) {
+	err = r.do(ctx, "RevertReview", false, func(ctx context.Context) (err error) {
+		review, err = r.next.RevertReview(ctx, id, revision)
+		return err
+	})
+	return review, err
+}
+
+// resilientAudit is an AuditStore decorator applying the Resilience policy.
+type resilientAudit struct {
+	*Resilience
+	next AuditStore
+}
+
+// ListAuditEntries retries transient errors.
+func (a *resilientAudit) ListAuditEntries(ctx context.Context, query AuditQuery) (entries []*AuditEntry, err error) {
+	err = a.do(ctx, "ListAuditEntries", true, func(ctx context.Context) (err error) {
+		entries, err = a.next.ListAuditEntries(ctx, query)
+		return err
+	})
+	return entries, err
+}
+
+// resilientWebhookAdmin is a WebhookAdmin decorator applying the Resilience policy.
+type resilientWebhookAdmin struct {
+	*Resilience
+	next WebhookAdmin
+}
+
+// ListDeadWebhookDeliveries retries transient errors.
+func (w *resilientWebhookAdmin) ListDeadWebhookDeliveries(ctx context.Context, limit int) (deliveries []*WebhookDelivery, err error) {
+	err = w.do(ctx, "ListDeadWebhookDeliveries", true, func(ctx context.Context) (err error) {
+		deliveries, err = w.next.ListDeadWebhookDeliveries(ctx, limit)
+		return err
+	})
+	return deliveries, err
+}
+
+// RetryWebhookDelivery retries only errors that guarantee the change was
+// rolled back: once committed, a repeat finds the delivery no longer dead.
+func (w *resilientWebhookAdmin) RetryWebhookDelivery(ctx context.Context, id int64) (delivery *WebhookDelivery, err error) {
+	err = w.do(ctx, "RetryWebhookDelivery", false, func(ctx context.Context) (err error) {
+		delivery, err = w.next.RetryWebhookDelivery(ctx, id)
+		return err
+	})
+	return delivery, err
+}
+
+// resilientEventLog is an EventLog decorator applying the Resilience policy.
+type resilientEventLog struct {
+	*Resilience
+	next EventLog
+}
+
+// ListReviewEvents retries transient errors.
+func (e *resilientEventLog) ListReviewEvents(ctx context.Context, afterID int64, limit int) (events []*ReviewEvent, err error) {
+	err = e.do(ctx, "ListReviewEvents", true, func(ctx context.Context) (err error) {
+		events, err = e.next.ListReviewEvents(ctx, afterID, limit)
+		return err
+	})
+	return events, err
+}
+
+// OldestReviewEventID retries transient errors.
+func (e *resilientEventLog) OldestReviewEventID(ctx context.Context) (id int64, err error) {
+	err = e.do(ctx, "OldestReviewEventID", true, func(ctx context.Context) (err error) {
+		id, err = e.next.OldestReviewEventID(ctx)
+		return err
+	})
+	return id, err
+}
@@ -0,0 +1,148 @@
+package reviewapi
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// TestCircuitBreakerTransitions walks the breaker through its states:
+// closed until the failure ratio is reached in a window, open for
+// OpenDuration, then half-open with a single probe deciding the next state.
+func TestCircuitBreakerTransitions(t *testing.T) {
+	cfg := BreakerConfig{Enabled: true, FailureRatio: 0.5, MinRequests: 4, Window: 10 * time.Second, OpenDuration: 5 * time.Second}
+	breaker := &circuitBreaker{cfg: cfg, logger: discardLogger()}
+	start := time.Date(2026, time.January, 15, 10, 30, 0, 0, time.UTC)
+	at := func(offset time.Duration) time.Time { return start.Add(offset) }
+
+	// expect checks the state and what allow returns at now
+	expect := func(step string, now time.Time, state breakerState, allowed, probe bool) {
+		t.Helper()
+		gotAllowed, gotProbe := breaker.allow(now)
+		breaker.mu.Lock()
+		gotState := breaker.state
+		breaker.mu.Unlock()
+		if gotState != state || gotAllowed != allowed || gotProbe != probe {
+			t.Fatalf("%s: state %s, allow = %t, %t; want %s, %t, %t",
+				step, gotState, gotAllowed, gotProbe, state, allowed, probe)
+		}
+	}
+
+	// Failures of a past window do not count
+	breaker.record(at(0), true, false)
+	breaker.record(at(0), true, false)
+	breaker.record(at(0), true, false)
+	breaker.record(at(11*time.Second), false, false)
+	expect("new window", at(11*time.Second), breakerClosed, true, false)
+
+	// Below MinRequests the ratio does not apply
+	breaker.record(at(12*time.Second), true, false)
+	breaker.record(at(12*time.Second), true, false)
+	expect("too few requests", at(12*time.Second), breakerClosed, true, false)
+
+	// 3 of 4 calls failed: open
+	breaker.record(at(12*time.Second), true, false)
+	expect("open", at(12*time.Second), breakerOpen, false, false)
+	expect("still open", at(16*time.Second), breakerOpen, false, false)
+
+	// After OpenDuration a single probe is let through
+	expect("probe", at(17*time.Second), breakerHalfOpen, true, true)
+	expect("second caller while probing", at(17*time.Second), breakerHalfOpen, false, false)
+
+	// A late outcome of a call made before the circuit opened is ignored
+	breaker.record(at(17*time.Second), false, false)
+	expect("late outcome", at(17*time.Second), breakerHalfOpen, false, false)
+
+	// A failed probe opens the circuit for another OpenDuration
+	breaker.record(at(18*time.Second), true, true)
+	expect("failed probe", at(22*time.Second), breakerOpen, false, false)
+
+	// A successful probe closes it with a fresh window
+	expect("second probe", at(23*time.Second), breakerHalfOpen, true, true)
+	breaker.record(at(23*time.Second), false, true)
+	expect("closed", at(23*time.Second), breakerClosed, true, false)
+	breaker.record(at(23*time.Second), true, false)
+	expect("fresh window", at(23*time.Second), breakerClosed, true, false)
+}
+
+// TestResilienceCancelledProbe checks that a probe cancelled by its caller
+// neither closes nor reopens the circuit: the next call probes instead.
+func TestResilienceCancelledProbe(t *testing.T) {
+	cfg := ResilienceConfig{
+		Retry:   StorageRetryConfig{MaxAttempts: 1},
+		Breaker: BreakerConfig{Enabled: true, FailureRatio: 1, MinRequests: 1, Window: time.Minute, OpenDuration: 20 * time.Millisecond},
+	}
+	resilience := NewResilience(cfg, discardLogger(), nil)
+	ctx := context.Background()
+	state := func() breakerState {
+		resilience.breaker.mu.Lock()
+		defer resilience.breaker.mu.Unlock()
+		return resilience.breaker.state
+	}
+
+	// One lost connection opens the circuit
+	resilience.do(ctx, "test", true, func(context.Context) error { return driver.ErrBadConn })
+	if err := resilience.do(ctx, "test", true, func(context.Context) error { return nil }); err == nil {
+		t.Fatalf("call while open succeeded, want ErrCircuitOpen")
+	}
+
+	// The probe's caller goes away
+	time.Sleep(2 * cfg.Breaker.OpenDuration)
+	cancelled := fmt.Errorf("failed to get review: %w", ErrCanceled)
+	if err := resilience.do(ctx, "test", true, func(context.Context) error { return cancelled }); err != cancelled {
+		t.Fatalf("cancelled probe returned %v, want %v", err, cancelled)
+	}
+	if got := state(); got != breakerHalfOpen {
+		t.Fatalf("state after a cancelled probe %s, want half-open", got)
+	}
+
+	// The next call is the probe, and its success closes the circuit
+	called := false
+	if err := resilience.do(ctx, "test", true, func(context.Context) error { called = true; return nil }); err != nil || !called {
+		t.Fatalf("probe after the cancelled one = %v (called %t), want it to run", err, called)
+	}
+	if got := state(); got != breakerClosed {
+		t.Fatalf("state after a successful probe %s, want closed", got)
+	}
+}
+
+// countingStorage is a Storage failing writes with err, counting the calls.
+type countingStorage struct {
+	Storage
+	err   error
+	calls int
+}
+
+func (s *countingStorage) UpdateReview(ctx context.Context, review *Review) error {
+	s.calls++
+	return s.err
+}
+
+// TestResilientStorageWriteRetries checks that writes are only retried
+// after errors that guarantee a rollback: a write lost with its connection
+// may have committed its revision, audit entry and outbox event.
+func TestResilientStorageWriteRetries(t *testing.T) {
+	cfg := ResilienceConfig{Retry: StorageRetryConfig{MaxAttempts: 3, InitialInterval: time.Millisecond, MaxInterval: time.Millisecond}}
+	for _, tc := range []struct {
+		name  string
+		err   error
+		calls int
+	}{
+		{"serialization failure", &pq.Error{Code: "40001"}, 3},
+		{"deadlock", &pq.Error{Code: "40P01"}, 3},
+		{"lost connection", driver.ErrBadConn, 1},
+		{"admin shutdown", &pq.Error{Code: "57P01"}, 1},
+		{"not found", fmt.Errorf("review with id 42 %w", ErrNotFound), 1},
+	} {
+		backend := &countingStorage{err: tc.err}
+		storage := NewResilience(cfg, discardLogger(), nil).Storage(backend)
+		storage.UpdateReview(context.Background(), storedReview(42))
+		if backend.calls != tc.calls {
+			t.Errorf("%s: %d calls, want %d", tc.name, backend.calls, tc.calls)
+		}
+	}
+}
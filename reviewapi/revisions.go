@@ -0,0 +1,630 @@
+// Package reviewapi provides the review revision history for the Movie Review API.
+// This file records a revision for every version of a review and implements
+// listing, comparing and reverting them.
+//
+// Behaviour:
+//   - CreateReview records revision 1; UpdateReview records the next revision
+//     when at least one field changes, in the same transaction as the write
+//   - Each revision holds the review's content, the fields changed since the
+//     previous revision, the author (the request's actor, see
+//     ActorFromContext) and the time of the change
+//   - RevertReview writes the content of an earlier revision back like an
+//     update, recording a new revision, a "revert" audit entry and a
+//     review.updated event, in one transaction
+//   - Revisions of reviews in the trash are hidden, and purging a review
+//     removes its history
+//
+// API Endpoints:
+//   - GET  /review/{id}/revisions                   - List revisions, newest first
+//   - GET  /review/{id}/revisions/{revision}        - Get one revision
+//   - GET  /review/{id}/revisions/diff?from=1&to=3  - Compare two revisions
+//   - POST /review/{id}/revisions/{revision}/revert - Revert to a revision
+package reviewapi
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// revisionColumns are the review_revisions columns read by scanRevision, in
+// order, qualified for queries joining reviews.
+const revisionColumns = `v.reviewId, v.revision, v.author, v.createdAt, v.changedFields,
+	v.title, v.director, v.releaseDate, v.rating, v.reviewNotes`
+
+// RevisionStore is implemented by the backends to read the revision history
+// of the reviews. The revision routes are served when one is given with
+// WithRevisions.
+type RevisionStore interface {
+	// ListRevisions returns the revisions of a review, newest first.
+	// Returns an error wrapping ErrNotFound if the review does not exist or
+	// is in the trash.
+	ListRevisions(ctx context.Context, id int) ([]*Revision, error)
+
+	// GetRevision returns one revision of a review. Returns an error
+	// wrapping ErrNotFound if there is no such revision.
+	GetRevision(ctx context.Context, id, revision int) (*Revision, error)
+
+	// RevertReview writes the content of a revision back to its review and
+	// returns the review. Returns an error wrapping ErrNotFound if there is
+	// no such revision or the review is in the trash.
+	RevertReview(ctx context.Context, id, revision int) (*Review, error)
+}
+
+// ReviewFields are the names of the fields tracked by the revision history,
+// in the order of reviewFieldValues.
+var ReviewFields = []string{"title", "director", "releaseDate", "rating", "reviewNotes"}
+
+// reviewFieldValues returns the tracked fields of review, in ReviewFields order.
+func reviewFieldValues(review *Review) []string {
+	return []string{review.Title, review.Director, review.ReleaseDate, review.Rating, review.ReviewNotes}
+}
+
+// changedFields returns the names of the tracked fields that differ between
+// before and after.
+func changedFields(before, after *Review) []string {
+	var changed []string
+	afterValues := reviewFieldValues(after)
+	for i, value := range reviewFieldValues(before) {
+		if value != afterValues[i] {
+			changed = append(changed, ReviewFields[i])
+		}
+	}
+	return changed
+}
+
+// review returns the content of the revision as a review to write back.
+func (r *Revision) review() *Review {
+	return &Review{
+		ID:          r.ReviewID,
+		Title:       r.Title,
+		Director:    r.Director,
+		ReleaseDate: r.ReleaseDate,
+		Rating:      r.Rating,
+		ReviewNotes: r.ReviewNotes,
+	}
+}
+
+// diffRevisions compares two revisions of the same review field by field.
+func diffRevisions(from, to *Revision) RevisionDiff {
+	diff := RevisionDiff{ReviewID: to.ReviewID, From: from.Revision, To: to.Revision, Changes: []FieldChange{}}
+	fromValues := reviewFieldValues(from.review())
+	toValues := reviewFieldValues(to.review())
+	for i, field := range ReviewFields {
+		if fromValues[i] != toValues[i] {
+			diff.Changes = append(diff.Changes, FieldChange{Field: field, From: fromValues[i], To: toValues[i]})
+		}
+	}
+	return diff
+}
+
+// scanRevision reads the revisionColumns of row into revision.
+func scanRevision(row rowScanner, revision *Revision) error {
+	var changed string
+	err := row.Scan(
+		&revision.ReviewID,
+		&revision.Revision,
+		&revision.Author,
+		&revision.CreatedAt,
+		&changed,
+		&revision.Title,
+		&revision.Director,
+		&revision.ReleaseDate,
+		&revision.Rating,
+		&revision.ReviewNotes)
+	revision.ChangedFields = strings.Split(changed, ",")
+	return err
+}
+
+// scanRevisions reads every revision of rows and closes them. It returns
+// sql.ErrNoRows when there is none, since every live review has at least one.
+func scanRevisions(rows *sql.Rows) ([]*Revision, error) {
+	defer rows.Close()
+	var revisions []*Revision
+	for rows.Next() {
+		revision := &Revision{}
+		if err := scanRevision(rows, revision); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return revisions, nil
+}
+
+// revisionArgs returns the arguments of the insertRevision statements for
+// the new version review, authored by the actor of ctx.
+func revisionArgs(ctx context.Context, review *Review, changed []string) []any {
+	args := []any{review.ID, ActorFromContext(ctx), review.UpdatedAt.UTC(), strings.Join(changed, ",")}
+	for _, value := range reviewFieldValues(review) {
+		args = append(args, value)
+	}
+	return args
+}
+
+// listRevisionsResult turns the outcome of a revisions query into
+// ListRevisions' error, mapping no rows to ErrNotFound.
+func listRevisionsResult(ctx context.Context, id int, err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("review with id %d %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list revisions: %w", contextError(ctx, err))
+	}
+	return nil
+}
+
+// getRevisionResult turns the outcome of a revision query into
+// GetRevision's error, mapping a missing row to ErrNotFound.
+func getRevisionResult(ctx context.Context, id, revision int, err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("revision %d of review with id %d %w", revision, id, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get revision: %w", contextError(ctx, err))
+	}
+	return nil
+}
+
+// revertResult turns the outcome of a revert transaction into
+// RevertReview's error, mapping a missing review to ErrNotFound.
+func revertResult(ctx context.Context, id int, err error) error {
+	if errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("review with id %d %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to revert review: %w", contextError(ctx, err))
+	}
+	return nil
+}
+
+// insertRevision records review as its next revision within tx.
+func (pg *PgDb) insertRevision(ctx context.Context, tx *sql.Tx, review *Review, changed []string) error {
+	return pg.withStatement(ctx, pg.stmtInsertRevision, false, func(stmt *sql.Stmt) error {
+		_, err := tx.StmtContext(ctx, stmt).ExecContext(ctx, revisionArgs(ctx, review, changed)...)
+		return err
+	})
+}
+
+// ListRevisions returns the revisions of a review, newest first.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout control
+//   - id: The unique identifier of the review
+//
+// Returns:
+//   - []*Revision: The revisions, at least one
+//   - error: Non-nil if the query fails; wraps ErrNotFound if the review does
+//     not exist or is in the trash
+func (pg *PgDb) ListRevisions(ctx context.Context, id int) (_ []*Revision, err error) {
+	ctx, finish := pg.beginOperation(ctx, "listRevisions", "SELECT", id)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !pg.ready.Load() {
+		return nil, ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, pg.timeouts.Get)
+	defer cancel()
+
+	// Served by a caught-up replica if any; a stale statement is retried once
+	var revisions []*Revision
+	err = pg.withReadStatement(ctx, pg.stmtListRevisions, func(s *replicaStatements) *preparedStmt { return s.listRevisions }, func(stmt *sql.Stmt) error {
+		rows, err := stmt.QueryContext(ctx, id)
+		if err != nil {
+			return err
+		}
+		revisions, err = scanRevisions(rows)
+		return err
+	})
+	if err = listRevisionsResult(ctx, id, err); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// GetRevision returns one revision of a review.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout control
+//   - id: The unique identifier of the review
+//   - revision: The revision number
+//
+// Returns:
+//   - *Revision: The revision
+//   - error: Non-nil if the query fails; wraps ErrNotFound if the revision
+//     does not exist or the review is in the trash
+func (pg *PgDb) GetRevision(ctx context.Context, id, revision int) (_ *Revision, err error) {
+	ctx, finish := pg.beginOperation(ctx, "getRevision", "SELECT", id)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !pg.ready.Load() {
+		return nil, ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, pg.timeouts.Get)
+	defer cancel()
+
+	result := &Revision{}
+	err = pg.withReadStatement(ctx, pg.stmtGetRevision, func(s *replicaStatements) *preparedStmt { return s.getRevision }, func(stmt *sql.Stmt) error {
+		return scanRevision(stmt.QueryRowContext(ctx, id, revision), result)
+	})
+	if err = getRevisionResult(ctx, id, revision, err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// RevertReview writes the content of a revision back to its review.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout control
+//   - id: The unique identifier of the review
+//   - revision: The revision number to revert to
+//
+// Returns:
+//   - *Review: The reverted review
+//   - error: Non-nil if the update fails; wraps ErrNotFound if the revision
+//     does not exist or the review is in the trash
+//
+// The revision is read under the review's lock and written back like
+// UpdateReview does, recording a revision when a field changes, a "revert"
+// audit entry and a review.updated event, all in one transaction.
+func (pg *PgDb) RevertReview(ctx context.Context, id, revision int) (_ *Review, err error) {
+	ctx, finish := pg.beginOperation(ctx, "revert", "UPDATE", id)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !pg.ready.Load() {
+		return nil, ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, pg.timeouts.Update)
+	defer cancel()
+
+	var after *Review
+	err = pg.inTx(ctx, func(tx *sql.Tx) error {
+		// Lock the current version; reviews in the trash are not found
+		current, err := pg.lockReview(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if current.DeletedAt != nil {
+			return sql.ErrNoRows
+		}
+
+		// Read the revision under the lock, so no update slips in between
+		target := &Revision{}
+		err = pg.withStatement(ctx, pg.stmtGetRevision, false, func(stmt *sql.Stmt) error {
+			return scanRevision(tx.StmtContext(ctx, stmt).QueryRowContext(ctx, id, revision), target)
+		})
+		if err = getRevisionResult(ctx, id, revision, err); err != nil {
+			return err
+		}
+
+		review := target.review()
+		err = pg.withStatement(ctx, pg.stmtUpdate, false, func(stmt *sql.Stmt) error {
+			return tx.StmtContext(ctx, stmt).QueryRowContext(ctx,
+				review.Title,
+				review.Director,
+				review.ReleaseDate,
+				review.Rating,
+				review.ReviewNotes,
+				review.ID).Scan(&review.UpdatedAt)
+		})
+		if err != nil {
+			return err
+		}
+		if changed := changedFields(current, review); len(changed) > 0 {
+			if err := pg.insertRevision(ctx, tx, review, changed); err != nil {
+				return err
+			}
+		}
+		after = updatedReview(current, review)
+		if err := pg.insertAudit(ctx, tx, AuditRevert, id, current, after); err != nil {
+			return err
+		}
+		return pg.insertEvent(ctx, tx, EventReviewUpdated, after)
+	})
+	if err = revertResult(ctx, id, err); err != nil {
+		return nil, err
+	}
+	pg.recordWritePosition(ctx)
+	return after, nil
+}
+
+// insertRevision records review as its next revision within tx.
+func (s *SqliteDb) insertRevision(ctx context.Context, tx *sql.Tx, review *Review, changed []string) error {
+	_, err := tx.StmtContext(ctx, s.stmtInsertRevision).ExecContext(ctx, revisionArgs(ctx, review, changed)...)
+	return err
+}
+
+// ListRevisions returns the revisions of a review, newest first (see
+// PgDb.ListRevisions).
+func (s *SqliteDb) ListRevisions(ctx context.Context, id int) (_ []*Revision, err error) {
+	ctx, finish := s.beginOperation(ctx, "listRevisions", "SELECT", id)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !s.ready.Load() {
+		return nil, ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Get)
+	defer cancel()
+
+	rows, err := s.stmtListRevisions.QueryContext(ctx, id)
+	var revisions []*Revision
+	if err == nil {
+		revisions, err = scanRevisions(rows)
+	}
+	if err = listRevisionsResult(ctx, id, err); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// GetRevision returns one revision of a review (see PgDb.GetRevision).
+func (s *SqliteDb) GetRevision(ctx context.Context, id, revision int) (_ *Revision, err error) {
+	ctx, finish := s.beginOperation(ctx, "getRevision", "SELECT", id)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !s.ready.Load() {
+		return nil, ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Get)
+	defer cancel()
+
+	result := &Revision{}
+	err = scanRevision(s.stmtGetRevision.QueryRowContext(ctx, id, revision), result)
+	if err = getRevisionResult(ctx, id, revision, err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// RevertReview writes the content of a revision back to its review in one
+// transaction (see PgDb.RevertReview).
+func (s *SqliteDb) RevertReview(ctx context.Context, id, revision int) (_ *Review, err error) {
+	ctx, finish := s.beginOperation(ctx, "revert", "UPDATE", id)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !s.ready.Load() {
+		return nil, ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Update)
+	defer cancel()
+
+	updatedAt := time.Now().UTC()
+	var after *Review
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		// Read the current version; reviews in the trash are not found
+		current, err := s.lockReview(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if current.DeletedAt != nil {
+			return sql.ErrNoRows
+		}
+
+		target := &Revision{}
+		err = scanRevision(tx.StmtContext(ctx, s.stmtGetRevision).QueryRowContext(ctx, id, revision), target)
+		if err = getRevisionResult(ctx, id, revision, err); err != nil {
+			return err
+		}
+
+		review := target.review()
+		_, err = tx.StmtContext(ctx, s.stmtUpdate).ExecContext(ctx,
+			review.Title,
+			review.Director,
+			review.ReleaseDate,
+			review.Rating,
+			review.ReviewNotes,
+			updatedAt,
+			review.ID)
+		if err != nil {
+			return err
+		}
+		review.UpdatedAt = updatedAt
+		if changed := changedFields(current, review); len(changed) > 0 {
+			if err := s.insertRevision(ctx, tx, review, changed); err != nil {
+				return err
+			}
+		}
+		after = updatedReview(current, review)
+		if err := s.insertAudit(ctx, tx, AuditRevert, id, current, after); err != nil {
+			return err
+		}
+		return s.insertEvent(ctx, tx, EventReviewUpdated, after)
+	})
+	if err = revertResult(ctx, id, err); err != nil {
+		return nil, err
+	}
+	s.eventWritten()
+	return after, nil
+}
+
+// parseRevision parses a revision number from a URL or query parameter.
+func parseRevision(name, raw string) (int, error) {
+	revision, err := strconv.Atoi(raw)
+	if err != nil || revision < 1 {
+		return 0, fmt.Errorf("invalid %s %q: must be a positive revision number", name, raw)
+	}
+	return revision, nil
+}
+
+// handleListRevisions handles GET /review/{id}/revisions requests.
+// It lists the revisions of a review, newest first.
+//
+// URL Parameters:
+//   - id: The numeric ID of the review
+//
+// Response:
+//   - 200 OK: Returns a JSON array of revisions
+//   - 400 Bad Request: If the ID is invalid or the review is not found
+//   - 499/503/504: If the request is cancelled or times out (see statusForError)
+//
+// Example Request:
+//
+//	GET /review/42/revisions
+func (server *APIServer) handleListRevisions(writer http.ResponseWriter, request *http.Request) error {
+	// Extract and validate the ID from URL path
+	id, err := strconv.Atoi(chi.URLParam(request, "id"))
+	if err != nil {
+		return fmt.Errorf("invalid id: %w", err)
+	}
+
+	revisions, err := server.revisions.ListRevisions(request.Context(), id)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(writer, http.StatusOK, revisions)
+}
+
+// handleGetRevision handles GET /review/{id}/revisions/{revision} requests.
+// It returns one revision of a review.
+//
+// URL Parameters:
+//   - id: The numeric ID of the review
+//   - revision: The revision number
+//
+// Response:
+//   - 200 OK: Returns the revision as JSON
+//   - 400 Bad Request: If a parameter is invalid or the revision is not found
+//   - 499/503/504: If the request is cancelled or times out (see statusForError)
+//
+// Example Request:
+//
+//	GET /review/42/revisions/2
+func (server *APIServer) handleGetRevision(writer http.ResponseWriter, request *http.Request) error {
+	// Extract and validate the ID and revision from URL path
+	id, err := strconv.Atoi(chi.URLParam(request, "id"))
+	if err != nil {
+		return fmt.Errorf("invalid id: %w", err)
+	}
+	number, err := parseRevision("revision", chi.URLParam(request, "revision"))
+	if err != nil {
+		return err
+	}
+
+	revision, err := server.revisions.GetRevision(request.Context(), id, number)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(writer, http.StatusOK, revision)
+}
+
+// handleDiffRevisions handles GET /review/{id}/revisions/diff requests.
+// It lists the fields that differ between two revisions of a review.
+//
+// URL Parameters:
+//   - id: The numeric ID of the review
+//
+// Query Parameters:
+//   - from: The older revision number
+//   - to: The newer revision number
+//
+// Response:
+//   - 200 OK: Returns the differences as JSON
+//   - 400 Bad Request: If a parameter is invalid or a revision is not found
+//   - 499/503/504: If the request is cancelled or times out (see statusForError)
+//
+// Example Request:
+//
+//	GET /review/42/revisions/diff?from=1&to=3
+//
+// Example Response:
+//
+//	{
+//	    "reviewId": 42,
+//	    "from": 1,
+//	    "to": 3,
+//	    "changes": [{"field": "rating", "from": "9/10", "to": "10/10"}]
+//	}
+func (server *APIServer) handleDiffRevisions(writer http.ResponseWriter, request *http.Request) error {
+	// Extract and validate the ID and revisions
+	id, err := strconv.Atoi(chi.URLParam(request, "id"))
+	if err != nil {
+		return fmt.Errorf("invalid id: %w", err)
+	}
+	query := request.URL.Query()
+	fromNumber, err := parseRevision("from", query.Get("from"))
+	if err != nil {
+		return err
+	}
+	toNumber, err := parseRevision("to", query.Get("to"))
+	if err != nil {
+		return err
+	}
+
+	from, err := server.revisions.GetRevision(request.Context(), id, fromNumber)
+	if err != nil {
+		return err
+	}
+	to, err := server.revisions.GetRevision(request.Context(), id, toNumber)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(writer, http.StatusOK, diffRevisions(from, to))
+}
+
+// handleRevertReview handles POST /review/{id}/revisions/{revision}/revert
+// requests. It writes the content of an earlier revision back to the
+// review (see RevisionStore.RevertReview), which records a new revision.
+//
+// URL Parameters:
+//   - id: The numeric ID of the review
+//   - revision: The revision number to revert to
+//
+// Response:
+//   - 200 OK: Returns the reverted review
+//   - 400 Bad Request: If a parameter is invalid or the revision is not found
+//   - 499/503/504: If the request is cancelled or times out (see statusForError)
+//
+// Example Request:
+//
+//	POST /review/42/revisions/1/revert
+func (server *APIServer) handleRevertReview(writer http.ResponseWriter, request *http.Request) error {
+	// Extract and validate the ID and revision from URL path
+	id, err := strconv.Atoi(chi.URLParam(request, "id"))
+	if err != nil {
+		return fmt.Errorf("invalid id: %w", err)
+	}
+	number, err := parseRevision("revision", chi.URLParam(request, "revision"))
+	if err != nil {
+		return err
+	}
+
+	review, err := server.revisions.RevertReview(request.Context(), id, number)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(writer, http.StatusOK, review)
+}
@@ -0,0 +1,129 @@
+// Package reviewapi provides secret handling for the Movie Review API.
+// This file defines string types for credentials that never reveal their
+// value when printed, logged or marshaled, and the loading of secrets from
+// files mounted by Docker or Kubernetes.
+package reviewapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// redactedValue replaces secrets when values are printed or marshaled.
+const redactedValue = "[REDACTED]"
+
+// Secret is a string that hides its value from fmt, logs, JSON and YAML.
+// Use Value to obtain the underlying string where it is actually needed.
+//
+// Example:
+//
+//	password := Secret("hunter2")
+//	fmt.Println(password)  // prints [REDACTED]
+//	password.Value()       // "hunter2"
+type Secret string
+
+// Value returns the secret in clear text.
+func (s Secret) Value() string {
+	return string(s)
+}
+
+// String implements fmt.Stringer and always returns a placeholder.
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redactedValue
+}
+
+// GoString implements fmt.GoStringer so %#v does not leak the value.
+func (s Secret) GoString() string {
+	return fmt.Sprintf("%q", s.String())
+}
+
+// MarshalJSON implements json.Marshaler with the redacted placeholder.
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// MarshalYAML implements yaml.Marshaler with the redacted placeholder.
+func (s Secret) MarshalYAML() (any, error) {
+	return s.String(), nil
+}
+
+// DSN is a database connection string (key=value or postgres:// URL) whose
+// password is hidden when printed, logged or marshaled.
+type DSN string
+
+// Value returns the connection string in clear text.
+func (d DSN) Value() string {
+	return string(d)
+}
+
+// String implements fmt.Stringer with the password redacted.
+func (d DSN) String() string {
+	return redactDSN(string(d))
+}
+
+// GoString implements fmt.GoStringer so %#v does not leak the password.
+func (d DSN) GoString() string {
+	return fmt.Sprintf("%q", d.String())
+}
+
+// MarshalJSON implements json.Marshaler with the password redacted.
+func (d DSN) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// MarshalYAML implements yaml.Marshaler with the password redacted.
+func (d DSN) MarshalYAML() (any, error) {
+	return d.String(), nil
+}
+
+// dsnPasswordPattern matches the password in a key=value connection string,
+// including quoted values with escaped quotes.
+var dsnPasswordPattern = regexp.MustCompile(`password\s*=\s*('(?:[^'\\]|\\.)*'|\S+)`)
+
+// redactDSN hides the password in a postgres:// URL or key=value DSN.
+func redactDSN(dsn string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		parsed, err := url.Parse(dsn)
+		if err != nil {
+			return redactedValue
+		}
+		return parsed.Redacted()
+	}
+	return dsnPasswordPattern.ReplaceAllString(dsn, "password="+redactedValue)
+}
+
+// lookupEnvOrFile returns the value of the environment variable key, or the
+// contents of the file named by key+"_FILE" (the convention used for Docker
+// and Kubernetes secrets). A single trailing newline is trimmed from files.
+//
+// Parameters:
+//   - key: The environment variable name, e.g. "DB_PASSWORD"
+//
+// Returns:
+//   - string: The value found
+//   - bool: Whether either variable was set
+//   - error: Non-nil if both variants are set or the file cannot be read
+func lookupEnvOrFile(key string) (string, bool, error) {
+	value, hasValue := os.LookupEnv(key)
+	path, hasFile := os.LookupEnv(key + "_FILE")
+
+	switch {
+	case hasValue && hasFile:
+		return "", false, fmt.Errorf("both %s and %s_FILE are set", key, key)
+	case hasFile:
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", false, fmt.Errorf("read %s_FILE: %w", key, err)
+		}
+		return strings.TrimSuffix(strings.TrimSuffix(string(content), "\n"), "\r"), true, nil
+	default:
+		return value, hasValue, nil
+	}
+}
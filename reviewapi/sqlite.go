@@ -0,0 +1,776 @@
+// Package reviewapi provides the SQLite storage backend for the Movie Review API.
+// This file implements the Storage interface on an embedded SQLite database
+// through a pure-Go driver, for single-node deployments and local development
+// without a PostgreSQL server.
+//
+// SqliteDb mirrors PgDb: the same schema (from migrations/sqlite), the same
+// error messages, per-operation time budgets, readiness reporting and
+// startup lifecycle (NewSqliteDb, then Connect). Differences:
+//   - Writes are serialized by SQLite; concurrent writers wait up to the busy
+//     timeout instead of failing
+//   - There are no read replicas and no consistency tokens
+//   - UpdatedAt is set by the application rather than the database clock
+package reviewapi
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	// SQLite driver (pure Go, no cgo), registered as "sqlite"
+	_ "modernc.org/sqlite"
+)
+
+// sqliteMemoryPath opens a throwaway in-memory database.
+const sqliteMemoryPath = ":memory:"
+
+// SqliteDb implements the Storage interface using SQLite.
+//
+// A SqliteDb created by NewSqliteDb rejects operations with ErrNotReady until
+// Connect has opened the database, migrated the schema and prepared the
+// statements.
+type SqliteDb struct {
+	// db is the connection pool to the database file.
+	db *sql.DB
+
+	// timeouts holds the resolved time budget of each database operation.
+	timeouts OperationTimeouts
+
+	// connectRetry controls the retries of the initial connection.
+	connectRetry BackoffConfig
+
+	// autoMigrate applies pending migrations in Connect.
+	autoMigrate bool
+
+	// schemaVersion is the latest embedded migration, which CheckReady
+	// expects the database to be at.
+	schemaVersion int
+
+	// logger records every storage operation with its duration and outcome.
+	logger *slog.Logger
+
+	// ready is set once Connect has completed; the statements below must
+	// not be used before then.
+	ready atomic.Bool
+
+	// stmtCreate is the prepared statement for INSERT operations.
+	stmtCreate *sql.Stmt
+
+	// stmtUpdate is the prepared statement for UPDATE operations.
+	stmtUpdate *sql.Stmt
+
+	// stmtDelete is the prepared statement for DELETE operations.
+	stmtDelete *sql.Stmt
+
+	// stmtGetById is the prepared statement for SELECT by ID operations.
+	stmtGetById *sql.Stmt
+
+	// stmtListDeleted, stmtRestore and stmtPurge serve the trash (trash.go).
+	stmtListDeleted *sql.Stmt
+	stmtRestore     *sql.Stmt
+	stmtPurge       *sql.Stmt
+
+	// stmtLockById reads a review, in the trash or not, for a write that
+	// records its previous version.
+	stmtLockById *sql.Stmt
+
+	// stmtInsertRevision, stmtListRevisions and stmtGetRevision serve the
+	// revision history (revisions.go).
+	stmtInsertRevision *sql.Stmt
+	stmtListRevisions  *sql.Stmt
+	stmtGetRevision    *sql.Stmt
+
+	// stmtInsertAudit and stmtListAudit serve the audit log (audit.go).
+	stmtInsertAudit *sql.Stmt
+	stmtListAudit   *sql.Stmt
+
+	// stmtInsertEvent and the delivery statements serve the transactional
+	// outbox (outbox.go).
+	stmtInsertEvent        *sql.Stmt
+	stmtFanOutEvents       *sql.Stmt
+	stmtMarkFannedOut      *sql.Stmt
+	stmtClaimDeliveries    *sql.Stmt
+	stmtLeaseDelivery      *sql.Stmt
+	stmtCompleteDelivery   *sql.Stmt
+	stmtPruneOutbox        *sql.Stmt
+	stmtListDeadDeliveries *sql.Stmt
+	stmtRetryDelivery      *sql.Stmt
+	stmtGetDelivery        *sql.Stmt
+
+	// stmtListEvents, stmtLatestEvent and stmtOldestEvent serve the event
+	// stream (events.go).
+	stmtListEvents  *sql.Stmt
+	stmtLatestEvent *sql.Stmt
+	stmtOldestEvent *sql.Stmt
+
+	// eventsWritten wakes the event feed after a change wrote an event.
+	eventsWritten chan struct{}
+}
+
+// sqliteDSN builds the driver DSN for the database file at path.
+//
+// Every connection enables a busy timeout (so writers queue instead of
+// failing with SQLITE_BUSY), write-ahead logging (so reads do not block on
+// writes) and foreign keys, and starts transactions with BEGIN IMMEDIATE so
+// that they take the write lock up front instead of failing on upgrade.
+func sqliteDSN(path string) string {
+	query := url.Values{}
+	query.Add("_pragma", "busy_timeout(5000)")
+	query.Add("_pragma", "foreign_keys(1)")
+	if path != sqliteMemoryPath {
+		query.Add("_pragma", "journal_mode(WAL)")
+	}
+	query.Set("_txlock", "immediate")
+	return "file:" + path + "?" + query.Encode()
+}
+
+// NewSqliteDb opens a SQLite database client without connecting it.
+//
+// The function performs the following steps:
+//  1. Validates the settings
+//  2. Opens the connection pool (the file is not opened yet)
+//  3. Configures the pool; an in-memory database is limited to a single
+//     connection, since each connection would get its own empty database
+//
+// Parameters:
+//   - cfg: The database settings (Driver "sqlite" and Path)
+//   - logger: Structured logger used for storage operation logs
+//   - metrics: Metrics registry for connection pool statistics (may be nil)
+//
+// Returns:
+//   - *SqliteDb: Database client waiting to be connected
+//   - error: Non-nil if the settings are invalid
+func NewSqliteDb(cfg DBConfig, logger *slog.Logger, metrics *Metrics) (*SqliteDb, error) {
+	// Reject invalid settings before touching the file system
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid database configuration: %w", err)
+	}
+
+	// The embedded migrations do not change while running
+	schemaVersion, err := latestMigrationVersion(sqliteMigrations, "migrations/sqlite")
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite", sqliteDSN(cfg.Path))
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite database: %w", err)
+	}
+
+	// Configure the pool; connections must not be recycled for :memory:
+	if cfg.Path == sqliteMemoryPath {
+		db.SetMaxOpenConns(1)
+		db.SetMaxIdleConns(1)
+		db.SetConnMaxLifetime(0)
+	} else {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+		db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+
+	// Expose pool statistics (open, in-use, idle, waits) as gauges
+	if err := metrics.RegisterDBStats("sqlite", db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("register pool metrics: %w", err)
+	}
+
+	return &SqliteDb{
+		db:            db,
+		timeouts:      cfg.Timeouts.withDefault(cfg.QueryTimeout),
+		connectRetry:  cfg.ConnectRetry,
+		autoMigrate:   cfg.AutoMigrate,
+		schemaVersion: schemaVersion,
+		logger:        logger.With("component", "storage"),
+		eventsWritten: make(chan struct{}, 1),
+	}, nil
+}
+
+// Connect opens the database and makes the client ready for use.
+//
+// The function performs the following steps:
+//  1. Pings the database, retrying while it cannot be opened (e.g. while a
+//     volume is still being mounted)
+//  2. Applies pending schema migrations (when DBConfig.AutoMigrate is set)
+//  3. Prepares SQL statements for CRUD operations
+//
+// Parameters:
+//   - ctx: Context that aborts connecting (e.g. on shutdown)
+//
+// Returns:
+//   - error: Non-nil if the database could not be opened for
+//     ConnectRetry.MaxElapsed, ctx was cancelled, or a later step failed
+func (s *SqliteDb) Connect(ctx context.Context) error {
+	onRetry := func(attempt int, err error, next time.Duration) {
+		s.logger.Warn("database unavailable, retrying", "attempt", attempt, "retry_in", next, "error", err)
+	}
+	if err := s.connectRetry.Retry(ctx, onRetry, s.db.PingContext); err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+
+	if s.autoMigrate {
+		if err := s.Migrate(ctx); err != nil {
+			return fmt.Errorf("migrate schema: %w", err)
+		}
+	}
+
+	if err := s.prepareStatements(ctx); err != nil {
+		return fmt.Errorf("prepare statements: %w", err)
+	}
+
+	s.ready.Store(true)
+	return nil
+}
+
+// prepareStatements creates prepared statements for all CRUD operations.
+// This is called automatically by Connect and should not be called directly.
+func (s *SqliteDb) prepareStatements(ctx context.Context) error {
+	var err error
+
+	// Prepare INSERT statement for creating new reviews
+	s.stmtCreate, err = s.db.PrepareContext(ctx, `INSERT INTO reviews (
+		title,director,releaseDate,rating,reviewNotes,dateCreated,updatedAt
+	) VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("prepare create: %w", err)
+	}
+
+	// Prepare UPDATE statement for modifying existing reviews
+	s.stmtUpdate, err = s.db.PrepareContext(ctx, `UPDATE reviews
+		SET title=?, director=?, releaseDate=?, rating=?, reviewNotes=?, updatedAt=?
+		WHERE id=? AND deletedAt IS NULL`)
+	if err != nil {
+		return fmt.Errorf("prepare update: %w", err)
+	}
+
+	// Prepare the soft DELETE statement moving reviews to the trash
+	s.stmtDelete, err = s.db.PrepareContext(ctx, `UPDATE reviews
+		SET deletedAt=?, updatedAt=?
+		WHERE id=? AND deletedAt IS NULL
+		RETURNING `+reviewColumns)
+	if err != nil {
+		return fmt.Errorf("prepare delete: %w", err)
+	}
+
+	// Prepare SELECT statement for fetching reviews by ID
+	s.stmtGetById, err = s.db.PrepareContext(ctx, `SELECT `+reviewColumns+`
+		FROM reviews WHERE id=? AND deletedAt IS NULL`)
+	if err != nil {
+		return fmt.Errorf("prepare getById: %w", err)
+	}
+
+	// Prepare the trash statements
+	s.stmtListDeleted, err = s.db.PrepareContext(ctx, `SELECT `+reviewColumns+`
+		FROM reviews WHERE deletedAt IS NOT NULL
+		ORDER BY deletedAt DESC, id DESC LIMIT ?`)
+	if err != nil {
+		return fmt.Errorf("prepare listDeleted: %w", err)
+	}
+	s.stmtRestore, err = s.db.PrepareContext(ctx, `UPDATE reviews
+		SET deletedAt=NULL, updatedAt=?
+		WHERE id=? AND deletedAt IS NOT NULL
+		RETURNING `+reviewColumns)
+	if err != nil {
+		return fmt.Errorf("prepare restore: %w", err)
+	}
+	s.stmtPurge, err = s.db.PrepareContext(ctx, `DELETE FROM reviews
+		WHERE deletedAt IS NOT NULL AND deletedAt < ?
+		RETURNING `+reviewColumns)
+	if err != nil {
+		return fmt.Errorf("prepare purge: %w", err)
+	}
+
+	// Prepare the statement reading a review before a write that records
+	// its previous version
+	s.stmtLockById, err = s.db.PrepareContext(ctx, `SELECT `+reviewColumns+`
+		FROM reviews WHERE id=?`)
+	if err != nil {
+		return fmt.Errorf("prepare lockById: %w", err)
+	}
+
+	// Prepare the revision history statements. Writes run in IMMEDIATE
+	// transactions, which serializes the numbering of revisions.
+	s.stmtInsertRevision, err = s.db.PrepareContext(ctx, `INSERT INTO review_revisions (
+		reviewId, revision, author, createdAt, changedFields,
+		title, director, releaseDate, rating, reviewNotes
+	) SELECT ?1, COALESCE(max(revision), 0) + 1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9
+	FROM review_revisions WHERE reviewId=?1`)
+	if err != nil {
+		return fmt.Errorf("prepare insertRevision: %w", err)
+	}
+	s.stmtListRevisions, err = s.db.PrepareContext(ctx, `SELECT `+revisionColumns+`
+		FROM review_revisions v JOIN reviews r ON r.id = v.reviewId
+		WHERE v.reviewId=? AND r.deletedAt IS NULL
+		ORDER BY v.revision DESC`)
+	if err != nil {
+		return fmt.Errorf("prepare listRevisions: %w", err)
+	}
+	s.stmtGetRevision, err = s.db.PrepareContext(ctx, `SELECT `+revisionColumns+`
+		FROM review_revisions v JOIN reviews r ON r.id = v.reviewId
+		WHERE v.reviewId=? AND v.revision=? AND r.deletedAt IS NULL`)
+	if err != nil {
+		return fmt.Errorf("prepare getRevision: %w", err)
+	}
+
+	// Prepare the audit log statements
+	s.stmtInsertAudit, err = s.db.PrepareContext(ctx, `INSERT INTO audit_log (
+		occurredAt, actor, action, reviewId, before, after, requestId, clientIp, claimedActor
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("prepare insertAudit: %w", err)
+	}
+	s.stmtListAudit, err = s.db.PrepareContext(ctx, `SELECT `+auditColumns+`
+		FROM audit_log
+		WHERE (?1 = '' OR actor = ?1) AND (?2 = '' OR action = ?2)
+			AND (?3 IS NULL OR occurredAt >= ?3)
+			AND (?4 IS NULL OR occurredAt < ?4)
+			AND (?5 = 0 OR id < ?5)
+		ORDER BY id DESC LIMIT ?6`)
+	if err != nil {
+		return fmt.Errorf("prepare listAudit: %w", err)
+	}
+
+	// Prepare the outbox statements. Claims run in IMMEDIATE transactions,
+	// so fanning out and marking select the same events.
+	s.stmtInsertEvent, err = s.db.PrepareContext(ctx, `INSERT INTO outbox_events (
+		eventType, reviewId, payload, createdAt
+	) VALUES (?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("prepare insertEvent: %w", err)
+	}
+	s.stmtFanOutEvents, err = s.db.PrepareContext(ctx, `INSERT OR IGNORE INTO webhook_deliveries (
+		eventId, webhook, nextAttemptAt, updatedAt
+	) SELECT e.id, w.value, ?3, ?3
+	FROM (SELECT id FROM outbox_events WHERE fannedOut = 0 ORDER BY id LIMIT ?2) e, json_each(?1) w`)
+	if err != nil {
+		return fmt.Errorf("prepare fanOutEvents: %w", err)
+	}
+	s.stmtMarkFannedOut, err = s.db.PrepareContext(ctx, `UPDATE outbox_events SET fannedOut = 1
+		WHERE id IN (SELECT id FROM outbox_events WHERE fannedOut = 0 ORDER BY id LIMIT ?)`)
+	if err != nil {
+		return fmt.Errorf("prepare markFannedOut: %w", err)
+	}
+	s.stmtClaimDeliveries, err = s.db.PrepareContext(ctx, `SELECT `+deliveryColumns+`
+		FROM webhook_deliveries d JOIN outbox_events e ON e.id = d.eventId
+		WHERE d.status = 'pending' AND d.nextAttemptAt <= ?
+		ORDER BY d.nextAttemptAt, d.id LIMIT ?`)
+	if err != nil {
+		return fmt.Errorf("prepare claimDeliveries: %w", err)
+	}
+	s.stmtLeaseDelivery, err = s.db.PrepareContext(ctx, `UPDATE webhook_deliveries
+		SET nextAttemptAt = ?, updatedAt = ? WHERE id = ?`)
+	if err != nil {
+		return fmt.Errorf("prepare leaseDelivery: %w", err)
+	}
+	s.stmtCompleteDelivery, err = s.db.PrepareContext(ctx, `UPDATE webhook_deliveries
+		SET status = ?, attempts = attempts + 1, nextAttemptAt = ?, lastError = ?, updatedAt = ?
+		WHERE id = ?`)
+	if err != nil {
+		return fmt.Errorf("prepare completeDelivery: %w", err)
+	}
+	s.stmtPruneOutbox, err = s.db.PrepareContext(ctx, `DELETE FROM outbox_events
+		WHERE fannedOut = 1 AND createdAt < ? AND NOT EXISTS (
+			SELECT 1 FROM webhook_deliveries d WHERE d.eventId = outbox_events.id AND d.status <> 'delivered'
+		)`)
+	if err != nil {
+		return fmt.Errorf("prepare pruneOutbox: %w", err)
+	}
+	s.stmtListDeadDeliveries, err = s.db.PrepareContext(ctx, `SELECT `+deliveryColumns+`
+		FROM webhook_deliveries d JOIN outbox_events e ON e.id = d.eventId
+		WHERE d.status = 'dead'
+		ORDER BY d.id DESC LIMIT ?`)
+	if err != nil {
+		return fmt.Errorf("prepare listDeadDeliveries: %w", err)
+	}
+	s.stmtRetryDelivery, err = s.db.PrepareContext(ctx, `UPDATE webhook_deliveries
+		SET status = 'pending', attempts = 0, nextAttemptAt = ?1, updatedAt = ?1
+		WHERE id = ?2 AND status = 'dead'
+		RETURNING id`)
+	if err != nil {
+		return fmt.Errorf("prepare retryDelivery: %w", err)
+	}
+	s.stmtGetDelivery, err = s.db.PrepareContext(ctx, `SELECT `+deliveryColumns+`
+		FROM webhook_deliveries d JOIN outbox_events e ON e.id = d.eventId
+		WHERE d.id = ?`)
+	if err != nil {
+		return fmt.Errorf("prepare getDelivery: %w", err)
+	}
+	s.stmtListEvents, err = s.db.PrepareContext(ctx, `SELECT `+eventColumns+`
+		FROM outbox_events WHERE id > ? ORDER BY id LIMIT ?`)
+	if err != nil {
+		return fmt.Errorf("prepare listEvents: %w", err)
+	}
+	s.stmtLatestEvent, err = s.db.PrepareContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM outbox_events`)
+	if err != nil {
+		return fmt.Errorf("prepare latestEvent: %w", err)
+	}
+	// An empty outbox keeps nothing before the next AUTOINCREMENT ID
+	s.stmtOldestEvent, err = s.db.PrepareContext(ctx, `SELECT COALESCE(MIN(id), (
+		SELECT seq + 1 FROM sqlite_sequence WHERE name = 'outbox_events'
+	), 1) FROM outbox_events`)
+	if err != nil {
+		return fmt.Errorf("prepare oldestEvent: %w", err)
+	}
+
+	return nil
+}
+
+// Migrate applies every embedded SQLite migration newer than the database's
+// current schema version. SQLite allows a single writer, so BEGIN IMMEDIATE
+// transactions serialize concurrent runs without an advisory lock.
+//
+// Parameters:
+//   - ctx: Context for cancellation of the migration run
+//
+// Returns:
+//   - error: Non-nil if any migration fails; earlier migrations stay applied
+func (s *SqliteDb) Migrate(ctx context.Context) error {
+	migrations, err := loadMigrations(sqliteMigrations, "migrations/sqlite")
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	for _, m := range migrations {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("begin migration %s: %w", m.name, err)
+		}
+
+		// Re-read the version under the write lock: another process may
+		// have applied this migration meanwhile
+		var applied bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = ?)`, m.version).Scan(&applied); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("read schema version: %w", err)
+		}
+		if applied {
+			tx.Rollback()
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, m.sql); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("apply migration %s: %w", m.name, err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, m.version, m.name); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("record migration %s: %w", m.name, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit migration %s: %w", m.name, err)
+		}
+		s.logger.InfoContext(ctx, "applied migration", "version", m.version, "name", m.name)
+	}
+	return nil
+}
+
+// SchemaVersion returns the highest applied migration version, or 0 when no
+// migration has been applied yet.
+func (s *SqliteDb) SchemaVersion(ctx context.Context) (int, error) {
+	var exists bool
+	if err := s.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations')`).Scan(&exists); err != nil {
+		return 0, fmt.Errorf("check schema_migrations: %w", err)
+	}
+	if !exists {
+		return 0, nil
+	}
+
+	var version int
+	if err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// CheckReady verifies that Connect has completed, that the database can be
+// queried and that the schema is at the version this build expects.
+func (s *SqliteDb) CheckReady(ctx context.Context) error {
+	if !s.ready.Load() {
+		return fmt.Errorf("database connecting: %w", ErrNotReady)
+	}
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("database unavailable: %w", err)
+	}
+
+	current, err := s.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if current < s.schemaVersion {
+		return fmt.Errorf("schema version %d behind expected %d", current, s.schemaVersion)
+	}
+	return nil
+}
+
+// Close releases the prepared statements and the connection pool.
+//
+// Returns:
+//   - error: Non-nil if closing the database fails
+func (s *SqliteDb) Close() error {
+	for _, stmt := range []*sql.Stmt{s.stmtCreate, s.stmtUpdate, s.stmtDelete, s.stmtGetById,
+		s.stmtListDeleted, s.stmtRestore, s.stmtPurge,
+		s.stmtLockById, s.stmtInsertRevision, s.stmtListRevisions, s.stmtGetRevision,
+		s.stmtInsertAudit, s.stmtListAudit,
+		s.stmtInsertEvent, s.stmtFanOutEvents, s.stmtMarkFannedOut, s.stmtClaimDeliveries, s.stmtLeaseDelivery,
+		s.stmtCompleteDelivery, s.stmtPruneOutbox, s.stmtListDeadDeliveries, s.stmtRetryDelivery, s.stmtGetDelivery,
+		s.stmtListEvents, s.stmtLatestEvent, s.stmtOldestEvent} {
+		if stmt != nil {
+			stmt.Close()
+		}
+	}
+	return s.db.Close()
+}
+
+// beginOperation starts tracing and timing a storage operation; see
+// PgDb.beginOperation.
+func (s *SqliteDb) beginOperation(ctx context.Context, operation, sqlOperation string, id int) (context.Context, func(*error)) {
+	return beginStorageOperation(ctx, s.logger, "SqliteDb", "sqlite", operation, sqlOperation, id)
+}
+
+// lockReview reads the review with the given ID within tx, whether it is in
+// the trash or not; the transaction already holds the write lock. It
+// returns sql.ErrNoRows if there is none.
+func (s *SqliteDb) lockReview(ctx context.Context, tx *sql.Tx, id int) (*Review, error) {
+	review := &Review{}
+	err := scanReview(tx.StmtContext(ctx, s.stmtLockById).QueryRowContext(ctx, id), review)
+	return review, err
+}
+
+// inTx runs fn in a transaction, committing it if fn succeeds and rolling it
+// back otherwise. Transactions take the write lock when they begin
+// (_txlock=immediate), so concurrent writers wait instead of deadlocking.
+func (s *SqliteDb) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// CreateReview inserts a new review into the database together with its
+// first revision, audit entry and outbox event, storing the generated ID and
+// UpdatedAt back into review.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout control
+//   - review: The review data to persist (ID field is ignored)
+//
+// Returns:
+//   - string: Success message including the creation timestamp
+//   - error: Non-nil if the insert fails; wraps ErrCanceled or ErrTimeout
+//     when the context is done
+func (s *SqliteDb) CreateReview(ctx context.Context, review *Review) (_ string, err error) {
+	ctx, finish := s.beginOperation(ctx, "create", "INSERT", review.ID)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !s.ready.Load() {
+		return "", ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Create)
+	defer cancel()
+
+	updatedAt := time.Now().UTC()
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.StmtContext(ctx, s.stmtCreate).ExecContext(ctx,
+			review.Title,
+			review.Director,
+			review.ReleaseDate,
+			review.Rating,
+			review.ReviewNotes,
+			review.DateCreated,
+			updatedAt)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		review.ID = int(id)
+		review.UpdatedAt = updatedAt
+		if err := s.insertRevision(ctx, tx, review, ReviewFields); err != nil {
+			return err
+		}
+		if err := s.insertAudit(ctx, tx, AuditCreate, review.ID, nil, review); err != nil {
+			return err
+		}
+		return s.insertEvent(ctx, tx, EventReviewCreated, review)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create review: %w", contextError(ctx, err))
+	}
+	s.eventWritten()
+
+	success := "Review Created :: Recorded In DB:: " + review.DateCreated
+	return success, nil
+}
+
+// UpdateReview modifies an existing review, identified by its ID field, and
+// stores the new modification time in review.UpdatedAt.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout control
+//   - review: The review data with updated values (ID must be set)
+//
+// Returns:
+//   - error: Non-nil if the update fails or no review exists with the given ID
+//
+// The change is audited and written to the outbox and, when a field
+// changes, a revision is recorded, in the same transaction.
+func (s *SqliteDb) UpdateReview(ctx context.Context, review *Review) (err error) {
+	ctx, finish := s.beginOperation(ctx, "update", "UPDATE", review.ID)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !s.ready.Load() {
+		return ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Update)
+	defer cancel()
+
+	updatedAt := time.Now().UTC()
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		// Read the current version; reviews in the trash are not found
+		current, err := s.lockReview(ctx, tx, review.ID)
+		if err != nil {
+			return err
+		}
+		if current.DeletedAt != nil {
+			return sql.ErrNoRows
+		}
+
+		_, err = tx.StmtContext(ctx, s.stmtUpdate).ExecContext(ctx,
+			review.Title,
+			review.Director,
+			review.ReleaseDate,
+			review.Rating,
+			review.ReviewNotes,
+			updatedAt,
+			review.ID)
+		if err != nil {
+			return err
+		}
+		review.UpdatedAt = updatedAt
+		if changed := changedFields(current, review); len(changed) > 0 {
+			if err := s.insertRevision(ctx, tx, review, changed); err != nil {
+				return err
+			}
+		}
+		after := updatedReview(current, review)
+		if err := s.insertAudit(ctx, tx, AuditUpdate, review.ID, current, after); err != nil {
+			return err
+		}
+		return s.insertEvent(ctx, tx, EventReviewUpdated, after)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("review with id %d %w", review.ID, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update review: %w", contextError(ctx, err))
+	}
+	s.eventWritten()
+	return nil
+}
+
+// DeleteReview moves a review to the trash by its ID (see trash.go), audits
+// it and writes it to the outbox.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout control
+//   - id: The unique identifier of the review to delete
+//
+// Returns:
+//   - error: Non-nil if the deletion fails or no review exists with the given ID
+func (s *SqliteDb) DeleteReview(ctx context.Context, id int) (err error) {
+	ctx, finish := s.beginOperation(ctx, "delete", "DELETE", id)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !s.ready.Load() {
+		return ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Delete)
+	defer cancel()
+
+	now := time.Now().UTC()
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		// Read the current version; reviews already in the trash are not found
+		current, err := s.lockReview(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if current.DeletedAt != nil {
+			return sql.ErrNoRows
+		}
+
+		deleted := &Review{}
+		if err := scanReview(tx.StmtContext(ctx, s.stmtDelete).QueryRowContext(ctx, now, now, id), deleted); err != nil {
+			return err
+		}
+		if err := s.insertAudit(ctx, tx, AuditDelete, id, current, deleted); err != nil {
+			return err
+		}
+		return s.insertEvent(ctx, tx, EventReviewDeleted, deleted)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("review with id %d %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete review: %w", contextError(ctx, err))
+	}
+	s.eventWritten()
+	s.logger.InfoContext(ctx, "moved review to trash", "review_id", id)
+	return nil
+}
+
+// GetReviewById retrieves a single review from the database by its ID.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout control
+//   - id: The unique identifier of the review to retrieve
+//
+// Returns:
+//   - *Review: The retrieved review data
+//   - error: Non-nil if the query fails; wraps ErrNotFound if no review
+//     exists with the given ID
+func (s *SqliteDb) GetReviewById(ctx context.Context, id int) (_ *Review, err error) {
+	ctx, finish := s.beginOperation(ctx, "get", "SELECT", id)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !s.ready.Load() {
+		return nil, ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Get)
+	defer cancel()
+
+	review := &Review{}
+	err = scanReview(s.stmtGetById.QueryRowContext(ctx, id), review)
+	return getResult(ctx, id, review, err)
+}
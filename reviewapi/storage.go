@@ -0,0 +1,1308 @@
+// Package reviewapi provides the storage layer for the Movie Review API.
+// This file implements the database access layer using PostgreSQL with
+// connection pooling, prepared statements, and context-based timeouts.
+//
+// The storage layer follows the Repository pattern, abstracting database
+// operations behind the Storage interface for testability and flexibility.
+package reviewapi
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	// PostgreSQL driver - used directly to build connectors from DSNs
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// storageTracer creates the child spans of storage operations. It resolves
+// the global tracer provider lazily, so spans are exported once tracing is
+// set up and are no-ops otherwise.
+var storageTracer = otel.Tracer(instrumentationName + "/storage")
+
+// Storage defines the interface for review persistence operations.
+// This interface allows for easy mocking in tests and potential
+// swapping of storage backends (e.g., switching from PostgreSQL to MySQL).
+//
+// All methods accept a context.Context for cancellation and timeout support.
+// When the context is cancelled or an operation exceeds its time budget, the
+// returned error wraps ErrCanceled or ErrTimeout respectively. Before the
+// backend has finished connecting, methods return ErrNotReady. Operations on
+// a review that does not exist return an error wrapping ErrNotFound.
+//
+// Storage only covers the reviews themselves. The trash, revisions, audit
+// log, webhook dead letters and event log are separate interfaces
+// (TrashStore, RevisionStore, AuditStore, WebhookAdmin and EventLog), so
+// that decorators and test fakes implement only what they need; the
+// server receives them through ServerOptions.
+//
+// storagetest.RunStorageConformance (package reviewapi/storagetest)
+// verifies these semantics for every backend, and for any other
+// implementation whose tests call it.
+type Storage interface {
+	// CreateReview persists a new review to the database.
+	// Returns a success message with the creation timestamp, or an error.
+	CreateReview(context.Context, *Review) (string, error)
+
+	// UpdateReview modifies an existing review identified by the Review.ID field.
+	// Returns an error if the review doesn't exist or the update fails.
+	UpdateReview(context.Context, *Review) error
+
+	// DeleteReview moves a review to the trash by its ID (soft delete).
+	// Returns an error if the review doesn't exist or the deletion fails.
+	DeleteReview(context.Context, int) error
+
+	// GetReviewById retrieves a single review by its unique identifier.
+	// Returns the Review and nil error on success, or nil and an error if not
+	// found. Reviews in the trash are not found.
+	GetReviewById(context.Context, int) (*Review, error)
+}
+
+// Backend is a Storage implementation that owns a database connection and
+// implements every optional store. Backends are created unconnected
+// (reporting ErrNotReady) so the server can start first; Connect makes them
+// ready and Close releases them.
+type Backend interface {
+	Storage
+	TrashStore
+	RevisionStore
+	AuditStore
+	WebhookAdmin
+	EventLog
+	ReadinessChecker
+	WebhookOutbox
+	ReviewEventSource
+
+	// Connect waits for the database, migrates the schema and prepares
+	// statements. It returns early when ctx is cancelled.
+	Connect(ctx context.Context) error
+
+	// Close releases the statements and connections.
+	Close() error
+}
+
+// NewBackend creates the Storage backend selected by cfg.Driver, without
+// connecting it.
+//
+// Parameters:
+//   - cfg: The database settings
+//   - logger: Structured logger used for storage operation logs
+//   - metrics: Metrics registry for connection pool statistics (may be nil)
+//
+// Returns:
+//   - Backend: PgDb for "postgres", SqliteDb for "sqlite"
+//   - error: Non-nil if the settings are invalid
+func NewBackend(cfg DBConfig, logger *slog.Logger, metrics *Metrics) (Backend, error) {
+	if cfg.Driver == driverSQLite {
+		return NewSqliteDb(cfg, logger, metrics)
+	}
+	return NewPgDb(cfg, logger, metrics)
+}
+
+// PgDb implements the Storage interface using PostgreSQL.
+// It maintains a connection pool and prepared statements for optimal performance.
+//
+// Prepared statements are created once during initialization and reused for all
+// subsequent queries, reducing parsing overhead and improving throughput. A
+// statement invalidated by the server (after a failover or schema change) is
+// prepared again on first failure; see prepared.go.
+//
+// A PgDb created by NewPgDb rejects operations with ErrNotReady until Connect
+// has reached the database, migrated the schema and prepared the statements.
+type PgDb struct {
+	// db is the underlying database connection pool managed by database/sql.
+	// It connects to the primary, which serves every write.
+	db *sql.DB
+
+	// replicas serve reads when healthy and caught up (see replica.go).
+	replicas []*replica
+
+	// replicaCheckInterval is the time between replica health checks.
+	replicaCheckInterval time.Duration
+
+	// nextReplica rotates reads across replicas.
+	nextReplica atomic.Uint64
+
+	// stopReplicaChecks ends the health check loop (nil when not running).
+	stopReplicaChecks func()
+
+	// timeouts holds the resolved time budget of each database operation.
+	timeouts OperationTimeouts
+
+	// connectRetry controls the retries of the initial connection.
+	connectRetry BackoffConfig
+
+	// autoMigrate applies pending migrations in Connect.
+	autoMigrate bool
+
+	// schemaVersion is the latest embedded migration, which CheckReady
+	// expects the database to be at.
+	schemaVersion int
+
+	// logger records every storage operation with its duration and outcome.
+	logger *slog.Logger
+
+	// ready is set once Connect has completed; the statements below must
+	// not be used before then.
+	ready atomic.Bool
+
+	// metrics counts statement re-preparations and retries (may be nil).
+	metrics *Metrics
+
+	// stmtCreate is the prepared statement for INSERT operations.
+	stmtCreate *preparedStmt
+
+	// stmtUpdate is the prepared statement for UPDATE operations.
+	stmtUpdate *preparedStmt
+
+	// stmtDelete is the prepared statement for DELETE operations.
+	stmtDelete *preparedStmt
+
+	// stmtGetById is the prepared statement for SELECT by ID operations.
+	stmtGetById *preparedStmt
+
+	// stmtListDeleted, stmtRestore and stmtPurge serve the trash (trash.go).
+	stmtListDeleted *preparedStmt
+	stmtRestore     *preparedStmt
+	stmtPurge       *preparedStmt
+
+	// stmtLockById locks a review, in the trash or not, for a write that
+	// records its previous version.
+	stmtLockById *preparedStmt
+
+	// stmtInsertRevision, stmtListRevisions and stmtGetRevision serve the
+	// revision history (revisions.go).
+	stmtInsertRevision *preparedStmt
+	stmtListRevisions  *preparedStmt
+	stmtGetRevision    *preparedStmt
+
+	// stmtInsertAudit and stmtListAudit serve the audit log (audit.go).
+	stmtInsertAudit *preparedStmt
+	stmtListAudit   *preparedStmt
+
+	// stmtInsertEvent and the delivery statements serve the transactional
+	// outbox (outbox.go).
+	stmtInsertEvent        *preparedStmt
+	stmtFanOutEvents       *preparedStmt
+	stmtClaimDeliveries    *preparedStmt
+	stmtLeaseDeliveries    *preparedStmt
+	stmtCompleteDelivery   *preparedStmt
+	stmtPruneOutbox        *preparedStmt
+	stmtListDeadDeliveries *preparedStmt
+	stmtRetryDelivery      *preparedStmt
+
+	// stmtListEvents, stmtLatestEvent and stmtOldestEvent serve the event
+	// stream (events.go).
+	stmtListEvents  *preparedStmt
+	stmtLatestEvent *preparedStmt
+	stmtOldestEvent *preparedStmt
+
+	// connStr opens the dedicated LISTEN connection of the event feed.
+	connStr string
+}
+
+// reviewColumns are the reviews columns read by scanReview, in order.
+const reviewColumns = `id, title, director, releaseDate, rating, reviewNotes, dateCreated, updatedAt, deletedAt`
+
+// The read queries below run on the primary or on a replica (replica.go).
+const (
+	// queryGetReviewById selects a review outside the trash by ID.
+	queryGetReviewById = `SELECT ` + reviewColumns + `
+		FROM public.reviews WHERE id=$1 AND deletedAt IS NULL`
+
+	// queryListDeleted selects the reviews in the trash, most recent first.
+	queryListDeleted = `SELECT ` + reviewColumns + `
+		FROM public.reviews WHERE deletedAt IS NOT NULL
+		ORDER BY deletedAt DESC, id DESC LIMIT $1`
+
+	// queryListRevisions selects the revisions of a review outside the trash.
+	queryListRevisions = `SELECT ` + revisionColumns + `
+		FROM public.review_revisions v JOIN public.reviews r ON r.id = v.reviewId
+		WHERE v.reviewId=$1 AND r.deletedAt IS NULL
+		ORDER BY v.revision DESC`
+
+	// queryGetRevision selects one revision of a review outside the trash.
+	queryGetRevision = `SELECT ` + revisionColumns + `
+		FROM public.review_revisions v JOIN public.reviews r ON r.id = v.reviewId
+		WHERE v.reviewId=$1 AND v.revision=$2 AND r.deletedAt IS NULL`
+
+	// queryListAudit selects a page of audit entries, newest first.
+	queryListAudit = `SELECT ` + auditColumns + `
+		FROM public.audit_log
+		WHERE ($1 = '' OR actor = $1) AND ($2 = '' OR action = $2)
+			AND ($3::timestamptz IS NULL OR occurredAt >= $3)
+			AND ($4::timestamptz IS NULL OR occurredAt < $4)
+			AND ($5::bigint = 0 OR id < $5)
+		ORDER BY id DESC LIMIT $6`
+
+	// queryListEvents selects the outbox events after an ID, in ID order.
+	queryListEvents = `SELECT ` + eventColumns + `
+		FROM public.outbox_events WHERE id > $1 ORDER BY id LIMIT $2`
+)
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanReview reads the reviewColumns of row into review.
+func scanReview(row rowScanner, review *Review) error {
+	return row.Scan(
+		&review.ID,
+		&review.Title,
+		&review.Director,
+		&review.ReleaseDate,
+		&review.Rating,
+		&review.ReviewNotes,
+		&review.DateCreated,
+		&review.UpdatedAt,
+		&review.DeletedAt)
+}
+
+// validSSLModes lists the sslmode values supported by the lib/pq driver.
+var validSSLModes = map[string]bool{
+	"disable":     true,
+	"require":     true,
+	"verify-ca":   true,
+	"verify-full": true,
+}
+
+// Supported values for DBConfig.Driver.
+const (
+	driverPostgres = "postgres"
+	driverSQLite   = "sqlite"
+)
+
+// DBConfig holds the database connection settings.
+//
+// Driver selects the backend. For SQLite only Path and the pool, timeout and
+// migration settings apply.
+//
+// For PostgreSQL a complete connection string can be supplied through URL,
+// either as a postgres:// URL or a key=value DSN. Otherwise the discrete
+// connection fields (Host, Port, User, Password, Name) are used. The TLS and
+// session settings are applied in both cases and override values in URL.
+type DBConfig struct {
+	// Driver is the storage backend: "postgres" or "sqlite".
+	Driver string `yaml:"driver"`
+
+	// Path is the SQLite database file (":memory:" for a throwaway database).
+	Path string `yaml:"path"`
+
+	// URL is a full DSN or postgres:// URL. When set, the discrete
+	// connection fields are ignored.
+	URL DSN `yaml:"url"`
+
+	// Host, Port, User, Password and Name identify the database server
+	// and the database to connect to. Password (like URL) is redacted
+	// whenever the configuration is printed or logged.
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Password Secret `yaml:"password"`
+	Name     string `yaml:"name"`
+
+	// SSLMode is one of disable, require, verify-ca or verify-full.
+	// When empty, URL's sslmode is used, or "disable" for discrete settings.
+	SSLMode string `yaml:"sslmode"`
+
+	// SSLRootCert is the CA bundle used to verify the server certificate.
+	SSLRootCert string `yaml:"sslrootcert"`
+
+	// SSLCert and SSLKey are the client certificate and key for
+	// certificate authentication.
+	SSLCert string `yaml:"sslcert"`
+	SSLKey  string `yaml:"sslkey"`
+
+	// ApplicationName is reported to PostgreSQL (visible in pg_stat_activity).
+	ApplicationName string `yaml:"application_name"`
+
+	// SearchPath sets the schema search path for every connection.
+	SearchPath string `yaml:"search_path"`
+
+	// StatementTimeout aborts statements running longer than this on the
+	// server side. Zero leaves the server default in place.
+	StatementTimeout time.Duration `yaml:"statement_timeout"`
+
+	// MaxOpenConns is the maximum number of open connections to the database.
+	// This limits resource usage and prevents overwhelming the database server.
+	MaxOpenConns int `yaml:"max_open_conns"`
+
+	// MaxIdleConns is the maximum number of idle connections retained in the pool.
+	MaxIdleConns int `yaml:"max_idle_conns"`
+
+	// ConnMaxLifetime is the maximum duration a connection can be reused.
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+
+	// QueryTimeout is the maximum duration for database operations.
+	// Operations exceeding this timeout will be cancelled and return an error.
+	QueryTimeout time.Duration `yaml:"query_timeout"`
+
+	// Timeouts overrides QueryTimeout for individual operations.
+	Timeouts OperationTimeouts `yaml:"timeouts"`
+
+	// Replicas lists the DSNs or postgres:// URLs of read replicas. The TLS,
+	// session and pool settings above apply to them as well.
+	Replicas []DSN `yaml:"replicas"`
+
+	// ReplicaCheckInterval is the time between replica health checks.
+	ReplicaCheckInterval time.Duration `yaml:"replica_check_interval"`
+
+	// ConnectRetry controls the backoff between attempts to reach the
+	// database at startup.
+	ConnectRetry BackoffConfig `yaml:"connect_retry"`
+
+	// AutoMigrate applies pending schema migrations during initialization.
+	// When disabled, migrations must be applied externally and /readyz
+	// reports not-ready until the schema is current.
+	AutoMigrate bool `yaml:"auto_migrate"`
+}
+
+// OperationTimeouts holds a time budget per Storage operation. A zero value
+// means the operation uses DBConfig.QueryTimeout.
+//
+// Each budget is applied on top of the caller's context, so an operation is
+// cancelled by whichever comes first: its budget expiring, the client
+// disconnecting, or the server giving up on in-flight requests at shutdown.
+type OperationTimeouts struct {
+	Create time.Duration `yaml:"create"`
+	Update time.Duration `yaml:"update"`
+	Delete time.Duration `yaml:"delete"`
+	Get    time.Duration `yaml:"get"`
+}
+
+// withDefault returns a copy with every unset budget replaced by fallback.
+func (t OperationTimeouts) withDefault(fallback time.Duration) OperationTimeouts {
+	for _, budget := range []*time.Duration{&t.Create, &t.Update, &t.Delete, &t.Get} {
+		if *budget == 0 {
+			*budget = fallback
+		}
+	}
+	return t
+}
+
+var (
+	// ErrCanceled is returned when an operation's context was cancelled
+	// before it completed, e.g. because the client disconnected.
+	ErrCanceled = errors.New("operation canceled")
+
+	// ErrTimeout is returned when an operation exceeded its time budget.
+	ErrTimeout = errors.New("operation timed out")
+
+	// ErrNotReady is returned when the backend has not finished connecting.
+	ErrNotReady = errors.New("storage not ready")
+
+	// ErrNotFound is returned when no review has the requested ID.
+	ErrNotFound = errors.New("not found")
+)
+
+// contextError classifies a failed operation by the state of its context.
+// If the context is done, the returned error wraps ErrTimeout or ErrCanceled
+// together with the context's cause and the original error; otherwise err
+// is returned unchanged.
+//
+// The database driver reports an aborted query as a generic server error
+// ("canceling statement due to user request"), so the context must be
+// inspected to tell cancellations apart from genuine failures.
+func contextError(ctx context.Context, err error) error {
+	switch {
+	case ctx.Err() == nil:
+		return err
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	default:
+		return fmt.Errorf("%w (%w): %w", ErrCanceled, context.Cause(ctx), err)
+	}
+}
+
+// Validate checks the settings for mistakes that would otherwise only
+// surface when the first connection is attempted.
+//
+// Returns:
+//   - error: Non-nil describing the first invalid setting found
+func (c DBConfig) Validate() error {
+	switch c.Driver {
+	case driverPostgres:
+		if err := c.validatePostgres(); err != nil {
+			return err
+		}
+	case driverSQLite:
+		if c.Path == "" {
+			return fmt.Errorf("sqlite database path must be set")
+		}
+		if len(c.Replicas) > 0 {
+			return fmt.Errorf("read replicas are only supported with postgres")
+		}
+	default:
+		return fmt.Errorf("unsupported driver %q (use %s or %s)", c.Driver, driverPostgres, driverSQLite)
+	}
+	if c.MaxOpenConns <= 0 {
+		return fmt.Errorf("max open connections must be positive")
+	}
+	if c.MaxIdleConns < 0 || c.MaxIdleConns > c.MaxOpenConns {
+		return fmt.Errorf("max idle connections must be between 0 and max open connections")
+	}
+	if c.ConnMaxLifetime < 0 {
+		return fmt.Errorf("connection max lifetime must not be negative")
+	}
+	if c.QueryTimeout <= 0 {
+		return fmt.Errorf("query timeout must be positive")
+	}
+	for _, budget := range []time.Duration{c.Timeouts.Create, c.Timeouts.Update, c.Timeouts.Delete, c.Timeouts.Get} {
+		if budget < 0 {
+			return fmt.Errorf("operation timeouts must not be negative")
+		}
+	}
+	if len(c.Replicas) > 0 && c.ReplicaCheckInterval <= 0 {
+		return fmt.Errorf("replica check interval must be positive")
+	}
+	if err := c.ConnectRetry.Validate(); err != nil {
+		return fmt.Errorf("connect retry: %w", err)
+	}
+	return nil
+}
+
+// validatePostgres checks the PostgreSQL connection and TLS settings.
+func (c DBConfig) validatePostgres() error {
+	if c.URL == "" {
+		if c.Host == "" {
+			return fmt.Errorf("database host must be set")
+		}
+		if c.Port <= 0 || c.Port > 65535 {
+			return fmt.Errorf("database port %d out of range", c.Port)
+		}
+		if c.User == "" {
+			return fmt.Errorf("database user must be set")
+		}
+		if c.Name == "" {
+			return fmt.Errorf("database name must be set")
+		}
+	}
+	if c.SSLMode != "" && !validSSLModes[c.SSLMode] {
+		return fmt.Errorf("unsupported sslmode %q (use disable, require, verify-ca or verify-full)", c.SSLMode)
+	}
+	if (c.SSLCert == "") != (c.SSLKey == "") {
+		return fmt.Errorf("client certificate and key must be set together")
+	}
+	for _, file := range []string{c.SSLRootCert, c.SSLCert, c.SSLKey} {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			return fmt.Errorf("database TLS file: %w", err)
+		}
+	}
+	if c.StatementTimeout < 0 {
+		return fmt.Errorf("statement timeout must not be negative")
+	}
+	return nil
+}
+
+// quoteDSNValue quotes a value for a key=value connection string.
+// Values containing spaces, quotes or backslashes are wrapped in single
+// quotes with quotes and backslashes escaped, as libpq requires.
+func quoteDSNValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, " '\\\t\n") {
+		return value
+	}
+	escaper := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	return "'" + escaper.Replace(value) + "'"
+}
+
+// ConnString builds the key=value connection string for lib/pq.
+// Every value is escaped, so passwords containing spaces or quotes are safe.
+//
+// Returns:
+//   - string: The connection string
+//   - error: Non-nil if URL cannot be parsed
+func (c DBConfig) ConnString() (string, error) {
+	var parts []string
+	add := func(key, value string) {
+		if value != "" {
+			parts = append(parts, key+"="+quoteDSNValue(value))
+		}
+	}
+
+	if c.URL != "" {
+		base := c.URL.Value()
+		if strings.HasPrefix(base, "postgres://") || strings.HasPrefix(base, "postgresql://") {
+			converted, err := pq.ParseURL(base)
+			if err != nil {
+				// url.Error embeds the raw URL and its password; keep only the cause
+				var urlErr *url.Error
+				if errors.As(err, &urlErr) {
+					err = urlErr.Err
+				}
+				return "", fmt.Errorf("invalid database URL: %w", err)
+			}
+			base = converted
+		}
+		parts = append(parts, base)
+	} else {
+		add("host", c.Host)
+		add("port", strconv.Itoa(c.Port))
+		add("user", c.User)
+		add("password", c.Password.Value())
+		add("dbname", c.Name)
+		if c.SSLMode == "" {
+			add("sslmode", "disable")
+		}
+	}
+
+	// Settings below override any value given in URL (later keys win)
+	add("sslmode", c.SSLMode)
+	add("sslrootcert", c.SSLRootCert)
+	add("sslcert", c.SSLCert)
+	add("sslkey", c.SSLKey)
+	add("fallback_application_name", "goBackend")
+	add("application_name", c.ApplicationName)
+	add("search_path", c.SearchPath)
+	if c.StatementTimeout > 0 {
+		add("statement_timeout", strconv.FormatInt(c.StatementTimeout.Milliseconds(), 10))
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// NewPgDb creates a PostgreSQL client from the provided settings without
+// contacting the database. Call Connect before using it; until then every
+// Storage method returns ErrNotReady and CheckReady reports not-ready.
+//
+// The function performs the following steps:
+//  1. Validates the settings and builds an escaped connection string
+//  2. Opens database connection pool (does not actually connect yet)
+//  3. Configures connection pool settings (max connections, idle connections, lifetime)
+//
+// Parameters:
+//   - cfg: The database connection settings
+//   - logger: Structured logger used for storage operation logs
+//   - metrics: Metrics registry for connection pool statistics (may be nil)
+//
+// Returns:
+//   - *PgDb: Database client waiting to be connected
+//   - error: Non-nil if the settings are invalid
+func NewPgDb(cfg DBConfig, logger *slog.Logger, metrics *Metrics) (*PgDb, error) {
+	// Reject invalid settings before touching the network
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid database configuration: %w", err)
+	}
+
+	// The event feed listens on a connection of its own (see events.go)
+	connStr, err := cfg.ConnString()
+	if err != nil {
+		return nil, fmt.Errorf("invalid database configuration: %w", err)
+	}
+
+	// The embedded migrations do not change while running
+	schemaVersion, err := latestMigrationVersion(postgresMigrations, "migrations/postgres")
+	if err != nil {
+		return nil, err
+	}
+	db, err := openPool(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Expose pool statistics (open, in-use, idle, waits) as gauges
+	if err := metrics.RegisterDBStats("primary", db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("register pool metrics: %w", err)
+	}
+
+	// Open the read replica pools, if any, with the same settings
+	replicas, err := openReplicas(cfg, metrics)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &PgDb{
+		db:                   db,
+		replicas:             replicas,
+		replicaCheckInterval: cfg.ReplicaCheckInterval,
+		timeouts:             cfg.Timeouts.withDefault(cfg.QueryTimeout),
+		connectRetry:         cfg.ConnectRetry,
+		autoMigrate:          cfg.AutoMigrate,
+		schemaVersion:        schemaVersion,
+		logger:               logger.With("component", "storage"),
+		metrics:              metrics,
+		connStr:              connStr,
+	}, nil
+}
+
+// openPool opens and configures a connection pool for cfg's URL or
+// discrete connection settings. It does not contact the server.
+func openPool(cfg DBConfig) (*sql.DB, error) {
+	connStr, err := cfg.ConnString()
+	if err != nil {
+		return nil, fmt.Errorf("invalid database configuration: %w", err)
+	}
+
+	// Parse the connection string up front so malformed DSNs fail at startup
+	connector, err := pq.NewConnector(connStr)
+	if err != nil {
+		return nil, fmt.Errorf("parse connection string: %w", err)
+	}
+
+	// Open database connection pool (does not actually connect yet)
+	db := sql.OpenDB(connector)
+
+	// Configure connection pool for optimal performance
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	return db, nil
+}
+
+// Connect waits for the database to accept connections and makes the
+// client ready for use.
+//
+// The function performs the following steps:
+//  1. Pings the database, retrying with exponential backoff and jitter
+//     (DBConfig.ConnectRetry) while it is unreachable
+//  2. Applies pending schema migrations (when DBConfig.AutoMigrate is set)
+//  3. Prepares SQL statements for CRUD operations
+//
+// Only the ping is retried: a failing migration or statement points at a
+// problem that waiting will not fix.
+//
+// Parameters:
+//   - ctx: Context that aborts connecting (e.g. on shutdown)
+//
+// Returns:
+//   - error: Non-nil if the database stayed unreachable for
+//     ConnectRetry.MaxElapsed, ctx was cancelled, or a later step failed
+func (pg *PgDb) Connect(ctx context.Context) error {
+	// Verify the connection is actually working, waiting while it is not
+	onRetry := func(attempt int, err error, next time.Duration) {
+		pg.logger.Warn("database unreachable, retrying", "attempt", attempt, "retry_in", next, "error", err)
+	}
+	if err := pg.connectRetry.Retry(ctx, onRetry, pg.db.PingContext); err != nil {
+		return fmt.Errorf("ping database: %w", err)
+	}
+
+	// Bring the schema up to date before preparing statements against it
+	if pg.autoMigrate {
+		if err := pg.Migrate(ctx); err != nil {
+			return fmt.Errorf("migrate schema: %w", err)
+		}
+	}
+
+	// Prepare statements for better performance (parsed once, executed many times)
+	if err := pg.prepareStatements(ctx); err != nil {
+		return fmt.Errorf("prepare statements: %w", err)
+	}
+
+	pg.ready.Store(true)
+
+	// Replicas join the read rotation as their health checks succeed
+	pg.startReplicaChecks()
+	return nil
+}
+
+// InitializeClientAndDB creates a PostgreSQL client and connects it,
+// blocking until the database is ready (see NewPgDb and Connect).
+//
+// Parameters:
+//   - cfg: The database connection settings
+//   - logger: Structured logger used for storage operation logs
+//   - metrics: Metrics registry for connection pool statistics (may be nil)
+//
+// Returns:
+//   - *PgDb: Configured database client ready for use
+//   - error: Non-nil if any initialization step fails
+//
+// Example:
+//
+//	cfg, _, _ := LoadConfig(os.Args[1:])
+//	client, err := InitializeClientAndDB(cfg.Database, slog.Default(), nil)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	defer client.Close()
+func InitializeClientAndDB(cfg DBConfig, logger *slog.Logger, metrics *Metrics) (*PgDb, error) {
+	pgDb, err := NewPgDb(cfg, logger, metrics)
+	if err != nil {
+		return nil, err
+	}
+	if err := pgDb.Connect(context.Background()); err != nil {
+		pgDb.Close()
+		return nil, err
+	}
+	return pgDb, nil
+}
+
+// prepareStatements creates prepared statements for all CRUD operations.
+// Prepared statements are parsed and planned once by PostgreSQL, then reused
+// for subsequent executions, providing significant performance benefits.
+//
+// This is called automatically by Connect and should not be called directly.
+//
+// Parameters:
+//   - ctx: Context that aborts preparation
+//
+// Returns:
+//   - error: Non-nil if any statement preparation fails
+func (pg *PgDb) prepareStatements(ctx context.Context) error {
+	var err error
+
+	// Prepare INSERT statement for creating new reviews
+	pg.stmtCreate, err = prepareStmt(ctx, pg.db, "create", `INSERT INTO public.reviews (
+		title,director,releaseDate,rating,reviewNotes,dateCreated
+	) VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id, updatedAt`)
+	if err != nil {
+		return err
+	}
+
+	// Prepare UPDATE statement for modifying existing reviews
+	pg.stmtUpdate, err = prepareStmt(ctx, pg.db, "update", `UPDATE public.reviews 
+		SET title=$1, director=$2, releaseDate=$3, rating=$4, reviewNotes=$5, updatedAt=now()
+		WHERE id=$6 AND deletedAt IS NULL
+		RETURNING updatedAt`)
+	if err != nil {
+		return err
+	}
+
+	// Prepare the soft DELETE statement moving reviews to the trash
+	pg.stmtDelete, err = prepareStmt(ctx, pg.db, "delete", `UPDATE public.reviews
+		SET deletedAt=now(), updatedAt=now()
+		WHERE id=$1 AND deletedAt IS NULL
+		RETURNING `+reviewColumns)
+	if err != nil {
+		return err
+	}
+
+	// Prepare SELECT statement for fetching reviews by ID
+	pg.stmtGetById, err = prepareStmt(ctx, pg.db, "getById", queryGetReviewById)
+	if err != nil {
+		return err
+	}
+
+	// Prepare the trash statements
+	pg.stmtListDeleted, err = prepareStmt(ctx, pg.db, "listDeleted", queryListDeleted)
+	if err != nil {
+		return err
+	}
+	pg.stmtRestore, err = prepareStmt(ctx, pg.db, "restore", `UPDATE public.reviews
+		SET deletedAt=NULL, updatedAt=now()
+		WHERE id=$1 AND deletedAt IS NOT NULL
+		RETURNING `+reviewColumns)
+	if err != nil {
+		return err
+	}
+	pg.stmtPurge, err = prepareStmt(ctx, pg.db, "purge", `DELETE FROM public.reviews
+		WHERE deletedAt IS NOT NULL AND deletedAt < $1
+		RETURNING `+reviewColumns)
+	if err != nil {
+		return err
+	}
+
+	// Prepare the statement locking a review before a write that records its
+	// previous version. This also serializes the numbering of revisions.
+	pg.stmtLockById, err = prepareStmt(ctx, pg.db, "lockById", `SELECT `+reviewColumns+`
+		FROM public.reviews WHERE id=$1 FOR UPDATE`)
+	if err != nil {
+		return err
+	}
+
+	// Prepare the revision history statements
+	pg.stmtInsertRevision, err = prepareStmt(ctx, pg.db, "insertRevision", `INSERT INTO public.review_revisions (
+		reviewId, revision, author, createdAt, changedFields,
+		title, director, releaseDate, rating, reviewNotes
+	) SELECT $1, COALESCE(max(revision), 0) + 1, $2, $3, $4, $5, $6, $7, $8, $9
+	FROM public.review_revisions WHERE reviewId=$1`)
+	if err != nil {
+		return err
+	}
+	pg.stmtListRevisions, err = prepareStmt(ctx, pg.db, "listRevisions", queryListRevisions)
+	if err != nil {
+		return err
+	}
+	pg.stmtGetRevision, err = prepareStmt(ctx, pg.db, "getRevision", queryGetRevision)
+	if err != nil {
+		return err
+	}
+
+	// Prepare the audit log statements
+	pg.stmtInsertAudit, err = prepareStmt(ctx, pg.db, "insertAudit", `INSERT INTO public.audit_log (
+		occurredAt, actor, action, reviewId, before, after, requestId, clientIp, claimedActor
+	) VALUES (now(), $1, $2, $3, $4, $5, $6, $7, $8)`)
+	if err != nil {
+		return err
+	}
+	pg.stmtListAudit, err = prepareStmt(ctx, pg.db, "listAudit", queryListAudit)
+	if err != nil {
+		return err
+	}
+
+	// Prepare the outbox statements. Claims lock the due deliveries and skip
+	// those locked by other instances.
+	// Events take their ID under a transaction lock (see eventOrderLockID)
+	pg.stmtInsertEvent, err = prepareStmt(ctx, pg.db, "insertEvent", `INSERT INTO public.outbox_events (
+		eventType, reviewId, payload
+	) SELECT $1::varchar, $2::integer, $3::jsonb FROM (SELECT pg_advisory_xact_lock($4)) AS ordered`)
+	if err != nil {
+		return err
+	}
+	pg.stmtFanOutEvents, err = prepareStmt(ctx, pg.db, "fanOutEvents", `WITH events AS (
+		UPDATE public.outbox_events SET fannedOut = true
+		WHERE id IN (
+			SELECT id FROM public.outbox_events WHERE NOT fannedOut
+			ORDER BY id LIMIT $2 FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id
+	)
+	INSERT INTO public.webhook_deliveries (eventId, webhook)
+	SELECT events.id, webhook FROM events CROSS JOIN unnest($1::varchar[]) AS webhook
+	ON CONFLICT (eventId, webhook) DO NOTHING`)
+	if err != nil {
+		return err
+	}
+	pg.stmtClaimDeliveries, err = prepareStmt(ctx, pg.db, "claimDeliveries", `SELECT `+deliveryColumns+`
+		FROM public.webhook_deliveries d JOIN public.outbox_events e ON e.id = d.eventId
+		WHERE d.status = 'pending' AND d.nextAttemptAt <= now()
+		ORDER BY d.nextAttemptAt, d.id LIMIT $1
+		FOR UPDATE OF d SKIP LOCKED`)
+	if err != nil {
+		return err
+	}
+	pg.stmtLeaseDeliveries, err = prepareStmt(ctx, pg.db, "leaseDeliveries", `UPDATE public.webhook_deliveries
+		SET nextAttemptAt = now() + make_interval(secs => $2), updatedAt = now()
+		WHERE id = ANY($1)`)
+	if err != nil {
+		return err
+	}
+	pg.stmtCompleteDelivery, err = prepareStmt(ctx, pg.db, "completeDelivery", `UPDATE public.webhook_deliveries
+		SET status = $2, attempts = attempts + 1, nextAttemptAt = now() + make_interval(secs => $3),
+			lastError = $4, updatedAt = now()
+		WHERE id = $1`)
+	if err != nil {
+		return err
+	}
+	pg.stmtPruneOutbox, err = prepareStmt(ctx, pg.db, "pruneOutbox", `DELETE FROM public.outbox_events e
+		WHERE e.fannedOut AND e.createdAt < $1 AND NOT EXISTS (
+			SELECT 1 FROM public.webhook_deliveries d WHERE d.eventId = e.id AND d.status <> 'delivered'
+		)`)
+	if err != nil {
+		return err
+	}
+	pg.stmtListDeadDeliveries, err = prepareStmt(ctx, pg.db, "listDeadDeliveries", `SELECT `+deliveryColumns+`
+		FROM public.webhook_deliveries d JOIN public.outbox_events e ON e.id = d.eventId
+		WHERE d.status = 'dead'
+		ORDER BY d.id DESC LIMIT $1`)
+	if err != nil {
+		return err
+	}
+	pg.stmtRetryDelivery, err = prepareStmt(ctx, pg.db, "retryDelivery", `WITH d AS (
+		UPDATE public.webhook_deliveries
+		SET status = 'pending', attempts = 0, nextAttemptAt = now(), updatedAt = now()
+		WHERE id = $1 AND status = 'dead'
+		RETURNING *
+	)
+	SELECT `+deliveryColumns+` FROM d JOIN public.outbox_events e ON e.id = d.eventId`)
+	if err != nil {
+		return err
+	}
+
+	// Statements of the event stream
+	pg.stmtListEvents, err = prepareStmt(ctx, pg.db, "listEvents", queryListEvents)
+	if err != nil {
+		return err
+	}
+	pg.stmtLatestEvent, err = prepareStmt(ctx, pg.db, "latestEvent", `SELECT COALESCE(MAX(id), 0) FROM public.outbox_events`)
+	if err != nil {
+		return err
+	}
+	// An empty outbox keeps nothing before the next ID of its sequence
+	pg.stmtOldestEvent, err = prepareStmt(ctx, pg.db, "oldestEvent", `SELECT COALESCE(MIN(id), (
+		SELECT CASE WHEN is_called THEN last_value + 1 ELSE last_value END FROM public.outbox_events_id_seq
+	)) FROM public.outbox_events`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Close releases all database resources including prepared statements and
+// the connection pool. This should be called when the application shuts down
+// to ensure clean resource cleanup.
+//
+// It is safe to call Close multiple times; subsequent calls are no-ops for
+// already-closed statements.
+//
+// Returns:
+//   - error: Non-nil if closing the database connection fails
+//
+// Example:
+//
+//	client, _ := InitializeClientAndDB()
+//	defer client.Close() // Ensure cleanup on exit
+func (pg *PgDb) Close() error {
+	// Stop health checks before closing the replicas they use
+	if pg.stopReplicaChecks != nil {
+		pg.stopReplicaChecks()
+		pg.stopReplicaChecks = nil
+	}
+	closeReplicas(pg.replicas)
+
+	// Close all prepared statements first
+	for _, stmt := range []*preparedStmt{pg.stmtCreate, pg.stmtUpdate, pg.stmtDelete, pg.stmtGetById,
+		pg.stmtListDeleted, pg.stmtRestore, pg.stmtPurge,
+		pg.stmtLockById, pg.stmtInsertRevision, pg.stmtListRevisions, pg.stmtGetRevision,
+		pg.stmtInsertAudit, pg.stmtListAudit,
+		pg.stmtInsertEvent, pg.stmtFanOutEvents, pg.stmtClaimDeliveries, pg.stmtLeaseDeliveries,
+		pg.stmtCompleteDelivery, pg.stmtPruneOutbox, pg.stmtListDeadDeliveries, pg.stmtRetryDelivery,
+		pg.stmtListEvents, pg.stmtLatestEvent, pg.stmtOldestEvent} {
+		if stmt != nil {
+			stmt.close()
+		}
+	}
+	// Close the underlying database connection pool
+	return pg.db.Close()
+}
+
+// beginOperation starts tracing and timing a storage operation.
+// It returns the context to use for the operation, carrying a child span of
+// the caller's span, and a function to be deferred with a pointer to the
+// named error result so the span and log line reflect the final outcome.
+//
+// Parameters:
+//   - ctx: The caller's context, carrying the request ID and trace if any
+//   - operation: The operation name (e.g., "create", "get")
+//   - sqlOperation: The SQL statement kind recorded on the span (e.g., "INSERT")
+//   - id: The review ID involved, or 0 when not yet known
+//
+// Returns:
+//   - context.Context: The operation context
+//   - func(*error): Ends the span and logs the outcome with its duration
+func (pg *PgDb) beginOperation(ctx context.Context, operation, sqlOperation string, id int) (context.Context, func(*error)) {
+	return beginStorageOperation(ctx, pg.logger, "PgDb", "postgresql", operation, sqlOperation, id)
+}
+
+// lockReview reads and locks the review with the given ID within tx, whether
+// it is in the trash or not. It returns sql.ErrNoRows if there is none.
+func (pg *PgDb) lockReview(ctx context.Context, tx *sql.Tx, id int) (*Review, error) {
+	review := &Review{}
+	err := pg.withStatement(ctx, pg.stmtLockById, false, func(stmt *sql.Stmt) error {
+		return scanReview(tx.StmtContext(ctx, stmt).QueryRowContext(ctx, id), review)
+	})
+	return review, err
+}
+
+// inTx runs fn in a transaction, committing it if fn succeeds and rolling it
+// back otherwise.
+//
+// Parameters:
+//   - ctx: Context for the transaction; cancelling it rolls the transaction back
+//   - fn: The statements to run, executed with tx.StmtContext
+//
+// Returns:
+//   - error: The error of fn, or of beginning or committing the transaction
+func (pg *PgDb) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// beginStorageOperation implements beginOperation for any SQL backend.
+//
+// Parameters:
+//   - ctx: The caller's context, carrying the request ID and trace if any
+//   - logger: Logger receiving the outcome
+//   - backend: The backend type, used as the span name prefix (e.g., "PgDb")
+//   - system: The db.system span attribute (e.g., "postgresql")
+//   - operation, sqlOperation, id: As for PgDb.beginOperation
+//
+// Returns:
+//   - context.Context: The operation context
+//   - func(*error): Ends the span and logs the outcome with its duration
+func beginStorageOperation(ctx context.Context, logger *slog.Logger, backend, system, operation, sqlOperation string, id int) (context.Context, func(*error)) {
+	start := time.Now()
+	ctx, span := storageTracer.Start(ctx, backend+"."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", system),
+			attribute.String("db.operation.name", sqlOperation),
+			attribute.String("db.collection.name", "reviews"),
+		))
+	if id != 0 {
+		span.SetAttributes(attribute.Int("review_id", id))
+	}
+
+	return ctx, func(errp *error) {
+		defer span.End()
+
+		attrs := []any{"operation", operation, "duration", time.Since(start)}
+		if id != 0 {
+			attrs = append(attrs, "review_id", id)
+		}
+		if *errp != nil {
+			span.RecordError(*errp)
+			span.SetStatus(codes.Error, "storage operation failed")
+			logger.WarnContext(ctx, "storage operation failed", append(attrs, "error", *errp)...)
+			return
+		}
+		logger.DebugContext(ctx, "storage operation completed", attrs...)
+	}
+}
+
+// CreateReview inserts a new review into the database, together with its
+// first revision (see revisions.go), audit entry (see audit.go) and
+// review.created event (see outbox.go).
+// The review's ID field is ignored as the database auto-generates it; the
+// generated ID and UpdatedAt are stored back into review.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout control
+//   - review: The review data to persist (ID field is ignored)
+//
+// Returns:
+//   - string: Success message including the creation timestamp
+//   - error: Non-nil if the insert operation fails
+//
+// The operation is subject to the create budget (default 10 seconds). If the
+// context is cancelled or the budget expires, the error wraps ErrCanceled or
+// ErrTimeout.
+func (pg *PgDb) CreateReview(ctx context.Context, review *Review) (_ string, err error) {
+	ctx, finish := pg.beginOperation(ctx, "create", "INSERT", review.ID)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !pg.ready.Load() {
+		return "", ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, pg.timeouts.Create)
+	defer cancel()
+
+	// Execute the prepared INSERT statement (not repeated: it is not idempotent)
+	err = pg.inTx(ctx, func(tx *sql.Tx) error {
+		err := pg.withStatement(ctx, pg.stmtCreate, false, func(stmt *sql.Stmt) error {
+			return tx.StmtContext(ctx, stmt).QueryRowContext(ctx,
+				review.Title,
+				review.Director,
+				review.ReleaseDate,
+				review.Rating,
+				review.ReviewNotes,
+				review.DateCreated).Scan(&review.ID, &review.UpdatedAt)
+		})
+		if err != nil {
+			return err
+		}
+		if err := pg.insertRevision(ctx, tx, review, ReviewFields); err != nil {
+			return err
+		}
+		if err := pg.insertAudit(ctx, tx, AuditCreate, review.ID, nil, review); err != nil {
+			return err
+		}
+		return pg.insertEvent(ctx, tx, EventReviewCreated, review)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create review: %w", contextError(ctx, err))
+	}
+	pg.recordWritePosition(ctx)
+
+	success := "Review Created :: Recorded In DB:: " + review.DateCreated
+	return success, nil
+}
+
+// UpdateReview modifies an existing review in the database.
+// The review is identified by its ID field; all other fields are updated.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout control
+//   - review: The review data with updated values (ID must be set)
+//
+// Returns:
+//   - error: Non-nil if the update fails or no review exists with the given ID
+//
+// The new modification time is stored in review.UpdatedAt. If no row has
+// the given ID, an error is returned indicating the review was not found.
+// The change is audited and written to the outbox and, when a field
+// changes, a revision is recorded, in the same transaction.
+func (pg *PgDb) UpdateReview(ctx context.Context, review *Review) (err error) {
+	ctx, finish := pg.beginOperation(ctx, "update", "UPDATE", review.ID)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !pg.ready.Load() {
+		return ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, pg.timeouts.Update)
+	defer cancel()
+
+	err = pg.inTx(ctx, func(tx *sql.Tx) error {
+		// Lock the current version; reviews in the trash are not found
+		current, err := pg.lockReview(ctx, tx, review.ID)
+		if err != nil {
+			return err
+		}
+		if current.DeletedAt != nil {
+			return sql.ErrNoRows
+		}
+
+		// Execute the prepared UPDATE statement
+		err = pg.withStatement(ctx, pg.stmtUpdate, false, func(stmt *sql.Stmt) error {
+			return tx.StmtContext(ctx, stmt).QueryRowContext(ctx,
+				review.Title,
+				review.Director,
+				review.ReleaseDate,
+				review.Rating,
+				review.ReviewNotes,
+				review.ID).Scan(&review.UpdatedAt)
+		})
+		if err != nil {
+			return err
+		}
+		if changed := changedFields(current, review); len(changed) > 0 {
+			if err := pg.insertRevision(ctx, tx, review, changed); err != nil {
+				return err
+			}
+		}
+		after := updatedReview(current, review)
+		if err := pg.insertAudit(ctx, tx, AuditUpdate, review.ID, current, after); err != nil {
+			return err
+		}
+		return pg.insertEvent(ctx, tx, EventReviewUpdated, after)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("review with id %d %w", review.ID, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update review: %w", contextError(ctx, err))
+	}
+	pg.recordWritePosition(ctx)
+	return nil
+}
+
+// DeleteReview moves a review to the trash by its ID. The row is kept, with
+// deletedAt set, until it is restored or purged (see trash.go).
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout control
+//   - id: The unique identifier of the review to delete
+//
+// Returns:
+//   - error: Non-nil if the deletion fails or no review exists with the given ID
+//
+// The deletion is audited and written to the outbox in the same
+// transaction. If no review has the given ID (including for a review
+// already in the trash), an error is returned indicating the review was not
+// found.
+func (pg *PgDb) DeleteReview(ctx context.Context, id int) (err error) {
+	ctx, finish := pg.beginOperation(ctx, "delete", "DELETE", id)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !pg.ready.Load() {
+		return ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, pg.timeouts.Delete)
+	defer cancel()
+
+	err = pg.inTx(ctx, func(tx *sql.Tx) error {
+		// Lock the current version; reviews already in the trash are not found
+		current, err := pg.lockReview(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if current.DeletedAt != nil {
+			return sql.ErrNoRows
+		}
+
+		// Execute the prepared soft DELETE statement
+		deleted := &Review{}
+		err = pg.withStatement(ctx, pg.stmtDelete, false, func(stmt *sql.Stmt) error {
+			return scanReview(tx.StmtContext(ctx, stmt).QueryRowContext(ctx, id), deleted)
+		})
+		if err != nil {
+			return err
+		}
+		if err := pg.insertAudit(ctx, tx, AuditDelete, id, current, deleted); err != nil {
+			return err
+		}
+		return pg.insertEvent(ctx, tx, EventReviewDeleted, deleted)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("review with id %d %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete review: %w", contextError(ctx, err))
+	}
+	pg.recordWritePosition(ctx)
+	pg.logger.InfoContext(ctx, "moved review to trash", "review_id", id)
+	return nil
+}
+
+// GetReviewById retrieves a single review from the database by its unique ID.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout control
+//   - id: The unique identifier of the review to retrieve
+//
+// Returns:
+//   - *Review: The retrieved review data
+//   - error: Non-nil if the query fails or no review exists with the given ID
+//
+// If no review is found with the given ID, an error wrapping ErrNotFound
+// is returned.
+func (pg *PgDb) GetReviewById(ctx context.Context, id int) (_ *Review, err error) {
+	ctx, finish := pg.beginOperation(ctx, "get", "SELECT", id)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !pg.ready.Load() {
+		return nil, ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, pg.timeouts.Get)
+	defer cancel()
+
+	// Execute the prepared SELECT statement and scan results into Review struct.
+	// Reads are idempotent, so a stale statement is retried once.
+	review := &Review{}
+	query := func(stmt *sql.Stmt) error {
+		return scanReview(stmt.QueryRowContext(ctx, id), review)
+	}
+
+	// Served by a replica that has caught up with the client's writes, if any
+	err = pg.withReadStatement(ctx, pg.stmtGetById, func(s *replicaStatements) *preparedStmt { return s.getById }, query)
+	return getResult(ctx, id, review, err)
+}
+
+// getResult turns the outcome of a query for the review with the given ID
+// into GetReviewById's results, mapping a missing row to ErrNotFound.
+func getResult(ctx context.Context, id int, review *Review, err error) (*Review, error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("review with id %d %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get review: %w", contextError(ctx, err))
+	}
+	return review, nil
+}
@@ -0,0 +1,920 @@
+// Package storagetest provides the Storage conformance suite for the Movie
+// Review API. It verifies that every reviewapi.Storage implementation behaves
+// the same, so backends and decorators can be swapped without changing the
+// handlers.
+//
+// A program with its own Storage runs the suite from one of its tests:
+//
+//	func TestMyStorageConformance(t *testing.T) {
+//		storagetest.RunStorageConformance(t, func(t *testing.T) reviewapi.Storage {
+//			return newMyStorage(t)
+//		})
+//	}
+//
+// reviewapi's storage_conformance_test.go runs it against SqliteDb, against
+// the decorator chain built by main, and against PgDb when TEST_DATABASE_URL
+// is set (see README.md, "Storage Conformance").
+package storagetest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Logan-0/goBackend/reviewapi"
+)
+
+// StorageFactory returns a connected Storage holding no reviews. It is
+// called once per subtest and must register any cleanup with t.Cleanup.
+type StorageFactory func(t *testing.T) reviewapi.Storage
+
+// RunStorageConformance runs the Storage contract tests against the
+// implementation returned by newStorage. The subtests of the optional stores
+// (TrashStore, RevisionStore, AuditStore, WebhookAdmin, EventLog) and of the
+// backend interfaces (WebhookOutbox, ReviewEventSource) are skipped when it
+// does not implement them.
+//
+// The contract:
+//   - Created reviews get a positive, unique ID and a non-zero UpdatedAt and
+//     read back with every field intact
+//   - Updates replace every field and advance UpdatedAt
+//   - Update, delete and get of a missing review return ErrNotFound
+//   - A cancelled or expired context fails the call with ErrCanceled or
+//     ErrTimeout, without applying a write
+//   - Concurrent creates are all persisted under distinct IDs
+//   - Deleted reviews move to the trash: hidden from get, update and delete,
+//     listed with DeletedAt set, restorable until purged
+//   - Creates and changing updates record numbered revisions with the
+//     actor of the context as author and the changed fields
+//   - Every mutating call appends one audit entry per changed review, with
+//     the actor, request ID and client IP of the context and the review
+//     before and after; failed calls append nothing
+func RunStorageConformance(t *testing.T, newStorage StorageFactory) {
+	t.Run("CreateAndGet", func(t *testing.T) {
+		storage := newStorage(t)
+		ctx := context.Background()
+
+		review := testReview("Inception")
+		if _, err := storage.CreateReview(ctx, review); err != nil {
+			t.Fatalf("CreateReview: %v", err)
+		}
+		if review.ID <= 0 {
+			t.Fatalf("CreateReview set ID %d, want a positive ID", review.ID)
+		}
+
+		got, err := storage.GetReviewById(ctx, review.ID)
+		if err != nil {
+			t.Fatalf("GetReviewById(%d): %v", review.ID, err)
+		}
+		assertSameReview(t, got, review)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		storage := newStorage(t)
+		ctx := context.Background()
+
+		review := testReview("Heat")
+		if _, err := storage.CreateReview(ctx, review); err != nil {
+			t.Fatalf("CreateReview: %v", err)
+		}
+
+		// Read once so a caching implementation holds the old version
+		if _, err := storage.GetReviewById(ctx, review.ID); err != nil {
+			t.Fatalf("GetReviewById(%d): %v", review.ID, err)
+		}
+
+		updated := *review
+		updated.Title = "Heat (Director's Cut)"
+		updated.Director = "M. Mann"
+		updated.ReleaseDate = "15 Dec 95 00:00 UTC"
+		updated.Rating = "10/10"
+		updated.ReviewNotes = "Even better the second time"
+		if err := storage.UpdateReview(ctx, &updated); err != nil {
+			t.Fatalf("UpdateReview: %v", err)
+		}
+
+		got, err := storage.GetReviewById(ctx, review.ID)
+		if err != nil {
+			t.Fatalf("GetReviewById(%d): %v", review.ID, err)
+		}
+		assertSameReview(t, got, &updated)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		storage := newStorage(t)
+		ctx := context.Background()
+
+		review := testReview("Alien")
+		if _, err := storage.CreateReview(ctx, review); err != nil {
+			t.Fatalf("CreateReview: %v", err)
+		}
+		if _, err := storage.GetReviewById(ctx, review.ID); err != nil {
+			t.Fatalf("GetReviewById(%d): %v", review.ID, err)
+		}
+
+		if err := storage.DeleteReview(ctx, review.ID); err != nil {
+			t.Fatalf("DeleteReview: %v", err)
+		}
+		if _, err := storage.GetReviewById(ctx, review.ID); !errors.Is(err, reviewapi.ErrNotFound) {
+			t.Fatalf("GetReviewById after delete: got %v, want ErrNotFound", err)
+		}
+		if err := storage.DeleteReview(ctx, review.ID); !errors.Is(err, reviewapi.ErrNotFound) {
+			t.Fatalf("second DeleteReview: got %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("Trash", func(t *testing.T) {
+		storage := newStorage(t)
+		trash, ok := storage.(reviewapi.TrashStore)
+		if !ok {
+			t.Skip("storage does not implement TrashStore")
+		}
+		ctx := context.Background()
+
+		kept := testReview("Heat")
+		review := testReview("Ronin")
+		for _, r := range []*reviewapi.Review{kept, review} {
+			if _, err := storage.CreateReview(ctx, r); err != nil {
+				t.Fatalf("CreateReview: %v", err)
+			}
+		}
+		if err := storage.DeleteReview(ctx, review.ID); err != nil {
+			t.Fatalf("DeleteReview: %v", err)
+		}
+
+		// The deleted review is listed, and only it
+		deleted, err := trash.ListDeletedReviews(ctx, 10)
+		if err != nil {
+			t.Fatalf("ListDeletedReviews: %v", err)
+		}
+		if len(deleted) != 1 || deleted[0].ID != review.ID || deleted[0].DeletedAt == nil {
+			t.Fatalf("ListDeletedReviews = %+v, want review %d with DeletedAt set", deleted, review.ID)
+		}
+
+		// It is hidden from the other operations
+		updated := *review
+		updated.Rating = "1/10"
+		if err := storage.UpdateReview(ctx, &updated); !errors.Is(err, reviewapi.ErrNotFound) {
+			t.Fatalf("UpdateReview in trash: got %v, want ErrNotFound", err)
+		}
+		if _, err := trash.RestoreReview(ctx, kept.ID); !errors.Is(err, reviewapi.ErrNotFound) {
+			t.Fatalf("RestoreReview of a live review: got %v, want ErrNotFound", err)
+		}
+
+		// Restoring brings it back unchanged, with DeletedAt cleared
+		restored, err := trash.RestoreReview(ctx, review.ID)
+		if err != nil {
+			t.Fatalf("RestoreReview: %v", err)
+		}
+		if restored.DeletedAt != nil || restored.Title != review.Title || restored.Rating != review.Rating {
+			t.Fatalf("RestoreReview = %+v, want %+v without DeletedAt", *restored, *review)
+		}
+		got, err := storage.GetReviewById(ctx, review.ID)
+		if err != nil {
+			t.Fatalf("GetReviewById after restore: %v", err)
+		}
+		assertSameReview(t, got, restored)
+		if _, err := trash.RestoreReview(ctx, review.ID); !errors.Is(err, reviewapi.ErrNotFound) {
+			t.Fatalf("second RestoreReview: got %v, want ErrNotFound", err)
+		}
+		if deleted, err := trash.ListDeletedReviews(ctx, 10); err != nil || len(deleted) != 0 {
+			t.Fatalf("ListDeletedReviews after restore = %v, %v; want empty", deleted, err)
+		}
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		storage := newStorage(t)
+		trash, ok := storage.(reviewapi.TrashStore)
+		if !ok {
+			t.Skip("storage does not implement TrashStore")
+		}
+		ctx := context.Background()
+
+		kept := testReview("Heat")
+		review := testReview("Ronin")
+		for _, r := range []*reviewapi.Review{kept, review} {
+			if _, err := storage.CreateReview(ctx, r); err != nil {
+				t.Fatalf("CreateReview: %v", err)
+			}
+		}
+		if err := storage.DeleteReview(ctx, review.ID); err != nil {
+			t.Fatalf("DeleteReview: %v", err)
+		}
+
+		// The database clock may differ slightly from the test's
+		const skew = 5 * time.Second
+		if purged, err := trash.PurgeDeletedReviews(ctx, time.Now().Add(-time.Hour)); err != nil || purged != 0 {
+			t.Fatalf("PurgeDeletedReviews before deletion = %d, %v; want 0", purged, err)
+		}
+		purged, err := trash.PurgeDeletedReviews(ctx, time.Now().Add(skew))
+		if err != nil || purged != 1 {
+			t.Fatalf("PurgeDeletedReviews after deletion = %d, %v; want 1", purged, err)
+		}
+		if _, err := trash.RestoreReview(ctx, review.ID); !errors.Is(err, reviewapi.ErrNotFound) {
+			t.Fatalf("RestoreReview after purge: got %v, want ErrNotFound", err)
+		}
+		if _, err := storage.GetReviewById(ctx, kept.ID); err != nil {
+			t.Fatalf("GetReviewById of a live review after purge: %v", err)
+		}
+	})
+
+	t.Run("Revisions", func(t *testing.T) {
+		storage := newStorage(t)
+		history, ok := storage.(reviewapi.RevisionStore)
+		if !ok {
+			t.Skip("storage does not implement RevisionStore")
+		}
+		ctx := context.Background()
+
+		review := testReview("Solaris")
+		if _, err := storage.CreateReview(ctx, review); err != nil {
+			t.Fatalf("CreateReview: %v", err)
+		}
+
+		// An update changing the rating records revision 2 by its actor
+		updated := *review
+		updated.Rating = "10/10"
+		if err := storage.UpdateReview(reviewapi.ContextWithActor(ctx, "alice"), &updated); err != nil {
+			t.Fatalf("UpdateReview: %v", err)
+		}
+		// An update changing nothing records no revision
+		unchanged := updated
+		if err := storage.UpdateReview(ctx, &unchanged); err != nil {
+			t.Fatalf("UpdateReview without changes: %v", err)
+		}
+
+		revisions, err := history.ListRevisions(ctx, review.ID)
+		if err != nil {
+			t.Fatalf("ListRevisions: %v", err)
+		}
+		if len(revisions) != 2 {
+			t.Fatalf("ListRevisions returned %d revisions, want 2", len(revisions))
+		}
+		latest, first := revisions[0], revisions[1]
+		if first.Revision != 1 || first.Author != reviewapi.AnonymousActor || first.Rating != review.Rating ||
+			fmt.Sprint(first.ChangedFields) != fmt.Sprint(reviewapi.ReviewFields) || !sameInstant(first.CreatedAt, review.UpdatedAt) {
+			t.Fatalf("first revision = %+v, want revision 1 of %+v by %s", *first, *review, reviewapi.AnonymousActor)
+		}
+		if latest.Revision != 2 || latest.Author != "alice" || latest.Rating != "10/10" ||
+			fmt.Sprint(latest.ChangedFields) != "[rating]" || !sameInstant(latest.CreatedAt, updated.UpdatedAt) {
+			t.Fatalf("latest revision = %+v, want revision 2 changing the rating by alice", *latest)
+		}
+
+		got, err := history.GetRevision(ctx, review.ID, 1)
+		if err != nil {
+			t.Fatalf("GetRevision(1): %v", err)
+		}
+		if got.Title != review.Title || got.Rating != review.Rating {
+			t.Fatalf("GetRevision(1) = %+v, want the created content", *got)
+		}
+		if _, err := history.GetRevision(ctx, review.ID, 3); !errors.Is(err, reviewapi.ErrNotFound) {
+			t.Fatalf("GetRevision(3): got %v, want ErrNotFound", err)
+		}
+
+		// The history is hidden with the review
+		if err := storage.DeleteReview(ctx, review.ID); err != nil {
+			t.Fatalf("DeleteReview: %v", err)
+		}
+		if _, err := history.ListRevisions(ctx, review.ID); !errors.Is(err, reviewapi.ErrNotFound) {
+			t.Fatalf("ListRevisions in trash: got %v, want ErrNotFound", err)
+		}
+		if _, err := history.GetRevision(ctx, review.ID, 1); !errors.Is(err, reviewapi.ErrNotFound) {
+			t.Fatalf("GetRevision in trash: got %v, want ErrNotFound", err)
+		}
+		if _, err := history.ListRevisions(ctx, review.ID+1000); !errors.Is(err, reviewapi.ErrNotFound) {
+			t.Fatalf("ListRevisions of a missing review: got %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("Revert", func(t *testing.T) {
+		storage := newStorage(t)
+		history, ok := storage.(reviewapi.RevisionStore)
+		if !ok {
+			t.Skip("storage does not implement RevisionStore")
+		}
+		ctx := context.Background()
+
+		review := testReview("Solaris")
+		if _, err := storage.CreateReview(ctx, review); err != nil {
+			t.Fatalf("CreateReview: %v", err)
+		}
+		updated := *review
+		updated.Rating = "10/10"
+		if err := storage.UpdateReview(ctx, &updated); err != nil {
+			t.Fatalf("UpdateReview: %v", err)
+		}
+		// Read it once, so that a cache in front holds the updated version
+		if _, err := storage.GetReviewById(ctx, review.ID); err != nil {
+			t.Fatalf("GetReviewById: %v", err)
+		}
+
+		// Reverting to revision 1 writes its content back as revision 3
+		reverted, err := history.RevertReview(reviewapi.ContextWithActor(ctx, "bob"), review.ID, 1)
+		if err != nil {
+			t.Fatalf("RevertReview: %v", err)
+		}
+		if reverted.ID != review.ID || reverted.Rating != review.Rating || reverted.DateCreated == "" ||
+			!reverted.UpdatedAt.After(updated.UpdatedAt) {
+			t.Fatalf("RevertReview = %+v, want the created content with a later UpdatedAt", *reverted)
+		}
+		got, err := storage.GetReviewById(ctx, review.ID)
+		if err != nil {
+			t.Fatalf("GetReviewById after revert: %v", err)
+		}
+		assertSameReview(t, got, reverted)
+		revisions, err := history.ListRevisions(ctx, review.ID)
+		if err != nil || len(revisions) != 3 {
+			t.Fatalf("ListRevisions = %d revisions, %v; want 3", len(revisions), err)
+		}
+		if latest := revisions[0]; latest.Revision != 3 || latest.Author != "bob" || latest.Rating != review.Rating ||
+			fmt.Sprint(latest.ChangedFields) != "[rating]" {
+			t.Fatalf("latest revision = %+v, want revision 3 restoring the rating by bob", *latest)
+		}
+		if audit, ok := storage.(reviewapi.AuditStore); ok {
+			entries, err := audit.ListAuditEntries(ctx, reviewapi.AuditQuery{Action: reviewapi.AuditRevert, Limit: 10})
+			if err != nil || len(entries) != 1 || entries[0].Actor != "bob" || entries[0].ReviewID != review.ID {
+				t.Fatalf("revert audit entries = %v, %v; want one by bob", entries, err)
+			}
+		}
+
+		// A missing revision, or a review in the trash, changes nothing
+		if _, err := history.RevertReview(ctx, review.ID, 9); !errors.Is(err, reviewapi.ErrNotFound) {
+			t.Fatalf("RevertReview(9): got %v, want ErrNotFound", err)
+		}
+		if err := storage.DeleteReview(ctx, review.ID); err != nil {
+			t.Fatalf("DeleteReview: %v", err)
+		}
+		if _, err := history.RevertReview(ctx, review.ID, 2); !errors.Is(err, reviewapi.ErrNotFound) {
+			t.Fatalf("RevertReview in trash: got %v, want ErrNotFound", err)
+		}
+		if _, err := history.RevertReview(ctx, review.ID+1000, 1); !errors.Is(err, reviewapi.ErrNotFound) {
+			t.Fatalf("RevertReview of a missing review: got %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("Audit", func(t *testing.T) {
+		storage := newStorage(t)
+		trash, ok := storage.(reviewapi.TrashStore)
+		if !ok {
+			t.Skip("storage does not implement TrashStore")
+		}
+		audit, ok := storage.(reviewapi.AuditStore)
+		if !ok {
+			t.Skip("storage does not implement AuditStore")
+		}
+		request := func(actor string) context.Context {
+			ctx := reviewapi.ContextWithActor(context.Background(), actor)
+			ctx = reviewapi.ContextWithRequestID(ctx, actor+"-request")
+			ctx = reviewapi.ContextWithClaimedActor(ctx, actor+"-claimed")
+			return reviewapi.ContextWithClientIP(ctx, "203.0.113.7")
+		}
+
+		// The database clock may differ slightly from the test's
+		const skew = 5 * time.Second
+		start := time.Now()
+
+		review := testReview("Stalker")
+		if _, err := storage.CreateReview(request("alice"), review); err != nil {
+			t.Fatalf("CreateReview: %v", err)
+		}
+		updated := *review
+		updated.Rating = "10/10"
+		if err := storage.UpdateReview(request("bob"), &updated); err != nil {
+			t.Fatalf("UpdateReview: %v", err)
+		}
+		missing := updated
+		missing.ID += 1000
+		if err := storage.UpdateReview(request("bob"), &missing); !errors.Is(err, reviewapi.ErrNotFound) {
+			t.Fatalf("UpdateReview of a missing review: got %v, want ErrNotFound", err)
+		}
+		if err := storage.DeleteReview(request("alice"), review.ID); err != nil {
+			t.Fatalf("DeleteReview: %v", err)
+		}
+		if _, err := trash.RestoreReview(request("alice"), review.ID); err != nil {
+			t.Fatalf("RestoreReview: %v", err)
+		}
+		if err := storage.DeleteReview(request("alice"), review.ID); err != nil {
+			t.Fatalf("DeleteReview: %v", err)
+		}
+		if _, err := trash.PurgeDeletedReviews(reviewapi.ContextWithActor(context.Background(), reviewapi.SystemActor), time.Now().Add(skew)); err != nil {
+			t.Fatalf("PurgeDeletedReviews: %v", err)
+		}
+
+		ctx := context.Background()
+		entries, err := audit.ListAuditEntries(ctx, reviewapi.AuditQuery{Limit: 100})
+		if err != nil {
+			t.Fatalf("ListAuditEntries: %v", err)
+		}
+		var actions []string
+		for _, entry := range entries {
+			actions = append(actions, entry.Action+" by "+entry.Actor)
+			if entry.ReviewID != review.ID {
+				t.Fatalf("audit entry %+v is not about review %d", *entry, review.ID)
+			}
+			if entry.OccurredAt.Before(start.Add(-skew)) || entry.OccurredAt.After(time.Now().Add(skew)) {
+				t.Fatalf("audit entry %d occurred at %v, want about %v", entry.ID, entry.OccurredAt, start)
+			}
+		}
+		want := "[purge by system delete by alice restore by alice delete by alice update by bob create by alice]"
+		if fmt.Sprint(actions) != want {
+			t.Fatalf("audit entries %v, want %s", actions, want)
+		}
+
+		// The update records who made it from where, and both versions
+		update := entries[4]
+		if update.RequestID != "bob-request" || update.ClientIP != "203.0.113.7" || update.ClaimedActor != "bob-claimed" {
+			t.Fatalf("update entry = %+v, want bob's request ID, client IP and claimed actor", *update)
+		}
+		before, after := auditedReview(t, update.Before), auditedReview(t, update.After)
+		if before == nil || after == nil || before.Rating != review.Rating || after.Rating != "10/10" || after.Title != review.Title {
+			t.Fatalf("update entry before %s after %s, want the rating change", update.Before, update.After)
+		}
+		if create := entries[5]; auditedReview(t, create.Before) != nil || auditedReview(t, create.After) == nil {
+			t.Fatalf("create entry before %s after %s, want only after", create.Before, create.After)
+		}
+		if purge := entries[0]; auditedReview(t, purge.Before) == nil || auditedReview(t, purge.After) != nil || purge.RequestID != "" {
+			t.Fatalf("purge entry = %+v, want only before and no request", *purge)
+		}
+
+		// Filters select by actor, action, time range and position
+		for _, tc := range []struct {
+			name  string
+			query reviewapi.AuditQuery
+			want  []*reviewapi.AuditEntry
+		}{
+			{"actor", reviewapi.AuditQuery{Actor: "bob", Limit: 100}, entries[4:5]},
+			{"action", reviewapi.AuditQuery{Action: reviewapi.AuditDelete, Limit: 100}, []*reviewapi.AuditEntry{entries[1], entries[3]}},
+			{"since", reviewapi.AuditQuery{Since: start.Add(-skew), Limit: 100}, entries},
+			{"future", reviewapi.AuditQuery{Since: time.Now().Add(time.Hour), Limit: 100}, nil},
+			{"until", reviewapi.AuditQuery{Until: start.Add(-time.Hour), Limit: 100}, nil},
+			{"page", reviewapi.AuditQuery{BeforeID: entries[1].ID, Limit: 2}, entries[2:4]},
+		} {
+			got, err := audit.ListAuditEntries(ctx, tc.query)
+			if err != nil {
+				t.Fatalf("ListAuditEntries by %s: %v", tc.name, err)
+			}
+			if got == nil || len(got) != len(tc.want) {
+				t.Fatalf("ListAuditEntries by %s returned %d entries, want %d", tc.name, len(got), len(tc.want))
+			}
+			for i := range got {
+				if got[i].ID != tc.want[i].ID {
+					t.Fatalf("ListAuditEntries by %s returned entry %d, want %d", tc.name, got[i].ID, tc.want[i].ID)
+				}
+			}
+		}
+	})
+
+	t.Run("Outbox", func(t *testing.T) {
+		storage := newStorage(t)
+		outbox, ok := storage.(reviewapi.WebhookOutbox)
+		if !ok {
+			t.Skip("storage does not implement WebhookOutbox")
+		}
+		trash, ok := storage.(reviewapi.TrashStore)
+		if !ok {
+			t.Skip("storage does not implement TrashStore")
+		}
+		webhooks, ok := storage.(reviewapi.WebhookAdmin)
+		if !ok {
+			t.Skip("storage does not implement WebhookAdmin")
+		}
+		ctx := context.Background()
+
+		review := testReview("Solaris")
+		if _, err := storage.CreateReview(ctx, review); err != nil {
+			t.Fatalf("CreateReview: %v", err)
+		}
+		updated := *review
+		updated.Rating = "10/10"
+		if err := storage.UpdateReview(ctx, &updated); err != nil {
+			t.Fatalf("UpdateReview: %v", err)
+		}
+		if err := storage.DeleteReview(ctx, review.ID); err != nil {
+			t.Fatalf("DeleteReview: %v", err)
+		}
+		if _, err := trash.RestoreReview(ctx, review.ID); err != nil {
+			t.Fatalf("RestoreReview: %v", err)
+		}
+
+		// Every event is fanned out to both webhooks, in the order of the changes
+		deliveries, err := outbox.ClaimWebhookDeliveries(ctx, []string{"a", "b"}, 100, time.Hour)
+		if err != nil {
+			t.Fatalf("ClaimWebhookDeliveries: %v", err)
+		}
+		events := map[string][]string{}
+		for _, delivery := range deliveries {
+			if delivery.Status != reviewapi.DeliveryPending || delivery.Attempts != 0 || delivery.Event.ReviewID != review.ID {
+				t.Fatalf("claimed delivery %+v, want a first attempt for review %d", *delivery, review.ID)
+			}
+			events[delivery.Webhook] = append(events[delivery.Webhook], delivery.Event.Type)
+			payload := auditedReview(t, delivery.Event.Review)
+			if payload == nil || payload.ID != review.ID || payload.Title != review.Title {
+				t.Fatalf("event %d payload %s, want review %d", delivery.Event.ID, delivery.Event.Review, review.ID)
+			}
+			if delivery.Event.Type == reviewapi.EventReviewUpdated && payload.Rating != "10/10" {
+				t.Fatalf("update event payload %s, want the new rating", delivery.Event.Review)
+			}
+		}
+		want := "map[a:[review.created review.updated review.deleted review.restored] b:[review.created review.updated review.deleted review.restored]]"
+		if fmt.Sprint(events) != want {
+			t.Fatalf("claimed events %v, want %s", events, want)
+		}
+
+		// Claimed deliveries are leased
+		if again, err := outbox.ClaimWebhookDeliveries(ctx, []string{"a", "b"}, 100, time.Hour); err != nil || len(again) != 0 {
+			t.Fatalf("ClaimWebhookDeliveries while leased = %d deliveries, %v; want none", len(again), err)
+		}
+
+		// A failed attempt is retried once due
+		retried, dead := deliveries[0], deliveries[1]
+		if err := outbox.CompleteWebhookDelivery(ctx, retried.ID, reviewapi.WebhookResult{Status: reviewapi.DeliveryPending, Error: "boom"}); err != nil {
+			t.Fatalf("CompleteWebhookDelivery: %v", err)
+		}
+		again, err := outbox.ClaimWebhookDeliveries(ctx, []string{"a", "b"}, 100, time.Hour)
+		if err != nil || len(again) != 1 || again[0].ID != retried.ID || again[0].Attempts != 1 || again[0].LastError != "boom" {
+			t.Fatalf("ClaimWebhookDeliveries after a failure = %v, %v; want delivery %d on its second attempt", again, err, retried.ID)
+		}
+
+		// A delivery out of attempts is dead until retried
+		if err := outbox.CompleteWebhookDelivery(ctx, dead.ID, reviewapi.WebhookResult{Status: reviewapi.DeliveryDead, Error: "gone"}); err != nil {
+			t.Fatalf("CompleteWebhookDelivery: %v", err)
+		}
+		letters, err := webhooks.ListDeadWebhookDeliveries(ctx, 10)
+		if err != nil || len(letters) != 1 || letters[0].ID != dead.ID || letters[0].Status != reviewapi.DeliveryDead || letters[0].LastError != "gone" {
+			t.Fatalf("ListDeadWebhookDeliveries = %v, %v; want delivery %d", letters, err, dead.ID)
+		}
+		pending, err := webhooks.RetryWebhookDelivery(ctx, dead.ID)
+		if err != nil || pending.Status != reviewapi.DeliveryPending || pending.Attempts != 0 || pending.Event.ID != dead.Event.ID {
+			t.Fatalf("RetryWebhookDelivery = %+v, %v; want a pending delivery of event %d", pending, err, dead.Event.ID)
+		}
+		if _, err := webhooks.RetryWebhookDelivery(ctx, dead.ID); !errors.Is(err, reviewapi.ErrNotFound) {
+			t.Fatalf("RetryWebhookDelivery of a pending delivery: got %v, want ErrNotFound", err)
+		}
+
+		// Events are pruned once all their deliveries are delivered
+		const skew = 5 * time.Second
+		if pruned, err := outbox.PruneOutbox(ctx, time.Now().Add(skew)); err != nil || pruned != 0 {
+			t.Fatalf("PruneOutbox before delivery = %d, %v; want 0", pruned, err)
+		}
+		for _, delivery := range deliveries {
+			if err := outbox.CompleteWebhookDelivery(ctx, delivery.ID, reviewapi.WebhookResult{Status: reviewapi.DeliveryDelivered}); err != nil {
+				t.Fatalf("CompleteWebhookDelivery: %v", err)
+			}
+		}
+		if pruned, err := outbox.PruneOutbox(ctx, time.Now().Add(-time.Hour)); err != nil || pruned != 0 {
+			t.Fatalf("PruneOutbox of older events = %d, %v; want 0", pruned, err)
+		}
+		if pruned, err := outbox.PruneOutbox(ctx, time.Now().Add(skew)); err != nil || pruned != 4 {
+			t.Fatalf("PruneOutbox = %d, %v; want 4", pruned, err)
+		}
+
+		// Resuming streams learn that the pruned events are gone
+		if eventLog, ok := storage.(reviewapi.EventLog); ok {
+			var newest int64
+			for _, delivery := range deliveries {
+				newest = max(newest, delivery.Event.ID)
+			}
+			if oldest, err := eventLog.OldestReviewEventID(ctx); err != nil || oldest <= newest {
+				t.Fatalf("OldestReviewEventID after pruning = %d, %v; want more than %d", oldest, err, newest)
+			}
+		}
+	})
+
+	t.Run("Events", func(t *testing.T) {
+		storage := newStorage(t)
+		eventLog, ok := storage.(reviewapi.EventLog)
+		if !ok {
+			t.Skip("storage does not implement EventLog")
+		}
+		ctx := context.Background()
+
+		// Events written before the feed starts are only replayed
+		first := testReview("Stalker")
+		if _, err := storage.CreateReview(ctx, first); err != nil {
+			t.Fatalf("CreateReview: %v", err)
+		}
+		events, err := eventLog.ListReviewEvents(ctx, 0, 100)
+		if err != nil || len(events) == 0 {
+			t.Fatalf("ListReviewEvents = %v, %v; want the creation event", events, err)
+		}
+		created := events[len(events)-1]
+		if created.Type != reviewapi.EventReviewCreated || created.ReviewID != first.ID || len(created.Review) == 0 {
+			t.Fatalf("last event %+v, want the creation of review %d", *created, first.ID)
+		}
+		if after, err := eventLog.ListReviewEvents(ctx, created.ID, 100); err != nil || len(after) != 0 {
+			t.Fatalf("ListReviewEvents after the last event = %v, %v; want none", after, err)
+		}
+		if oldest, err := eventLog.OldestReviewEventID(ctx); err != nil || oldest != events[0].ID {
+			t.Fatalf("OldestReviewEventID = %d, %v; want %d", oldest, err, events[0].ID)
+		}
+
+		source, ok := storage.(reviewapi.ReviewEventSource)
+		if !ok {
+			t.Skip("storage does not implement ReviewEventSource")
+		}
+		broadcaster := reviewapi.NewEventBroadcaster(16, nil)
+		subscription := broadcaster.Subscribe()
+		defer subscription.Close()
+		feedCtx, cancel := context.WithCancel(ctx)
+		feedErr := make(chan error, 1)
+		go func() {
+			_, err := source.PublishReviewEvents(feedCtx, broadcaster, reviewapi.EventsFromNow)
+			feedErr <- err
+		}()
+		defer func() {
+			cancel()
+			if err := <-feedErr; err != nil {
+				t.Errorf("PublishReviewEvents: %v", err)
+			}
+		}()
+
+		// Changes are published in order until the feed is cancelled. The
+		// feed may start after the first change, so retry it until seen
+		var got []string
+		deadline := time.After(5 * time.Second)
+		for attempt := 0; len(got) == 0; attempt++ {
+			updated := *first
+			updated.Rating = fmt.Sprintf("%d/10", attempt%10+1)
+			if err := storage.UpdateReview(ctx, &updated); err != nil {
+				t.Fatalf("UpdateReview: %v", err)
+			}
+			select {
+			case event := <-subscription.Events():
+				if event.ID <= created.ID || event.Type != reviewapi.EventReviewUpdated || event.ReviewID != first.ID {
+					t.Fatalf("published %+v, want a later update of review %d", *event, first.ID)
+				}
+				got = append(got, event.Type)
+			case <-time.After(100 * time.Millisecond):
+			case <-deadline:
+				t.Fatalf("no event published")
+			}
+		}
+		if err := storage.DeleteReview(ctx, first.ID); err != nil {
+			t.Fatalf("DeleteReview: %v", err)
+		}
+		for {
+			select {
+			case event := <-subscription.Events():
+				if event.Type == reviewapi.EventReviewDeleted {
+					return
+				}
+			case <-deadline:
+				t.Fatalf("deletion not published")
+			}
+		}
+	})
+
+	t.Run("EventsResume", func(t *testing.T) {
+		storage := newStorage(t)
+		eventLog, ok := storage.(reviewapi.EventLog)
+		if !ok {
+			t.Skip("storage does not implement EventLog")
+		}
+		source, ok := storage.(reviewapi.ReviewEventSource)
+		if !ok {
+			t.Skip("storage does not implement ReviewEventSource")
+		}
+		ctx := context.Background()
+		broadcaster := reviewapi.NewEventBroadcaster(16, nil)
+		subscription := broadcaster.Subscribe()
+		defer subscription.Close()
+
+		review := testReview("Ivan's Childhood")
+		if _, err := storage.CreateReview(ctx, review); err != nil {
+			t.Fatalf("CreateReview: %v", err)
+		}
+		events, err := eventLog.ListReviewEvents(ctx, 0, 100)
+		if err != nil || len(events) == 0 {
+			t.Fatalf("ListReviewEvents = %v, %v; want the creation event", events, err)
+		}
+		created := events[len(events)-1].ID
+
+		// A feed resuming after an event publishes everything written
+		// since, including the changes made while no feed was running
+		if err := storage.DeleteReview(ctx, review.ID); err != nil {
+			t.Fatalf("DeleteReview: %v", err)
+		}
+		feedCtx, cancel := context.WithCancel(ctx)
+		feedResult := make(chan int64, 1)
+		go func() {
+			lastID, err := source.PublishReviewEvents(feedCtx, broadcaster, created-1)
+			if err != nil {
+				t.Errorf("PublishReviewEvents: %v", err)
+			}
+			feedResult <- lastID
+		}()
+		var got []string
+		for len(got) < 2 {
+			select {
+			case event := <-subscription.Events():
+				got = append(got, event.Type)
+			case <-time.After(5 * time.Second):
+				cancel()
+				t.Fatalf("published %v, want the creation and the deletion", got)
+			}
+		}
+		cancel()
+		if want := "[review.created review.deleted]"; fmt.Sprint(got) != want {
+			t.Fatalf("published %v, want %s", got, want)
+		}
+		if lastID := <-feedResult; lastID <= created {
+			t.Fatalf("PublishReviewEvents returned %d, want the deletion event after %d", lastID, created)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		storage := newStorage(t)
+		ctx := context.Background()
+		const missing = 987654
+
+		if _, err := storage.GetReviewById(ctx, missing); !errors.Is(err, reviewapi.ErrNotFound) {
+			t.Errorf("GetReviewById: got %v, want ErrNotFound", err)
+		}
+		review := testReview("Nowhere")
+		review.ID = missing
+		if err := storage.UpdateReview(ctx, review); !errors.Is(err, reviewapi.ErrNotFound) {
+			t.Errorf("UpdateReview: got %v, want ErrNotFound", err)
+		}
+		if err := storage.DeleteReview(ctx, missing); !errors.Is(err, reviewapi.ErrNotFound) {
+			t.Errorf("DeleteReview: got %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("ConcurrentCreates", func(t *testing.T) {
+		storage := newStorage(t)
+		ctx := context.Background()
+		const creators = 20
+
+		reviews := make([]*reviewapi.Review, creators)
+		errs := make([]error, creators)
+		var wg sync.WaitGroup
+		for i := range reviews {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				reviews[i] = testReview(fmt.Sprintf("Concurrent %d", i))
+				_, errs[i] = storage.CreateReview(ctx, reviews[i])
+			}()
+		}
+		wg.Wait()
+
+		seen := make(map[int]bool)
+		for i, review := range reviews {
+			if errs[i] != nil {
+				t.Fatalf("CreateReview %d: %v", i, errs[i])
+			}
+			if seen[review.ID] {
+				t.Fatalf("ID %d assigned twice", review.ID)
+			}
+			seen[review.ID] = true
+
+			got, err := storage.GetReviewById(ctx, review.ID)
+			if err != nil {
+				t.Fatalf("GetReviewById(%d): %v", review.ID, err)
+			}
+			assertSameReview(t, got, review)
+		}
+	})
+
+	t.Run("ContextHonored", func(t *testing.T) {
+		storage := newStorage(t)
+
+		existing := testReview("Memento")
+		if _, err := storage.CreateReview(context.Background(), existing); err != nil {
+			t.Fatalf("CreateReview: %v", err)
+		}
+
+		canceled, cancel := context.WithCancel(context.Background())
+		cancel()
+		expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancelExpired()
+
+		for _, tc := range []struct {
+			name string
+			ctx  context.Context
+			want error
+		}{
+			{"canceled", canceled, reviewapi.ErrCanceled},
+			{"expired", expired, reviewapi.ErrTimeout},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				if _, err := storage.CreateReview(tc.ctx, testReview("Never Created")); !errors.Is(err, tc.want) {
+					t.Errorf("CreateReview: got %v, want %v", err, tc.want)
+				}
+				if _, err := storage.GetReviewById(tc.ctx, existing.ID); !errors.Is(err, tc.want) {
+					t.Errorf("GetReviewById: got %v, want %v", err, tc.want)
+				}
+
+				changed := *existing
+				changed.Title = "Never Updated"
+				if err := storage.UpdateReview(tc.ctx, &changed); !errors.Is(err, tc.want) {
+					t.Errorf("UpdateReview: got %v, want %v", err, tc.want)
+				}
+				if err := storage.DeleteReview(tc.ctx, existing.ID); !errors.Is(err, tc.want) {
+					t.Errorf("DeleteReview: got %v, want %v", err, tc.want)
+				}
+
+				// None of the writes may have been applied
+				got, err := storage.GetReviewById(context.Background(), existing.ID)
+				if err != nil {
+					t.Fatalf("GetReviewById(%d): %v", existing.ID, err)
+				}
+				assertSameReview(t, got, existing)
+			})
+		}
+	})
+
+	t.Run("Timestamps", func(t *testing.T) {
+		storage := newStorage(t)
+		ctx := context.Background()
+
+		// The database clock may differ slightly from the test's
+		const skew = 5 * time.Second
+		before := time.Now()
+		review := testReview("Arrival")
+		if _, err := storage.CreateReview(ctx, review); err != nil {
+			t.Fatalf("CreateReview: %v", err)
+		}
+		if review.UpdatedAt.Before(before.Add(-skew)) || review.UpdatedAt.After(time.Now().Add(skew)) {
+			t.Fatalf("CreateReview set UpdatedAt %v, want about %v", review.UpdatedAt, before)
+		}
+		if review.DateCreated == "" {
+			t.Fatalf("DateCreated was not kept")
+		}
+
+		got, err := storage.GetReviewById(ctx, review.ID)
+		if err != nil {
+			t.Fatalf("GetReviewById(%d): %v", review.ID, err)
+		}
+		if !sameInstant(got.UpdatedAt, review.UpdatedAt) {
+			t.Fatalf("GetReviewById UpdatedAt %v, want %v as returned by CreateReview", got.UpdatedAt, review.UpdatedAt)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		updated := *got
+		updated.Rating = "8/10"
+		if err := storage.UpdateReview(ctx, &updated); err != nil {
+			t.Fatalf("UpdateReview: %v", err)
+		}
+		if !updated.UpdatedAt.After(review.UpdatedAt) {
+			t.Fatalf("UpdateReview set UpdatedAt %v, want after %v", updated.UpdatedAt, review.UpdatedAt)
+		}
+
+		got, err = storage.GetReviewById(ctx, review.ID)
+		if err != nil {
+			t.Fatalf("GetReviewById(%d): %v", review.ID, err)
+		}
+		if !sameInstant(got.UpdatedAt, updated.UpdatedAt) {
+			t.Fatalf("GetReviewById UpdatedAt %v, want %v as returned by UpdateReview", got.UpdatedAt, updated.UpdatedAt)
+		}
+		if got.DateCreated != review.DateCreated {
+			t.Fatalf("UpdateReview changed DateCreated from %q to %q", review.DateCreated, got.DateCreated)
+		}
+	})
+}
+
+// testReview returns a review with every field set, ready to be created.
+func testReview(title string) *reviewapi.Review {
+	return &reviewapi.Review{
+		Title:       title,
+		Director:    "Test Director",
+		ReleaseDate: "16 Jul 10 00:00 UTC",
+		Rating:      "9/10",
+		ReviewNotes: "Notes for " + title,
+		DateCreated: "15 Jan 26 10:30 UTC",
+	}
+}
+
+// auditedReview decodes the before or after image of an audit entry, or
+// returns nil for a null image.
+func auditedReview(t *testing.T, data json.RawMessage) *reviewapi.Review {
+	t.Helper()
+	var review *reviewapi.Review
+	if data == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, &review); err != nil {
+		t.Fatalf("decode audited review %s: %v", data, err)
+	}
+	return review
+}
+
+// sameInstant compares timestamps at PostgreSQL's microsecond precision.
+func sameInstant(a, b time.Time) bool {
+	return a.Truncate(time.Microsecond).Equal(b.Truncate(time.Microsecond))
+}
+
+// assertSameReview fails the test unless got holds the fields of want.
+func assertSameReview(t *testing.T, got, want *reviewapi.Review) {
+	t.Helper()
+	if got.ID != want.ID || got.Title != want.Title || got.Director != want.Director ||
+		got.ReleaseDate != want.ReleaseDate || got.Rating != want.Rating ||
+		got.ReviewNotes != want.ReviewNotes || got.DateCreated != want.DateCreated ||
+		!sameInstant(got.UpdatedAt, want.UpdatedAt) {
+		t.Fatalf("review mismatch:\n got  %+v\n want %+v", *got, *want)
+	}
+}
@@ -0,0 +1,210 @@
+// Package reviewapi provides TLS support for the Movie Review API.
+// This file implements HTTPS serving from certificate/key files, automatic
+// certificate reloading when the files change on disk, minimum protocol
+// version enforcement and optional mutual TLS (client certificate) verification.
+package reviewapi
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// certReloadInterval is the minimum time between checks of the certificate
+// and key files for modifications. Checks happen lazily during TLS handshakes,
+// so an idle server performs no file system access.
+const certReloadInterval = 5 * time.Second
+
+// TLSConfig describes how the HTTP server should terminate TLS.
+// When CertFile and KeyFile are both empty the server serves plain HTTP.
+type TLSConfig struct {
+	// CertFile is the path to the PEM encoded server certificate (chain).
+	CertFile string `yaml:"cert_file"`
+
+	// KeyFile is the path to the PEM encoded private key for CertFile.
+	KeyFile string `yaml:"key_file"`
+
+	// MinVersion is the lowest TLS version accepted ("1.2" or "1.3").
+	MinVersion string `yaml:"min_version"`
+
+	// ClientCAFile is the path to a PEM bundle of CAs used to verify
+	// client certificates. Setting it enables mutual TLS.
+	ClientCAFile string `yaml:"client_ca_file"`
+
+	// RequireClientCert rejects handshakes without a valid client certificate.
+	// When false and ClientCAFile is set, client certificates are verified
+	// if presented but are not mandatory. Changes made without one are
+	// attributed to the "anonymous" actor in revisions and the audit log.
+	RequireClientCert bool `yaml:"require_client_cert"`
+}
+
+// Enabled reports whether the server should serve HTTPS.
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+// parseTLSVersion converts a human readable TLS version into its crypto/tls
+// constant. Versions below 1.2 are rejected as they are considered insecure.
+func parseTLSVersion(version string) (uint16, error) {
+	switch strings.TrimPrefix(strings.ToLower(strings.TrimSpace(version)), "tls") {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS minimum version %q (use 1.2 or 1.3)", version)
+	}
+}
+
+// Build validates the configuration and creates a *tls.Config for the server.
+// The returned config serves the certificate through a reloader, so replacing
+// the certificate and key files on disk takes effect without a restart.
+//
+// Parameters:
+//   - logger: Logger used to report certificate reloads
+//
+// Returns:
+//   - *tls.Config: The server TLS configuration
+//   - error: Non-nil if the files cannot be loaded or settings are invalid
+func (c TLSConfig) Build(logger *slog.Logger) (*tls.Config, error) {
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, fmt.Errorf("both TLS certificate and key files must be set")
+	}
+
+	minVersion, err := parseTLSVersion(c.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	reloader, err := newCertReloader(c.CertFile, c.KeyFile, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:     minVersion,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	// Enable mutual TLS when a client CA bundle is configured
+	if c.ClientCAFile != "" {
+		pem, err := os.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("client CA file %s contains no certificates", c.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		if c.RequireClientCert {
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	} else if c.RequireClientCert {
+		return nil, fmt.Errorf("requiring client certificates needs a client CA file")
+	}
+
+	return tlsConfig, nil
+}
+
+// certReloader serves a certificate/key pair and transparently reloads it
+// when either file's modification time changes.
+//
+// If a reload fails (e.g. the key was written before the certificate), the
+// previously loaded certificate keeps being served and the reload is retried
+// on the next check.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	logger   *slog.Logger
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	certMod   time.Time
+	keyMod    time.Time
+	lastCheck time.Time
+}
+
+// newCertReloader loads the initial certificate pair.
+// Startup fails if the files cannot be loaded.
+func newCertReloader(certFile, keyFile string, logger *slog.Logger) (*certReloader, error) {
+	reloader := &certReloader{certFile: certFile, keyFile: keyFile, logger: logger}
+	if err := reloader.reload(); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// reload reads the certificate pair from disk and swaps it in.
+func (r *certReloader) reload() error {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return fmt.Errorf("stat TLS certificate: %w", err)
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return fmt.Errorf("stat TLS key: %w", err)
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load TLS key pair: %w", err)
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.certMod = certInfo.ModTime()
+	r.keyMod = keyInfo.ModTime()
+	r.lastCheck = time.Now()
+	r.mu.Unlock()
+	return nil
+}
+
+// changed reports whether the files on disk differ from the loaded pair.
+func (r *certReloader) changed() bool {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return false
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return !certInfo.ModTime().Equal(r.certMod) || !keyInfo.ModTime().Equal(r.keyMod)
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+// At most once per certReloadInterval it checks the files for changes and
+// reloads them before returning the current certificate.
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	due := time.Since(r.lastCheck) >= certReloadInterval
+	r.mu.RUnlock()
+
+	if due {
+		if r.changed() {
+			if err := r.reload(); err != nil {
+				r.logger.Error("reload TLS certificate failed, keeping previous certificate",
+					"cert_file", r.certFile, "error", err)
+			} else {
+				r.logger.Info("reloaded TLS certificate", "cert_file", r.certFile)
+			}
+		}
+		r.mu.Lock()
+		r.lastCheck = time.Now()
+		r.mu.Unlock()
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
@@ -0,0 +1,185 @@
+// Package reviewapi provides OpenTelemetry tracing for the Movie Review API.
+// This file sets up the tracer provider and exporter, and implements the
+// HTTP middleware that starts a server span per request.
+//
+// Trace context is propagated using W3C traceparent/tracestate headers, so
+// requests from instrumented clients join the caller's trace. Storage spans
+// are started by PgDb as children of the request span.
+//
+// Exporters:
+//   - otlp: OTLP over HTTP to a collector (or any stand-in accepting OTLP)
+//   - file: JSON encoded spans appended to a local file, useful for testing
+package reviewapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the spans created by this application.
+const instrumentationName = "github.com/Logan-0/goBackend"
+
+// TracingConfig holds the distributed tracing settings.
+type TracingConfig struct {
+	// Enabled turns on span recording and export.
+	Enabled bool `yaml:"enabled"`
+
+	// Exporter selects where spans are sent: "otlp" or "file".
+	Exporter string `yaml:"exporter"`
+
+	// Endpoint is the OTLP/HTTP collector URL (e.g. "http://localhost:4318").
+	Endpoint string `yaml:"endpoint"`
+
+	// File is the path spans are written to by the file exporter.
+	File string `yaml:"file"`
+
+	// ServiceName is reported as the service.name resource attribute.
+	ServiceName string `yaml:"service_name"`
+
+	// SampleRatio is the fraction of new traces recorded (0 to 1). Requests
+	// that arrive with a sampled parent trace are always recorded.
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// Validate checks the tracing settings.
+func (c TracingConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	switch c.Exporter {
+	case "otlp":
+		if c.Endpoint == "" {
+			return fmt.Errorf("otlp exporter needs an endpoint")
+		}
+	case "file":
+		if c.File == "" {
+			return fmt.Errorf("file exporter needs a file path")
+		}
+	default:
+		return fmt.Errorf("unknown exporter %q (use otlp or file)", c.Exporter)
+	}
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		return fmt.Errorf("sample ratio must be between 0 and 1")
+	}
+	return nil
+}
+
+// SetupTracing installs the global tracer provider and W3C propagator.
+// When tracing is disabled only the propagator is installed, so incoming
+// trace context is still forwarded, and spans are no-ops.
+//
+// Parameters:
+//   - ctx: Context for creating the exporter
+//   - cfg: Tracing settings
+//
+// Returns:
+//   - func(context.Context) error: Flushes pending spans and releases the exporter
+//   - error: Non-nil if the exporter cannot be created
+func SetupTracing(ctx context.Context, cfg TracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	if !cfg.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var exporter sdktrace.SpanExporter
+	var closer io.Closer
+	switch cfg.Exporter {
+	case "otlp":
+		otlpExporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+		if err != nil {
+			return nil, fmt.Errorf("create OTLP exporter: %w", err)
+		}
+		exporter = otlpExporter
+	case "file":
+		file, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("open trace file: %w", err)
+		}
+		fileExporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("create file exporter: %w", err)
+		}
+		exporter, closer = fileExporter, file
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", cfg.Exporter)
+	}
+
+	res, err := resource.Merge(resource.Default(),
+		resource.NewSchemaless(attribute.String("service.name", cfg.ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("create trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	shutdown := func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			closer.Close()
+		}
+		return err
+	}
+	return shutdown, nil
+}
+
+// tracingMiddleware starts a server span for every request, continuing the
+// trace described by incoming traceparent headers. The span is named after
+// the matched chi route pattern once routing has completed.
+//
+// It must run inside the chi router (router.Use) so that the route pattern
+// is available.
+func tracingMiddleware(next http.Handler) http.Handler {
+	tracer := otel.Tracer(instrumentationName)
+	propagator := otel.GetTextMapPropagator()
+
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		ctx := propagator.Extract(request.Context(), propagation.HeaderCarrier(request.Header))
+		ctx, span := tracer.Start(ctx, request.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", request.Method),
+				attribute.String("url.path", request.URL.Path),
+				attribute.String("request_id", RequestIDFromContext(request.Context())),
+			))
+		defer span.End()
+
+		recorder := &statusRecorder{ResponseWriter: writer}
+		next.ServeHTTP(recorder, request.WithContext(ctx))
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if routeContext := chi.RouteContext(request.Context()); routeContext != nil && routeContext.RoutePattern() != "" {
+			span.SetName(request.Method + " " + routeContext.RoutePattern())
+			span.SetAttributes(attribute.String("http.route", routeContext.RoutePattern()))
+		}
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
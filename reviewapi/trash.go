@@ -0,0 +1,454 @@
+// Package reviewapi provides the review trash for the Movie Review API.
+// This file implements soft deletion: DeleteReview only stamps a review's
+// deletedAt column, and the review stays restorable until a background job
+// purges it once the retention period has passed.
+//
+// Behaviour:
+//   - Reviews in the trash are hidden from GetReviewById, UpdateReview and
+//     DeleteReview, which report them as not found
+//   - GET /trash lists them, most recently deleted first
+//   - POST /review/{id}/restore takes a review out of the trash
+//   - Every purge interval, reviews deleted longer than the retention period
+//     ago are removed for good
+//
+// API Endpoints:
+//   - GET  /trash               - List deleted reviews
+//   - POST /review/{id}/restore - Restore a deleted review
+package reviewapi
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Limits of the number of reviews returned by GET /trash.
+const (
+	defaultTrashLimit = 100
+	maxTrashLimit     = 1000
+)
+
+// TrashConfig holds the soft delete retention settings.
+type TrashConfig struct {
+	// Retention is how long a deleted review can be restored before it is
+	// purged. Zero keeps deleted reviews forever.
+	Retention time.Duration `yaml:"retention"`
+
+	// PurgeInterval is how often the purge job runs.
+	PurgeInterval time.Duration `yaml:"purge_interval"`
+}
+
+// Validate checks the trash settings.
+func (c TrashConfig) Validate() error {
+	if c.Retention < 0 {
+		return fmt.Errorf("retention must not be negative")
+	}
+	if c.Retention > 0 && c.PurgeInterval <= 0 {
+		return fmt.Errorf("purge_interval must be positive")
+	}
+	return nil
+}
+
+// TrashStore is implemented by the backends to list, restore and purge the
+// reviews in the trash. The trash routes are served when one is given with
+// WithTrash.
+type TrashStore interface {
+	// ListDeletedReviews returns up to limit reviews in the trash, most
+	// recently deleted first.
+	ListDeletedReviews(ctx context.Context, limit int) ([]*Review, error)
+
+	// RestoreReview takes a review out of the trash and returns it.
+	// Returns an error wrapping ErrNotFound if the review is not in the trash.
+	RestoreReview(ctx context.Context, id int) (*Review, error)
+
+	// PurgeDeletedReviews permanently removes the reviews deleted before
+	// cutoff and returns how many were removed.
+	PurgeDeletedReviews(ctx context.Context, cutoff time.Time) (int, error)
+}
+
+// ListDeletedReviews returns up to limit reviews from the trash, most
+// recently deleted first.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout control
+//   - limit: The maximum number of reviews returned
+//
+// Returns:
+//   - []*Review: The deleted reviews, with DeletedAt set
+//   - error: Non-nil if the query fails
+func (pg *PgDb) ListDeletedReviews(ctx context.Context, limit int) (_ []*Review, err error) {
+	ctx, finish := pg.beginOperation(ctx, "listDeleted", "SELECT", 0)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !pg.ready.Load() {
+		return nil, ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, pg.timeouts.Get)
+	defer cancel()
+
+	// Served by a caught-up replica if any; a stale statement is retried once
+	var reviews []*Review
+	err = pg.withReadStatement(ctx, pg.stmtListDeleted, func(s *replicaStatements) *preparedStmt { return s.listDeleted }, func(stmt *sql.Stmt) error {
+		reviews = nil
+		rows, err := stmt.QueryContext(ctx, limit)
+		if err != nil {
+			return err
+		}
+		reviews, err = scanReviews(rows)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deleted reviews: %w", contextError(ctx, err))
+	}
+	return reviews, nil
+}
+
+// RestoreReview takes a review out of the trash, audits it and writes it to
+// the outbox.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout control
+//   - id: The unique identifier of the deleted review
+//
+// Returns:
+//   - *Review: The restored review
+//   - error: Non-nil if the update fails; wraps ErrNotFound if the review is
+//     not in the trash
+func (pg *PgDb) RestoreReview(ctx context.Context, id int) (_ *Review, err error) {
+	ctx, finish := pg.beginOperation(ctx, "restore", "UPDATE", id)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !pg.ready.Load() {
+		return nil, ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, pg.timeouts.Update)
+	defer cancel()
+
+	review := &Review{}
+	err = pg.inTx(ctx, func(tx *sql.Tx) error {
+		// Lock the current version; reviews outside the trash are not found
+		current, err := pg.lockReview(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if current.DeletedAt == nil {
+			return sql.ErrNoRows
+		}
+
+		err = pg.withStatement(ctx, pg.stmtRestore, false, func(stmt *sql.Stmt) error {
+			return scanReview(tx.StmtContext(ctx, stmt).QueryRowContext(ctx, id), review)
+		})
+		if err != nil {
+			return err
+		}
+		if err := pg.insertAudit(ctx, tx, AuditRestore, id, current, review); err != nil {
+			return err
+		}
+		return pg.insertEvent(ctx, tx, EventReviewRestored, review)
+	})
+	if err = restoreResult(ctx, id, err); err != nil {
+		return nil, err
+	}
+	pg.recordWritePosition(ctx)
+	pg.logger.InfoContext(ctx, "restored review from trash", "review_id", id)
+	return review, nil
+}
+
+// PurgeDeletedReviews permanently removes the reviews deleted before cutoff,
+// with an audit entry for each.
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout control
+//   - cutoff: Reviews whose deletedAt is earlier than this are removed
+//
+// Returns:
+//   - int: The number of reviews removed
+//   - error: Non-nil if the deletion fails
+func (pg *PgDb) PurgeDeletedReviews(ctx context.Context, cutoff time.Time) (_ int, err error) {
+	ctx, finish := pg.beginOperation(ctx, "purge", "DELETE", 0)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !pg.ready.Load() {
+		return 0, ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, pg.timeouts.Delete)
+	defer cancel()
+
+	// Remove the reviews and audit each of them
+	var purged []*Review
+	err = pg.inTx(ctx, func(tx *sql.Tx) error {
+		err := pg.withStatement(ctx, pg.stmtPurge, false, func(stmt *sql.Stmt) error {
+			rows, err := tx.StmtContext(ctx, stmt).QueryContext(ctx, cutoff)
+			if err != nil {
+				return err
+			}
+			purged, err = scanReviews(rows)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		for _, review := range purged {
+			if err := pg.insertAudit(ctx, tx, AuditPurge, review.ID, review, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted reviews: %w", contextError(ctx, err))
+	}
+	return len(purged), nil
+}
+
+// ListDeletedReviews returns up to limit reviews from the trash, most
+// recently deleted first (see PgDb.ListDeletedReviews).
+func (s *SqliteDb) ListDeletedReviews(ctx context.Context, limit int) (_ []*Review, err error) {
+	ctx, finish := s.beginOperation(ctx, "listDeleted", "SELECT", 0)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !s.ready.Load() {
+		return nil, ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Get)
+	defer cancel()
+
+	rows, err := s.stmtListDeleted.QueryContext(ctx, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deleted reviews: %w", contextError(ctx, err))
+	}
+	reviews, err := scanReviews(rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deleted reviews: %w", contextError(ctx, err))
+	}
+	return reviews, nil
+}
+
+// RestoreReview takes a review out of the trash (see PgDb.RestoreReview).
+func (s *SqliteDb) RestoreReview(ctx context.Context, id int) (_ *Review, err error) {
+	ctx, finish := s.beginOperation(ctx, "restore", "UPDATE", id)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !s.ready.Load() {
+		return nil, ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Update)
+	defer cancel()
+
+	review := &Review{}
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		// Read the current version; reviews outside the trash are not found
+		current, err := s.lockReview(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if current.DeletedAt == nil {
+			return sql.ErrNoRows
+		}
+
+		err = scanReview(tx.StmtContext(ctx, s.stmtRestore).QueryRowContext(ctx, time.Now().UTC(), id), review)
+		if err != nil {
+			return err
+		}
+		if err := s.insertAudit(ctx, tx, AuditRestore, id, current, review); err != nil {
+			return err
+		}
+		return s.insertEvent(ctx, tx, EventReviewRestored, review)
+	})
+	if err = restoreResult(ctx, id, err); err != nil {
+		return nil, err
+	}
+	s.eventWritten()
+	s.logger.InfoContext(ctx, "restored review from trash", "review_id", id)
+	return review, nil
+}
+
+// PurgeDeletedReviews permanently removes the reviews deleted before cutoff
+// (see PgDb.PurgeDeletedReviews).
+func (s *SqliteDb) PurgeDeletedReviews(ctx context.Context, cutoff time.Time) (_ int, err error) {
+	ctx, finish := s.beginOperation(ctx, "purge", "DELETE", 0)
+	defer finish(&err)
+
+	// Reject calls until Connect has prepared the statements
+	if !s.ready.Load() {
+		return 0, ErrNotReady
+	}
+
+	// Apply the operation's budget on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Delete)
+	defer cancel()
+
+	// Remove the reviews and audit each of them
+	var purged []*Review
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.StmtContext(ctx, s.stmtPurge).QueryContext(ctx, cutoff.UTC())
+		if err != nil {
+			return err
+		}
+		if purged, err = scanReviews(rows); err != nil {
+			return err
+		}
+		for _, review := range purged {
+			if err := s.insertAudit(ctx, tx, AuditPurge, review.ID, review, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted reviews: %w", contextError(ctx, err))
+	}
+	return len(purged), nil
+}
+
+// scanReviews reads every review of rows and closes them.
+func scanReviews(rows *sql.Rows) ([]*Review, error) {
+	defer rows.Close()
+	reviews := []*Review{}
+	for rows.Next() {
+		review := &Review{}
+		if err := scanReview(rows, review); err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, review)
+	}
+	return reviews, rows.Err()
+}
+
+// restoreResult turns the outcome of a restore query into RestoreReview's
+// error, mapping a missing row to ErrNotFound.
+func restoreResult(ctx context.Context, id int, err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("deleted review with id %d %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to restore review: %w", contextError(ctx, err))
+	}
+	return nil
+}
+
+// StartTrashPurge purges the reviews deleted longer than the retention
+// period ago every purge interval, until stop is called. The first purge
+// runs after one interval, once the backend has had time to connect.
+// It does nothing when retention is zero.
+//
+// Parameters:
+//   - trash: The trash to purge (normally the decorated backend)
+//   - cfg: Retention and purge interval
+//   - logger: Logger for purge results and failures
+//
+// Returns:
+//   - func(): Stops the purge job and waits for a running purge to finish
+func StartTrashPurge(trash TrashStore, cfg TrashConfig, logger *slog.Logger) (stop func()) {
+	if cfg.Retention <= 0 {
+		return func() {}
+	}
+	logger = logger.With("component", "trash")
+	ctx, cancel := context.WithCancel(ContextWithActor(context.Background(), SystemActor))
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(cfg.PurgeInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			purged, err := trash.PurgeDeletedReviews(ctx, time.Now().Add(-cfg.Retention))
+			switch {
+			case errors.Is(err, ErrNotReady), errors.Is(err, ErrCanceled):
+				// Still connecting or shutting down: try again next time
+			case err != nil:
+				logger.Warn("purge deleted reviews", "error", err)
+			case purged > 0:
+				logger.Info("purged deleted reviews", "count", purged, "retention", cfg.Retention)
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// handleListTrash handles GET /trash requests.
+// It lists the deleted reviews that can still be restored.
+//
+// Query Parameters:
+//   - limit: The maximum number of reviews returned (default 100, at most 1000)
+//
+// Response:
+//   - 200 OK: Returns a JSON array of reviews, most recently deleted first
+//   - 400 Bad Request: If the limit is invalid
+//   - 499/503/504: If the request is cancelled or times out (see statusForError)
+//
+// Example Request:
+//
+//	GET /trash?limit=10
+func (server *APIServer) handleListTrash(writer http.ResponseWriter, request *http.Request) error {
+	// Parse and bound the optional limit
+	limit, err := parseLimit(request, defaultTrashLimit, maxTrashLimit)
+	if err != nil {
+		return err
+	}
+
+	reviews, err := server.trash.ListDeletedReviews(request.Context(), limit)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(writer, http.StatusOK, reviews)
+}
+
+// handleRestoreReview handles POST /review/{id}/restore requests.
+// It takes a review out of the trash.
+//
+// URL Parameters:
+//   - id: The numeric ID of the deleted review
+//
+// Response:
+//   - 200 OK: Returns the restored review
+//   - 400 Bad Request: If the ID is invalid or the review is not in the trash
+//   - 499/503/504: If the request is cancelled or times out (see statusForError)
+//
+// Example Request:
+//
+//	POST /review/42/restore
+func (server *APIServer) handleRestoreReview(writer http.ResponseWriter, request *http.Request) error {
+	// Extract and validate the ID from URL path
+	id, err := strconv.Atoi(chi.URLParam(request, "id"))
+	if err != nil {
+		return fmt.Errorf("invalid id: %w", err)
+	}
+
+	review, err := server.trash.RestoreReview(request.Context(), id)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(writer, http.StatusOK, review)
+}
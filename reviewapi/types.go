@@ -0,0 +1,322 @@
+// Package reviewapi provides the core types for the Movie Review API.
+// This file defines the data transfer objects (DTOs) and domain models used
+// throughout the application for handling movie review data.
+package reviewapi
+
+import (
+    "encoding/json"
+    "log/slog"
+    "time"
+)
+
+// CreateReviewRequest represents the expected JSON payload when creating a new review.
+// This DTO is used to validate and parse incoming POST requests to the /review endpoint.
+//
+// Example JSON:
+//
+//	{
+//	    "title": "Inception",
+//	    "director": "Christopher Nolan",
+//	    "releaseDate": "16 Jul 10 00:00 UTC",
+//	    "rating": "9/10",
+//	    "reviewNotes": "A mind-bending masterpiece"
+//	}
+type CreateReviewRequest struct {
+    // Title is the name of the movie being reviewed.
+    Title string `json:"title"`
+
+    // Director is the name of the movie's director.
+    Director string `json:"director"`
+
+    // ReleaseDate is the movie's release date in RFC822 format (e.g., "02 Jan 06 15:04 MST").
+    ReleaseDate string `json:"releaseDate"`
+
+    // Rating is a string representation of the review score (e.g., "8/10", "4 stars").
+    Rating string `json:"rating"`
+
+    // ReviewNotes contains the reviewer's written comments about the movie.
+    ReviewNotes string `json:"reviewNotes"`
+}
+
+// Review represents a complete movie review entity as stored in the database.
+// This is the primary domain model used for CRUD operations and API responses.
+//
+// The ID field is auto-generated by the database (SERIAL PRIMARY KEY).
+// The DateCreated field is automatically set when the review is created.
+type Review struct {
+    // ID is the unique identifier for the review, auto-generated by PostgreSQL.
+    ID int `json:"id"`
+
+    // Title is the name of the movie being reviewed.
+    Title string `json:"title"`
+
+    // Director is the name of the movie's director.
+    Director string `json:"director"`
+
+    // ReleaseDate is the formatted release date of the movie.
+    ReleaseDate string `json:"releaseDate"`
+
+    // Rating is a string representation of the review score.
+    Rating string `json:"rating"`
+
+    // ReviewNotes contains the reviewer's written comments about the movie.
+    ReviewNotes string `json:"reviewNotes"`
+
+    // DateCreated is the timestamp when this review was created, in RFC822 format.
+    DateCreated string `json:"dateCreated"`
+
+    // UpdatedAt is when the review was last created or updated, set by the
+    // storage backend. It is served as the Last-Modified header.
+    UpdatedAt time.Time `json:"updatedAt"`
+
+    // DeletedAt is when the review was moved to the trash, or nil for a
+    // live review. Reviews in the trash are hidden from reads until they
+    // are restored or purged.
+    DeletedAt *time.Time `json:"deletedAt,omitempty"`
+}
+
+// NewReview creates a new Review instance with the provided details.
+// It parses the releaseDate string (expected in RFC822 format) and sets
+// the DateCreated field to the current time.
+//
+// Parameters:
+//   - logger: Logger used to report an unparseable release date
+//   - title: The name of the movie
+//   - director: The director's name
+//   - releaseDate: The release date in RFC822 format (e.g., "02 Jan 06 15:04 MST")
+//   - rating: The review rating as a string
+//   - reviewNotes: The reviewer's comments
+//
+// Returns:
+//   - *Review: A pointer to the newly created Review instance
+//
+// Note: If the releaseDate cannot be parsed, the current time is used as a fallback
+// and a warning is logged.
+func NewReview(logger *slog.Logger, title string, director string, releaseDate string, rating string, reviewNotes string) *Review {
+    dateTime, err := time.Parse(time.RFC822, releaseDate)
+    if err != nil {
+        logger.Warn("unparseable release date, using current time",
+            "release_date", releaseDate,
+            "expected_format", "01 Jan 22 00:00 UTC",
+            "title", title)
+        // Use current time as fallback if parsing fails
+        dateTime = time.Now()
+    }
+    
+    // Format dates to a consistent 19-character string representation
+    dateString := dateTime.Format(time.RFC822)[0:19]
+    dateCreated := time.Now().Format(time.RFC822)[0:19]
+
+    return &Review{
+        Title:       title,
+        Director:    director,
+        ReleaseDate: dateString,
+        Rating:      rating,
+        ReviewNotes: reviewNotes,
+        DateCreated: dateCreated,
+    }
+}
+// Revision is one version of a review in its revision history. A revision is
+// recorded by every create and by every update that changes a field.
+//
+// Example JSON:
+//
+//	{
+//	    "reviewId": 42,
+//	    "revision": 2,
+//	    "author": "alice",
+//	    "createdAt": "2026-01-16T17:30:00.123456Z",
+//	    "changedFields": ["rating"],
+//	    "title": "Inception",
+//	    "director": "Christopher Nolan",
+//	    "releaseDate": "16 Jul 10 00:00",
+//	    "rating": "10/10",
+//	    "reviewNotes": "Even better on rewatch"
+//	}
+type Revision struct {
+    // ReviewID is the ID of the review this is a version of.
+    ReviewID int `json:"reviewId"`
+
+    // Revision numbers the versions of a review from 1 (its creation).
+    Revision int `json:"revision"`
+
+    // Author is the actor who made the change (see ActorFromContext).
+    Author string `json:"author"`
+
+    // CreatedAt is when the change was written; it equals the review's
+    // UpdatedAt at that point.
+    CreatedAt time.Time `json:"createdAt"`
+
+    // ChangedFields names the fields that differ from the previous revision.
+    ChangedFields []string `json:"changedFields"`
+
+    // Title, Director, ReleaseDate, Rating and ReviewNotes are the review's
+    // content as of this revision.
+    Title       string `json:"title"`
+    Director    string `json:"director"`
+    ReleaseDate string `json:"releaseDate"`
+    Rating      string `json:"rating"`
+    ReviewNotes string `json:"reviewNotes"`
+}
+
+// RevisionDiff lists the fields that differ between two revisions of a review.
+type RevisionDiff struct {
+    // ReviewID is the ID of the compared review.
+    ReviewID int `json:"reviewId"`
+
+    // From and To are the compared revision numbers.
+    From int `json:"from"`
+    To   int `json:"to"`
+
+    // Changes holds one entry per differing field, in field order.
+    Changes []FieldChange `json:"changes"`
+}
+
+// FieldChange is a field whose value differs between two revisions.
+type FieldChange struct {
+    Field string `json:"field"`
+    From  string `json:"from"`
+    To    string `json:"to"`
+}
+
+// AuditEntry is one record of the append-only audit log, written for every
+// review changed by a mutating store call.
+//
+// Example JSON:
+//
+//	{
+//	    "id": 1042,
+//	    "occurredAt": "2026-01-16T17:30:00.123456Z",
+//	    "actor": "alice",
+//	    "action": "update",
+//	    "reviewId": 42,
+//	    "before": {"id": 42, "title": "Inception", "rating": "9/10", ...},
+//	    "after": {"id": 42, "title": "Inception", "rating": "10/10", ...},
+//	    "requestId": "4bf92f3577b34da6a3ce929d0e0e4736",
+//	    "clientIp": "203.0.113.7",
+//	    "claimedActor": ""
+//	}
+type AuditEntry struct {
+    // ID orders the entries; later entries have larger IDs.
+    ID int64 `json:"id"`
+
+    // OccurredAt is when the change was written.
+    OccurredAt time.Time `json:"occurredAt"`
+
+    // Actor is who made the change, as authenticated (see
+    // ActorFromContext).
+    Actor string `json:"actor"`
+
+    // Action is one of create, update, delete, restore, purge or revert.
+    Action string `json:"action"`
+
+    // ReviewID is the ID of the changed review.
+    ReviewID int `json:"reviewId"`
+
+    // Before and After are the review as JSON before and after the change;
+    // null before a create and after a purge.
+    Before json.RawMessage `json:"before"`
+    After  json.RawMessage `json:"after"`
+
+    // RequestID is the ID of the HTTP request that made the change, empty
+    // for background jobs.
+    RequestID string `json:"requestId"`
+
+    // ClientIP is the address of the client that made the change, empty for
+    // background jobs.
+    ClientIP string `json:"clientIp"`
+
+    // ClaimedActor is the X-Actor header of the request that made the
+    // change, empty if none. Any client can send it: it is not verified.
+    ClaimedActor string `json:"claimedActor"`
+}
+
+// AuditQuery selects audit entries. Zero fields do not filter.
+type AuditQuery struct {
+    // Actor and Action select entries by exact match.
+    Actor  string
+    Action string
+
+    // Since and Until bound OccurredAt: Since inclusive, Until exclusive.
+    Since time.Time
+    Until time.Time
+
+    // BeforeID selects entries older than the given entry, for paging.
+    BeforeID int64
+
+    // Limit is the maximum number of entries returned.
+    Limit int
+}
+
+// ReviewEvent is a review change, as sent to webhooks and streamed by
+// GET /events. It is written to the outbox in the same transaction as the
+// change.
+//
+// Example JSON:
+//
+//	{
+//	    "id": 311,
+//	    "type": "review.updated",
+//	    "occurredAt": "2026-01-16T17:30:00.123456Z",
+//	    "reviewId": 42,
+//	    "review": {"id": 42, "title": "Inception", "rating": "10/10", ...}
+//	}
+type ReviewEvent struct {
+    // ID identifies the event; receivers use it to drop duplicates, since
+    // an event may be delivered more than once.
+    ID int64 `json:"id"`
+
+    // Type is review.created, review.updated, review.deleted or
+    // review.restored.
+    Type string `json:"type"`
+
+    // OccurredAt is when the change was written.
+    OccurredAt time.Time `json:"occurredAt"`
+
+    // ReviewID is the ID of the changed review.
+    ReviewID int `json:"reviewId"`
+
+    // Review is the review as JSON after the change.
+    Review json.RawMessage `json:"review"`
+}
+
+// WebhookDelivery is the delivery of one event to one webhook.
+type WebhookDelivery struct {
+    // ID identifies the delivery, e.g. to retry it once it is dead.
+    ID int64 `json:"id"`
+
+    // Webhook is the name of the webhook in webhooks.endpoints.
+    Webhook string `json:"webhook"`
+
+    // Status is pending until the webhook accepts the event (delivered), or
+    // dead once every attempt has failed.
+    Status string `json:"status"`
+
+    // Attempts counts the failed and successful attempts so far.
+    Attempts int `json:"attempts"`
+
+    // NextAttemptAt is when a pending delivery is due.
+    NextAttemptAt time.Time `json:"nextAttemptAt"`
+
+    // LastError describes why the last attempt failed, if it did.
+    LastError string `json:"lastError,omitempty"`
+
+    // UpdatedAt is when the delivery last changed.
+    UpdatedAt time.Time `json:"updatedAt"`
+
+    // Event is the event being delivered.
+    Event ReviewEvent `json:"event"`
+}
+
+// WebhookResult is the outcome of a delivery attempt.
+type WebhookResult struct {
+    // Status is the new status of the delivery: delivered, pending (to
+    // retry after RetryIn) or dead.
+    Status string
+
+    // RetryIn is the delay before the next attempt of a pending delivery.
+    RetryIn time.Duration
+
+    // Error describes why the attempt failed, empty if it succeeded.
+    Error string
+}
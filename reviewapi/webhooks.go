@@ -0,0 +1,402 @@
+// Package reviewapi provides webhook delivery for the Movie Review API.
+// This file implements the dispatcher that sends the events of the
+// transactional outbox (outbox.go) to the configured webhooks, and the admin
+// endpoints for deliveries that failed for good.
+//
+// Behaviour:
+//   - Every poll interval the dispatcher claims the due deliveries and POSTs
+//     each event as JSON to its webhook, all of a batch at once
+//   - A 2xx response delivers the event; any other outcome is retried with
+//     exponential backoff until max_attempts, after which the delivery is
+//     dead (the dead-letter view)
+//   - Events are delivered at least once and not necessarily in order;
+//     receivers drop duplicates by event ID
+//   - Every request is signed: X-Webhook-Signature is "sha256=" followed by
+//     the hex HMAC-SHA256, keyed with the webhook secret, of the
+//     X-Webhook-Timestamp header, a dot and the body
+//   - Several instances can dispatch at once: claimed deliveries are leased
+//     and skipped by the others
+//
+// API Endpoints:
+//   - GET  /admin/webhooks/dead-letters            - List dead deliveries
+//   - POST /admin/webhooks/dead-letters/{id}/retry - Retry a dead delivery
+package reviewapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Headers sent with every webhook request.
+const (
+	webhookEventHeader     = "X-Webhook-Event"
+	webhookDeliveryHeader  = "X-Webhook-Delivery"
+	webhookTimestampHeader = "X-Webhook-Timestamp"
+	webhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// webhookPruneInterval is how often delivered events past the retention
+// period are removed from the outbox.
+const webhookPruneInterval = time.Hour
+
+// maxWebhookErrorLength bounds the error recorded for a failed attempt.
+const maxWebhookErrorLength = 512
+
+// WebhookAdmin is implemented by the backends to manage the deliveries that
+// failed for good. The dead-letter routes are served when one is given with
+// WithWebhookAdmin.
+type WebhookAdmin interface {
+	// ListDeadWebhookDeliveries returns up to limit webhook deliveries that
+	// ran out of attempts, most recent first.
+	ListDeadWebhookDeliveries(ctx context.Context, limit int) ([]*WebhookDelivery, error)
+
+	// RetryWebhookDelivery makes a dead webhook delivery pending again and
+	// returns it. Returns an error wrapping ErrNotFound if it is not dead.
+	RetryWebhookDelivery(ctx context.Context, id int64) (*WebhookDelivery, error)
+}
+
+// Limits of the number of deliveries returned by GET /admin/webhooks/dead-letters.
+const (
+	defaultDeadLetterLimit = 100
+	maxDeadLetterLimit     = 1000
+)
+
+// WebhooksConfig holds the webhook endpoints and delivery settings.
+type WebhooksConfig struct {
+	// Endpoints maps webhook names to the URLs events are sent to. Every
+	// event is delivered to every endpoint.
+	Endpoints map[string]string `yaml:"endpoints"`
+
+	// Secret is the HMAC key of the signature sent with every event.
+	Secret Secret `yaml:"secret"`
+
+	// PollInterval is how often the outbox is checked for due deliveries.
+	PollInterval time.Duration `yaml:"poll_interval"`
+
+	// Timeout bounds each delivery attempt.
+	Timeout time.Duration `yaml:"timeout"`
+
+	// MaxAttempts is the number of attempts before a delivery is dead.
+	MaxAttempts int `yaml:"max_attempts"`
+
+	// RetryInitial and RetryMax bound the exponential backoff between the
+	// attempts of a delivery.
+	RetryInitial time.Duration `yaml:"retry_initial"`
+	RetryMax     time.Duration `yaml:"retry_max"`
+
+	// BatchSize is the maximum number of deliveries sent at once.
+	BatchSize int `yaml:"batch_size"`
+
+	// Retention is how long delivered events are kept in the outbox.
+	Retention time.Duration `yaml:"retention"`
+}
+
+// Validate checks the webhook settings.
+func (c WebhooksConfig) Validate() error {
+	for name, endpoint := range c.Endpoints {
+		parsed, err := url.Parse(endpoint)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("endpoint %q must be an http or https URL", name)
+		}
+	}
+	if len(c.Endpoints) > 0 && len(c.Secret) < minAdminTokenLength {
+		return fmt.Errorf("secret must be at least %d characters", minAdminTokenLength)
+	}
+	if c.PollInterval <= 0 || c.Timeout <= 0 {
+		return fmt.Errorf("poll_interval and timeout must be positive")
+	}
+	if c.MaxAttempts < 1 || c.BatchSize < 1 {
+		return fmt.Errorf("max_attempts and batch_size must be at least 1")
+	}
+	if err := c.backoff().Validate(); err != nil {
+		return fmt.Errorf("retry: %w", err)
+	}
+	if c.Retention < 0 {
+		return fmt.Errorf("retention must not be negative")
+	}
+	return nil
+}
+
+// backoff returns the backoff between the attempts of a delivery.
+func (c WebhooksConfig) backoff() BackoffConfig {
+	return BackoffConfig{InitialInterval: c.RetryInitial, MaxInterval: c.RetryMax}
+}
+
+// signWebhook returns the X-Webhook-Signature value of a request with the
+// given timestamp and body.
+func signWebhook(secret Secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret.Value()))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookDispatcher sends the deliveries of the outbox to the webhooks.
+type webhookDispatcher struct {
+	outbox  WebhookOutbox
+	cfg     WebhooksConfig
+	names   []string
+	client  *http.Client
+	logger  *slog.Logger
+	metrics *Metrics
+}
+
+// newWebhookDispatcher creates a dispatcher for the configured endpoints.
+func newWebhookDispatcher(outbox WebhookOutbox, cfg WebhooksConfig, logger *slog.Logger, metrics *Metrics) *webhookDispatcher {
+	names := make([]string, 0, len(cfg.Endpoints))
+	for name := range cfg.Endpoints {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return &webhookDispatcher{
+		outbox:  outbox,
+		cfg:     cfg,
+		names:   names,
+		client:  &http.Client{Timeout: cfg.Timeout},
+		logger:  logger.With("component", "webhooks"),
+		metrics: metrics,
+	}
+}
+
+// StartWebhookDispatcher sends due deliveries every poll interval and prunes
+// the outbox every webhookPruneInterval, until stop is called. Without
+// endpoints it still runs, so that events are marked as handled and pruned.
+//
+// Parameters:
+//   - outbox: The backend holding the outbox
+//   - cfg: The webhook endpoints and delivery settings
+//   - logger: Structured logger for delivery failures
+//   - metrics: Metrics registry counting attempts (may be nil)
+//
+// Returns:
+//   - stop: Stops the dispatcher and waits for the current batch to finish
+func StartWebhookDispatcher(outbox WebhookOutbox, cfg WebhooksConfig, logger *slog.Logger, metrics *Metrics) (stop func()) {
+	dispatcher := newWebhookDispatcher(outbox, cfg, logger, metrics)
+	ctx, cancel := context.WithCancel(ContextWithActor(context.Background(), SystemActor))
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(cfg.PollInterval)
+		defer ticker.Stop()
+		lastPrune := time.Now()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			// Keep going while full batches show a backlog
+			for {
+				sent, err := dispatcher.dispatch(ctx)
+				if err != nil {
+					dispatcher.logError("dispatch webhooks", err)
+				}
+				if err != nil || sent < cfg.BatchSize {
+					break
+				}
+			}
+
+			if cfg.Retention > 0 && time.Since(lastPrune) >= webhookPruneInterval {
+				lastPrune = time.Now()
+				if _, err := outbox.PruneOutbox(ctx, time.Now().Add(-cfg.Retention)); err != nil {
+					dispatcher.logError("prune outbox", err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// logError logs a failed outbox call, except while the backend is still
+// connecting or the dispatcher is stopping.
+func (d *webhookDispatcher) logError(msg string, err error) {
+	if !errors.Is(err, ErrNotReady) && !errors.Is(err, ErrCanceled) {
+		d.logger.Warn(msg, "error", err)
+	}
+}
+
+// dispatch claims a batch of due deliveries, sends them concurrently and
+// records their outcomes. It returns how many deliveries were claimed.
+func (d *webhookDispatcher) dispatch(ctx context.Context) (int, error) {
+	// The lease outlasts an attempt, so a delivery is not sent twice at once
+	deliveries, err := d.outbox.ClaimWebhookDeliveries(ctx, d.names, d.cfg.BatchSize, 2*d.cfg.Timeout)
+	if err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.deliver(ctx, delivery)
+		}()
+	}
+	wg.Wait()
+	return len(deliveries), nil
+}
+
+// deliver makes one attempt at a delivery and records its outcome.
+func (d *webhookDispatcher) deliver(ctx context.Context, delivery *WebhookDelivery) {
+	err := d.send(ctx, delivery)
+
+	// An attempt cut short by shutdown does not count; the lease expires
+	// and the delivery is sent again
+	if ctx.Err() != nil {
+		return
+	}
+
+	result, outcome := d.result(delivery, err)
+	d.metrics.webhookAttempted(delivery.Webhook, outcome)
+	if err != nil {
+		d.logger.WarnContext(ctx, "webhook delivery failed",
+			"webhook", delivery.Webhook,
+			"delivery_id", delivery.ID,
+			"event_id", delivery.Event.ID,
+			"attempt", delivery.Attempts+1,
+			"status", result.Status,
+			"error", err)
+	}
+	if err := d.outbox.CompleteWebhookDelivery(ctx, delivery.ID, result); err != nil {
+		d.logError("complete webhook delivery", err)
+	}
+}
+
+// result returns the outcome of an attempt at delivery that failed with err
+// (nil on success), and the outcome label counted in the metrics.
+func (d *webhookDispatcher) result(delivery *WebhookDelivery, err error) (WebhookResult, string) {
+	if err == nil {
+		return WebhookResult{Status: DeliveryDelivered}, "delivered"
+	}
+	message := err.Error()
+	if len(message) > maxWebhookErrorLength {
+		message = message[:maxWebhookErrorLength]
+	}
+	if delivery.Attempts+1 >= d.cfg.MaxAttempts {
+		return WebhookResult{Status: DeliveryDead, Error: message}, "dead"
+	}
+	return WebhookResult{
+		Status:  DeliveryPending,
+		RetryIn: d.cfg.backoff().delay(delivery.Attempts),
+		Error:   message,
+	}, "retry"
+}
+
+// send POSTs the event of delivery to its webhook, signed with the secret.
+func (d *webhookDispatcher) send(ctx context.Context, delivery *WebhookDelivery) error {
+	endpoint, ok := d.cfg.Endpoints[delivery.Webhook]
+	if !ok {
+		return fmt.Errorf("webhook %q is not configured", delivery.Webhook)
+	}
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return fmt.Errorf("encode event: %w", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(webhookEventHeader, delivery.Event.Type)
+	request.Header.Set(webhookDeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	request.Header.Set(webhookTimestampHeader, timestamp)
+	request.Header.Set(webhookSignatureHeader, signWebhook(d.cfg.Secret, timestamp, body))
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	// Drain a little of the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(response.Body, 4096))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("webhook responded %s", response.Status)
+	}
+	return nil
+}
+
+// handleListDeadLetters handles GET /admin/webhooks/dead-letters requests.
+// It lists the deliveries that ran out of attempts, most recent first.
+//
+// Query Parameters:
+//   - limit: Maximum number of deliveries, 1 to 1000 (default 100)
+//
+// Response:
+//   - 200 OK: Returns a JSON array of deliveries with their events
+//   - 400 Bad Request: If the limit is invalid
+//   - 401 Unauthorized: If the admin token is missing or wrong
+//   - 499/503/504: If the request is cancelled or times out (see statusForError)
+//
+// Example Request:
+//
+//	GET /admin/webhooks/dead-letters?limit=20
+//	Authorization: Bearer <admin token>
+func (server *APIServer) handleListDeadLetters(writer http.ResponseWriter, request *http.Request) error {
+	limit, err := parseLimit(request, defaultDeadLetterLimit, maxDeadLetterLimit)
+	if err != nil {
+		return err
+	}
+
+	deliveries, err := server.webhooks.ListDeadWebhookDeliveries(request.Context(), limit)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(writer, http.StatusOK, deliveries)
+}
+
+// handleRetryDeadLetter handles POST /admin/webhooks/dead-letters/{id}/retry
+// requests. It makes a dead delivery pending again, due immediately and with
+// a fresh set of attempts.
+//
+// URL Parameters:
+//   - id: The numeric ID of the delivery
+//
+// Response:
+//   - 200 OK: Returns the pending delivery
+//   - 400 Bad Request: If the ID is invalid or no dead delivery has it
+//   - 401 Unauthorized: If the admin token is missing or wrong
+//   - 499/503/504: If the request is cancelled or times out (see statusForError)
+//
+// Example Request:
+//
+//	POST /admin/webhooks/dead-letters/17/retry
+//	Authorization: Bearer <admin token>
+func (server *APIServer) handleRetryDeadLetter(writer http.ResponseWriter, request *http.Request) error {
+	// Extract and validate the ID from URL path
+	id, err := strconv.ParseInt(chi.URLParam(request, "id"), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid id: %w", err)
+	}
+
+	delivery, err := server.webhooks.RetryWebhookDelivery(request.Context(), id)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(writer, http.StatusOK, delivery)
+}
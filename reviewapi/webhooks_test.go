@@ -0,0 +1,172 @@
+package reviewapi
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookReceiver is a local HTTP endpoint recording the webhook requests
+// it receives and answering them with scripted status codes.
+type webhookReceiver struct {
+	t      *testing.T
+	secret Secret
+
+	mu       sync.Mutex
+	statuses []int
+	events   []ReviewEvent
+}
+
+// newWebhookReceiver starts a receiver answering with statuses in turn, then
+// with the last one. It returns the receiver and its URL.
+func newWebhookReceiver(t *testing.T, secret Secret, statuses ...int) (*webhookReceiver, string) {
+	receiver := &webhookReceiver{t: t, secret: secret, statuses: statuses}
+	server := httptest.NewServer(receiver)
+	t.Cleanup(server.Close)
+	return receiver, server.URL
+}
+
+// ServeHTTP checks the signature and headers of a webhook request.
+func (r *webhookReceiver) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		r.t.Errorf("read webhook body: %v", err)
+	}
+	timestamp := request.Header.Get(webhookTimestampHeader)
+	if got, want := request.Header.Get(webhookSignatureHeader), signWebhook(r.secret, timestamp, body); got != want {
+		r.t.Errorf("signature %q, want %q", got, want)
+	}
+	var event ReviewEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		r.t.Errorf("decode webhook event: %v", err)
+	}
+	if got := request.Header.Get(webhookEventHeader); got != event.Type {
+		r.t.Errorf("%s header %q, want %q", webhookEventHeader, got, event.Type)
+	}
+	if _, err := strconv.ParseInt(request.Header.Get(webhookDeliveryHeader), 10, 64); err != nil {
+		r.t.Errorf("%s header: %v", webhookDeliveryHeader, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	status := r.statuses[0]
+	if len(r.statuses) > 1 {
+		r.statuses = r.statuses[1:]
+	}
+	writer.WriteHeader(status)
+}
+
+// received returns the events received so far.
+func (r *webhookReceiver) received() []ReviewEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]ReviewEvent(nil), r.events...)
+}
+
+// testWebhooksConfig returns webhook settings with short retries.
+func testWebhooksConfig(endpoints map[string]string) WebhooksConfig {
+	cfg := DefaultConfig().Webhooks
+	cfg.Endpoints = endpoints
+	cfg.Secret = "test-webhook-secret-0123456789"
+	cfg.PollInterval = 10 * time.Millisecond
+	cfg.RetryInitial = time.Millisecond
+	cfg.RetryMax = time.Millisecond
+	cfg.MaxAttempts = 2
+	return cfg
+}
+
+// TestWebhookDispatcherRetries checks that a rejected event is sent again
+// and delivered, and that an endpoint rejecting every attempt ends up with
+// a dead delivery that can be retried.
+func TestWebhookDispatcherRetries(t *testing.T) {
+	db := newTestSqliteDb(t)
+	ctx := context.Background()
+	cfg := testWebhooksConfig(nil)
+	flaky, flakyURL := newWebhookReceiver(t, cfg.Secret, http.StatusInternalServerError, http.StatusNoContent)
+	down, downURL := newWebhookReceiver(t, cfg.Secret, http.StatusServiceUnavailable)
+	cfg.Endpoints = map[string]string{"flaky": flakyURL, "down": downURL}
+	dispatcher := newWebhookDispatcher(db, cfg, discardLogger(), nil)
+
+	review := testReview("Nostalghia")
+	if _, err := db.CreateReview(ctx, review); err != nil {
+		t.Fatalf("CreateReview: %v", err)
+	}
+
+	// Both webhooks fail the first attempt, then flaky accepts the retry
+	for attempt := 1; attempt <= cfg.MaxAttempts; attempt++ {
+		time.Sleep(10 * time.Millisecond)
+		if sent, err := dispatcher.dispatch(ctx); err != nil || sent != 2 {
+			t.Fatalf("attempt %d: dispatch = %d, %v; want 2 deliveries", attempt, sent, err)
+		}
+	}
+	for _, receiver := range []*webhookReceiver{flaky, down} {
+		events := receiver.received()
+		if len(events) != 2 || events[0].ID != events[1].ID || events[0].Type != EventReviewCreated || events[0].ReviewID != review.ID {
+			t.Fatalf("received %+v, want two attempts at the creation of review %d", events, review.ID)
+		}
+	}
+
+	// Nothing is left to send, and down's delivery is dead
+	time.Sleep(10 * time.Millisecond)
+	if sent, err := dispatcher.dispatch(ctx); err != nil || sent != 0 {
+		t.Fatalf("dispatch after the last attempt = %d, %v; want nothing", sent, err)
+	}
+	letters, err := db.ListDeadWebhookDeliveries(ctx, 10)
+	if err != nil || len(letters) != 1 || letters[0].Webhook != "down" || letters[0].Attempts != cfg.MaxAttempts {
+		t.Fatalf("ListDeadWebhookDeliveries = %v, %v; want down's delivery", letters, err)
+	}
+	if letters[0].LastError != "webhook responded 503 Service Unavailable" {
+		t.Fatalf("dead delivery error %q", letters[0].LastError)
+	}
+
+	// A retried dead delivery is sent again
+	if _, err := db.RetryWebhookDelivery(ctx, letters[0].ID); err != nil {
+		t.Fatalf("RetryWebhookDelivery: %v", err)
+	}
+	if sent, err := dispatcher.dispatch(ctx); err != nil || sent != 1 || len(down.received()) != 3 {
+		t.Fatalf("dispatch after retry = %d, %v; want down's delivery sent again", sent, err)
+	}
+}
+
+// TestWebhookDispatcherStart checks that the background dispatcher delivers
+// events written after it started.
+func TestWebhookDispatcherStart(t *testing.T) {
+	db := newTestSqliteDb(t)
+	cfg := testWebhooksConfig(nil)
+	receiver, url := newWebhookReceiver(t, cfg.Secret, http.StatusOK)
+	cfg.Endpoints = map[string]string{"receiver": url}
+	metrics := NewMetrics()
+
+	stop := StartWebhookDispatcher(db, cfg, discardLogger(), metrics)
+	defer stop()
+
+	review := testReview("Mirror")
+	if _, err := db.CreateReview(context.Background(), review); err != nil {
+		t.Fatalf("CreateReview: %v", err)
+	}
+	if err := db.DeleteReview(context.Background(), review.ID); err != nil {
+		t.Fatalf("DeleteReview: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(receiver.received()) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("received %d events, want 2", len(receiver.received()))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	types := map[string]bool{}
+	for _, event := range receiver.received() {
+		types[event.Type] = true
+	}
+	if !types[EventReviewCreated] || !types[EventReviewDeleted] {
+		t.Fatalf("received event types %v, want review.created and review.deleted", types)
+	}
+}